@@ -20,11 +20,79 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/certs"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restful"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
 )
 
+// Default time given to the shutdown, below the 30 second termination grace period of Kubernetes
+const defaultShutdownTimeout = 25 * time.Second
+
 func main() {
+	os.Exit(run())
+}
+
+// run returns the exit code, after the deferred cleanup is done
+func run() int {
 	appmgr.Init()
-	restful.NewRestful().Run()
+
+	if err := certs.Init(); err != nil {
+		appmgr.Logger.Error("Configuring TLS failed: %v", err)
+		return 1
+	}
+
+	shutdown, err := tracing.Init()
+	if err != nil {
+		appmgr.Logger.Error("Tracing not started: %v", err)
+	} else {
+		defer shutdown(context.Background())
+	}
+
+	return supervise(restful.NewRestful())
+}
+
+// supervise runs r until SIGTERM or SIGINT, or until its server fails or it
+// loses the leadership, and then shuts it down within 'shutdown.timeout' seconds
+func supervise(r *restful.Restful) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	failed := make(chan error, 1)
+	go func() {
+		failed <- r.Run()
+	}()
+
+	code := 0
+	select {
+	case sig := <-signals:
+		appmgr.Logger.Info("Received %v, shutting down", sig)
+	case err := <-failed:
+		appmgr.Logger.Error("Xapp manager stopped: %v", err)
+		code = 1
+	}
+
+	timeout := time.Duration(viper.GetInt("shutdown.timeout")) * time.Second
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := r.Shutdown(ctx); err != nil {
+		appmgr.Logger.Error("Shutdown incomplete: %v", err)
+		code = 1
+	} else {
+		appmgr.Logger.Info("Shutdown complete")
+	}
+	return code
 }
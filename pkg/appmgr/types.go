@@ -20,6 +20,11 @@
 package appmgr
 
 import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
 )
 
@@ -46,8 +51,10 @@ type ConfigMapper interface {
 	RestoreConfigMap(m models.XappDescriptor, cm interface{}) (err error)
 	ReadConfigMap(name string, ns string, c *interface{}) (err error)
 	ApplyConfigMap(r models.XAppConfig, action string) (err error)
-	GetRtmData(name string) (msgs RtmData)
+	GetRtmData(name, ns string) (msgs RtmData)
 	GetNamespace(ns string) string
+	GetNamespaces() []string
+	ValidateNamespace(ns string) (string, error)
 	GetNamesFromHelmRepo() (names []string)
 }
 
@@ -55,11 +62,58 @@ type Helmer interface {
 	SetCM(ConfigMapper)
 	Initialize()
 	Install(m models.XappDescriptor) (xapp models.Xapp, err error)
-	Status(name string) (xapp models.Xapp, err error)
-	StatusAll() (xapps models.AllDeployedXapps, err error)
+	Status(name, namespace string) (xapp models.Xapp, err error)
+	StatusAll(namespace string) (xapps models.AllDeployedXapps, err error)
 	SearchAll() (xapps []string)
-	List() (xapps []string, err error)
-	Delete(name string) (xapp models.Xapp, err error)
+	List(namespace string) (xapps []string, err error)
+	Delete(name, namespace string) (xapp models.Xapp, err error)
+	Upgrade(m models.XappDescriptor) (xapp models.Xapp, err error)
+	Rollback(name, namespace string, revision int) (xapp models.Xapp, err error)
+	History(name, namespace string) (history models.XappHistory, err error)
+}
+
+// ReleaseBackend hides how helm releases and charts are managed, either through
+// the helm command line or natively through the Helm v3 SDK. The operations that
+// change a release are traced as children of the span in their context.
+type ReleaseBackend interface {
+	AddRepo(name, url, username, password string) error
+	UpdateRepos() error
+	Install(ctx context.Context, x models.XappDescriptor) (*Release, error)
+	Upgrade(ctx context.Context, x models.XappDescriptor) (*Release, error)
+	Uninstall(ctx context.Context, name, namespace string) error
+	Rollback(ctx context.Context, name, namespace string, revision int) error
+	History(name, namespace string) ([]*Release, error)
+	Status(name, namespace string) (*Release, error)
+	List(namespace string) ([]*Release, error)
+	Search() ([]string, error)
+	Fetch(chart, version, dir string) error
+}
+
+// KubeClient gives typed access to the kubernetes objects read and updated by
+// appmgr. Updates carry the resourceVersion of the given object, so they fail
+// with a conflict if the object was modified after it was read.
+type KubeClient interface {
+	GetConfigMap(name, namespace string) (*corev1.ConfigMap, error)
+	UpdateConfigMap(c *corev1.ConfigMap) (*corev1.ConfigMap, error)
+	GetService(name, namespace string) (*corev1.Service, error)
+}
+
+type Release struct {
+	Name        string
+	Namespace   string
+	Chart       string
+	Version     string
+	AppVersion  string
+	Status      string
+	Revision    int
+	Updated     time.Time
+	Description string
+	Pods        []Pod
+}
+
+type Pod struct {
+	Name   string
+	Status string
 }
 
 type Helm struct {
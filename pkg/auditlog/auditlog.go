@@ -0,0 +1,258 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdl "gerrit.o-ran-sc.org/r/ric-plt/sdlgo"
+	"github.com/go-openapi/strfmt"
+	"github.com/segmentio/ksuid"
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/metrics"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
+)
+
+// To keep the audit records under their own namespace in a DB
+const (
+	auditSdlNs        = "appmgr-audit"
+	defaultMaxRecords = 10000
+	defaultMaxAge     = 90 * 24 * time.Hour
+	defaultLimit      = 100
+	pruneInterval     = time.Minute
+)
+
+type iSdl interface {
+	Set(ns string, pairs ...interface{}) error
+	Get(ns string, keys []string) (map[string]interface{}, error)
+	GetAll(ns string) ([]string, error)
+	Remove(ns string, keys []string) error
+}
+
+// Log keeps an audit record of every mutating request. Records older than
+// maxAge, and the oldest ones beyond maxRecords, are dropped at most once per
+// pruneInterval, so the limits may be exceeded briefly.
+type Log struct {
+	mutex      sync.Mutex
+	db         iSdl
+	maxRecords int
+	maxAge     time.Duration
+	prunedAt   time.Time
+	now        func() time.Time
+}
+
+// Filter selects audit records. Zero values match all records.
+type Filter struct {
+	Since     time.Time
+	Until     time.Time
+	XappName  string
+	Namespace string
+	Limit     int
+}
+
+// NewLog returns a Log configured from the 'audit' section
+func NewLog() *Log {
+	return createLog(metrics.InstrumentSdl(tracing.InstrumentSdl(sdl.NewSyncStorage())))
+}
+
+func createLog(sdlInst iSdl) *Log {
+	l := &Log{
+		db:         sdlInst,
+		maxRecords: viper.GetInt("audit.maxRecords"),
+		maxAge:     time.Duration(viper.GetInt("audit.maxAgeDays")) * 24 * time.Hour,
+		now:        time.Now,
+	}
+	if l.maxRecords <= 0 {
+		l.maxRecords = defaultMaxRecords
+	}
+	if l.maxAge <= 0 {
+		l.maxAge = defaultMaxAge
+	}
+	return l
+}
+
+// Keys start with the zero padded record time, so that they sort in time order
+func recordKey(t time.Time, id string) string {
+	return fmt.Sprintf("%020d:%s", t.UnixNano(), id)
+}
+
+func keyTime(key string) (time.Time, bool) {
+	nanos, err := strconv.ParseInt(strings.SplitN(key, ":", 2)[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+// Add stores r with a new ID and the current time, which are set in the returned record
+func (l *Log) Add(r models.AuditRecord) (models.AuditRecord, error) {
+	return l.AddContext(context.Background(), r)
+}
+
+// AddContext is Add within the trace of ctx
+func (l *Log) AddContext(ctx context.Context, r models.AuditRecord) (models.AuditRecord, error) {
+	now := l.now()
+	r.ID = ksuid.New().String()
+	r.Timestamp = strfmt.DateTime(now.UTC())
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		appmgr.Logger.Error("json.marshal failed: %v ", err.Error())
+		return r, err
+	}
+	if err := l.storage(ctx).Set(auditSdlNs, recordKey(now, r.ID), data); err != nil {
+		appmgr.Logger.Error("DB.session.Set failed: %v ", err.Error())
+		return r, err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if now.Sub(l.prunedAt) >= pruneInterval {
+		l.prunedAt = now
+		l.prune(now)
+	}
+	return r, nil
+}
+
+// storage returns the db for the operations done within ctx
+func (l *Log) storage(ctx context.Context) iSdl {
+	if db, ok := l.db.(metrics.Storage); ok {
+		return metrics.WithContext(ctx, db)
+	}
+	return l.db
+}
+
+// List returns the records selected by f, newest first
+func (l *Log) List(f Filter) (models.AuditRecordList, error) {
+	records := models.AuditRecordList{}
+	if f.Limit <= 0 {
+		f.Limit = defaultLimit
+	}
+
+	keys, err := l.keys()
+	if err != nil {
+		return records, err
+	}
+
+	var selected []string
+	for i := len(keys) - 1; i >= 0; i-- {
+		t, _ := keyTime(keys[i])
+		if (!f.Since.IsZero() && t.Before(f.Since)) || (!f.Until.IsZero() && !t.Before(f.Until)) {
+			continue
+		}
+		selected = append(selected, keys[i])
+	}
+	if len(selected) == 0 {
+		return records, nil
+	}
+
+	values, err := l.db.Get(auditSdlNs, selected)
+	if err != nil {
+		appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
+		return records, err
+	}
+
+	for _, key := range selected {
+		r, err := parseRecord(values[key])
+		if err != nil {
+			appmgr.Logger.Error("Skipping invalid audit record '%s': %v", key, err)
+			continue
+		}
+		if (f.XappName != "" && r.XappName != f.XappName) || (f.Namespace != "" && r.Namespace != f.Namespace) {
+			continue
+		}
+		records = append(records, r)
+		if len(records) == f.Limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+// Close closes the SDL client of the log
+func (l *Log) Close() error {
+	return metrics.CloseStorage(l.db)
+}
+
+// prune drops the records older than maxAge, and then the oldest records beyond maxRecords
+func (l *Log) prune(now time.Time) {
+	keys, err := l.keys()
+	if err != nil {
+		return
+	}
+
+	expired := 0
+	for expired < len(keys) {
+		if t, ok := keyTime(keys[expired]); ok && now.Sub(t) <= l.maxAge {
+			break
+		}
+		expired++
+	}
+	if excess := len(keys) - l.maxRecords; excess > expired {
+		expired = excess
+	}
+	if expired == 0 {
+		return
+	}
+
+	if err := l.db.Remove(auditSdlNs, keys[:expired]); err != nil {
+		appmgr.Logger.Error("DB.session.Remove failed: %v ", err.Error())
+		return
+	}
+	appmgr.Logger.Info("Dropped %d audit records", expired)
+}
+
+func (l *Log) keys() ([]string, error) {
+	keys, err := l.db.GetAll(auditSdlNs)
+	if err != nil {
+		appmgr.Logger.Error("DB.session.GetAll failed: %v ", err.Error())
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func parseRecord(value interface{}) (*models.AuditRecord, error) {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil, fmt.Errorf("unexpected value %T", value)
+	}
+
+	var r models.AuditRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,192 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package auditlog
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+func TestMain(m *testing.M) {
+	appmgr.Init()
+	appmgr.Logger.SetLevel(0)
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+// newTestLog returns a Log whose clock starts at 2026-01-01 and advances a second per record
+func newTestLog() (*Log, *fakeSdl) {
+	db := newFakeSdl()
+	l := createLog(db)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return l, db
+}
+
+func record(operation, xappName, namespace string) models.AuditRecord {
+	return models.AuditRecord{Caller: "alice", Operation: operation, XappName: xappName, Namespace: namespace}
+}
+
+func TestAddSetsIDAndTimestamp(t *testing.T) {
+	l, _ := newTestLog()
+
+	r, err := l.Add(record("deployXapp", "dummy-xapp", "ricxapp"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", r.ID)
+	assert.Equal(t, "2026-01-01T00:00:01.000Z", r.Timestamp.String())
+
+	records, err := l.List(Filter{})
+	assert.Nil(t, err)
+	assert.Equal(t, models.AuditRecordList{&r}, records)
+}
+
+func TestListNewestFirstWithFilters(t *testing.T) {
+	l, _ := newTestLog()
+	l.Add(record("deployXapp", "dummy-xapp", "ricxapp"))       // 00:00:01
+	l.Add(record("deployXapp", "other-xapp", "ricxapp"))       // 00:00:02
+	l.Add(record("ModifyXappConfig", "dummy-xapp", "ricxapp")) // 00:00:03
+	l.Add(record("undeployXapp", "dummy-xapp", "trialxapp"))   // 00:00:04
+
+	records, _ := l.List(Filter{XappName: "dummy-xapp", Namespace: "ricxapp"})
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "ModifyXappConfig", records[0].Operation)
+	assert.Equal(t, "deployXapp", records[1].Operation)
+
+	// Since is inclusive, until exclusive
+	since := time.Date(2026, 1, 1, 0, 0, 2, 0, time.UTC)
+	records, _ = l.List(Filter{Since: since, Until: since.Add(2 * time.Second)})
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "ModifyXappConfig", records[0].Operation)
+	assert.Equal(t, "other-xapp", records[1].XappName)
+
+	records, _ = l.List(Filter{Limit: 1})
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, "undeployXapp", records[0].Operation)
+}
+
+func TestAddDropsOldestRecords(t *testing.T) {
+	l, db := newTestLog()
+	l.maxRecords = 2
+
+	for i := 0; i < 3; i++ {
+		l.Add(record("deployXapp", "dummy-xapp", "ricxapp"))
+	}
+	// Pruned on the first record only
+	assert.Equal(t, 3, len(db.data[auditSdlNs]))
+
+	l.prunedAt = time.Time{}
+	l.Add(record("undeployXapp", "dummy-xapp", "ricxapp"))
+	records, _ := l.List(Filter{})
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "undeployXapp", records[0].Operation)
+}
+
+func TestAddDropsExpiredRecords(t *testing.T) {
+	l, _ := newTestLog()
+	l.maxAge = time.Hour
+
+	l.Add(record("deployXapp", "dummy-xapp", "ricxapp"))
+	clock := l.now
+	l.now = func() time.Time { return clock().Add(2 * time.Hour) }
+	l.Add(record("undeployXapp", "dummy-xapp", "ricxapp"))
+
+	records, _ := l.List(Filter{})
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, "undeployXapp", records[0].Operation)
+}
+
+func TestAddFailsIfDBIsDown(t *testing.T) {
+	l, db := newTestLog()
+	db.err = errors.New("db down")
+
+	_, err := l.Add(record("deployXapp", "dummy-xapp", "ricxapp"))
+	assert.NotNil(t, err)
+}
+
+type fakeSdl struct {
+	mutex sync.Mutex
+	data  map[string]map[string]interface{}
+	err   error
+}
+
+func newFakeSdl() *fakeSdl {
+	return &fakeSdl{data: make(map[string]map[string]interface{})}
+}
+
+func (f *fakeSdl) Set(ns string, pairs ...interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+	if f.data[ns] == nil {
+		f.data[ns] = make(map[string]interface{})
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		f.data[ns][pairs[i].(string)] = string(pairs[i+1].([]byte))
+	}
+	return nil
+}
+
+func (f *fakeSdl) Get(ns string, keys []string) (map[string]interface{}, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	values := make(map[string]interface{})
+	for _, key := range keys {
+		if v, found := f.data[ns][key]; found {
+			values[key] = v
+		}
+	}
+	return values, f.err
+}
+
+func (f *fakeSdl) GetAll(ns string) (keys []string, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for key := range f.data[ns] {
+		keys = append(keys, key)
+	}
+	return keys, f.err
+}
+
+func (f *fakeSdl) Remove(ns string, keys []string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(f.data[ns], key)
+	}
+	return f.err
+}
@@ -0,0 +1,197 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package auth authenticates the bearer tokens of REST requests, with static
+// tokens, JWTs signed by a key of a JWKS file, or Kubernetes TokenReview, and
+// authorizes the swagger operations by the roles of the caller.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The roles of the default policy in appmgr.yaml
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+	RoleXapp     = "xapp"
+
+	// AllOperations in the operations of a role grants every operation
+	AllOperations = "*"
+)
+
+var (
+	ErrNoToken      = errors.New("no bearer token")
+	ErrUnknownToken = errors.New("unknown token")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Principal is the authenticated caller
+type Principal struct {
+	Name  string
+	Roles []string
+}
+
+// Authenticator returns the principal of a bearer token. Tokens it does not
+// know, e.g. a JWT with a key ID of another issuer, fail with ErrUnknownToken
+// so that the next authenticator can try them.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+// Chain tries its authenticators in order, the first one knowing the token decides
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(ctx, token)
+		if !errors.Is(err, ErrUnknownToken) {
+			return p, err
+		}
+	}
+	return nil, ErrUnknownToken
+}
+
+// BearerToken returns the token of the Authorization header of req
+func BearerToken(req *http.Request) (string, error) {
+	header := req.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", ErrNoToken
+	}
+	token := strings.TrimSpace(header[7:])
+	if token == "" {
+		return "", ErrNoToken
+	}
+	return token, nil
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx by WithPrincipal, if any
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Role lists the swagger operations granted by a role, and the roles whose
+// operations it inherits
+type Role struct {
+	Operations []string `json:"operations" mapstructure:"operations"`
+	Inherits   []string `json:"inherits" mapstructure:"inherits"`
+}
+
+// Policy maps roles to the swagger operations they may call
+type Policy struct {
+	anonymous  map[string]bool
+	operations map[string]map[string]bool
+}
+
+// NewPolicy resolves the inherited operations of each role. The anonymous
+// operations may be called without a token.
+func NewPolicy(anonymous []string, roles map[string]Role) (*Policy, error) {
+	p := &Policy{anonymous: toSet(anonymous), operations: make(map[string]map[string]bool)}
+	for name := range roles {
+		if _, err := p.resolve(name, roles, nil); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *Policy) resolve(name string, roles map[string]Role, path []string) (map[string]bool, error) {
+	if ops, found := p.operations[name]; found {
+		return ops, nil
+	}
+	for _, n := range path {
+		if n == name {
+			return nil, fmt.Errorf("role '%s' inherits itself", name)
+		}
+	}
+	role, found := roles[name]
+	if !found {
+		return nil, fmt.Errorf("unknown role '%s'", name)
+	}
+
+	ops := toSet(role.Operations)
+	for _, parent := range role.Inherits {
+		inherited, err := p.resolve(parent, roles, append(path, name))
+		if err != nil {
+			return nil, err
+		}
+		for op := range inherited {
+			ops[op] = true
+		}
+	}
+	p.operations[name] = ops
+	return ops, nil
+}
+
+// Anonymous tells whether operation may be called without a token
+func (p *Policy) Anonymous(operation string) bool {
+	return p.anonymous[operation]
+}
+
+// Allowed tells whether one of the roles of principal grants operation
+func (p *Policy) Allowed(principal *Principal, operation string) bool {
+	if p.Anonymous(operation) {
+		return true
+	}
+	if principal == nil {
+		return false
+	}
+	for _, role := range principal.Roles {
+		if ops := p.operations[role]; ops[operation] || ops[AllOperations] {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorizer authenticates requests and checks their operation against the policy
+type Authorizer struct {
+	Authenticator
+	*Policy
+}
+
+// AuthenticateRequest returns the principal of the bearer token of req
+func (a *Authorizer) AuthenticateRequest(req *http.Request) (*Principal, error) {
+	token, err := BearerToken(req)
+	if err != nil {
+		return nil, err
+	}
+	return a.Authenticate(req.Context(), token)
+}
+
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, v := range list {
+		set[v] = true
+	}
+	return set
+}
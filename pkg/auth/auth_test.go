@@ -0,0 +1,136 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testRoles = map[string]Role{
+	RoleViewer:   {Operations: []string{"getAllXapps"}},
+	RoleOperator: {Operations: []string{"deployXapp"}, Inherits: []string{RoleViewer}},
+	RoleAdmin:    {Operations: []string{AllOperations}},
+	RoleXapp:     {Operations: []string{"registerXapp"}},
+}
+
+func TestPolicyResolvesInheritedOperations(t *testing.T) {
+	p, err := NewPolicy([]string{"getHealthAlive"}, testRoles)
+	assert.Nil(t, err)
+
+	operator := &Principal{Name: "alice", Roles: []string{RoleOperator}}
+	assert.True(t, p.Allowed(operator, "deployXapp"))
+	assert.True(t, p.Allowed(operator, "getAllXapps"))
+	assert.False(t, p.Allowed(operator, "registerXapp"))
+
+	admin := &Principal{Name: "root", Roles: []string{RoleAdmin}}
+	assert.True(t, p.Allowed(admin, "getAuditRecords"))
+
+	assert.True(t, p.Allowed(nil, "getHealthAlive"))
+	assert.False(t, p.Allowed(nil, "getAllXapps"))
+	assert.False(t, p.Allowed(&Principal{Name: "bob", Roles: []string{"nobody"}}, "getAllXapps"))
+}
+
+func TestPolicyRejectsUnknownAndCyclicRoles(t *testing.T) {
+	_, err := NewPolicy(nil, map[string]Role{"a": {Inherits: []string{"missing"}}})
+	assert.NotNil(t, err)
+
+	_, err = NewPolicy(nil, map[string]Role{"a": {Inherits: []string{"b"}}, "b": {Inherits: []string{"a"}}})
+	assert.NotNil(t, err)
+}
+
+func TestChainSkipsUnknownTokens(t *testing.T) {
+	first, second := &StaticAuthenticator{}, &StaticAuthenticator{}
+	first.Add("alice", "token-a", RoleOperator)
+	second.Add("bob", "token-b", RoleViewer)
+	chain := Chain{first, second, failingAuthenticator{}}
+
+	p, err := chain.Authenticate(context.Background(), "token-b")
+	assert.Nil(t, err)
+	assert.Equal(t, &Principal{Name: "bob", Roles: []string{RoleViewer}}, p)
+
+	_, err = chain.Authenticate(context.Background(), "token-c")
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+
+	_, err = Chain{first}.Authenticate(context.Background(), "token-c")
+	assert.Equal(t, ErrUnknownToken, err)
+}
+
+func TestBearerToken(t *testing.T) {
+	req := httptest.NewRequest("GET", "/ric/v1/xapps", nil)
+	_, err := BearerToken(req)
+	assert.Equal(t, ErrNoToken, err)
+
+	req.Header.Set("Authorization", "Basic YWxpY2U6c2VjcmV0")
+	_, err = BearerToken(req)
+	assert.Equal(t, ErrNoToken, err)
+
+	req.Header.Set("Authorization", "bearer  token-a ")
+	token, err := BearerToken(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "token-a", token)
+}
+
+func TestAuthenticateRequest(t *testing.T) {
+	static := &StaticAuthenticator{}
+	static.Add("alice", "token-a", RoleOperator)
+	policy, _ := NewPolicy(nil, testRoles)
+	a := &Authorizer{Authenticator: static, Policy: policy}
+
+	req := httptest.NewRequest("POST", "/ric/v1/xapps", nil)
+	req.Header.Set("Authorization", "Bearer token-a")
+	p, err := a.AuthenticateRequest(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", p.Name)
+
+	ctx := WithPrincipal(req.Context(), p)
+	assert.Equal(t, p, PrincipalFrom(ctx))
+	assert.Nil(t, PrincipalFrom(context.Background()))
+}
+
+func TestLoadStaticTokens(t *testing.T) {
+	file := path.Join(t.TempDir(), "tokens.yaml")
+	ioutil.WriteFile(file, []byte("tokens:\n  - name: ops-team\n    token: secret\n    roles: [operator, viewer]\n"), 0600)
+
+	a, err := LoadStaticTokens(file)
+	assert.Nil(t, err)
+	p, err := a.Authenticate(context.Background(), "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, &Principal{Name: "ops-team", Roles: []string{RoleOperator, RoleViewer}}, p)
+
+	_, err = a.Authenticate(context.Background(), "secre")
+	assert.Equal(t, ErrUnknownToken, err)
+
+	ioutil.WriteFile(file, []byte("tokens:\n  - name: ops-team\n"), 0600)
+	_, err = LoadStaticTokens(file)
+	assert.NotNil(t, err)
+}
+
+type failingAuthenticator struct{}
+
+func (failingAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	return nil, ErrInvalidToken
+}
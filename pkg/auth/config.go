@@ -0,0 +1,87 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+)
+
+// NewFromConfig returns the Authorizer configured in the 'auth' section, or nil
+// if authentication is disabled. The authenticators are chained in the order
+// static tokens, JWT, TokenReview.
+func NewFromConfig() (*Authorizer, error) {
+	if !viper.GetBool("auth.enabled") {
+		appmgr.Logger.Info("REST API authentication disabled")
+		return nil, nil
+	}
+
+	var chain Chain
+	if file := viper.GetString("auth.static.tokensFile"); file != "" {
+		a, err := LoadStaticTokens(file)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, a)
+	}
+
+	if file := viper.GetString("auth.jwt.jwksFile"); file != "" {
+		keys, err := LoadJWKS(file)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, NewJWTAuthenticator(keys,
+			viper.GetString("auth.jwt.issuer"), viper.GetString("auth.jwt.audience"),
+			viper.GetString("auth.jwt.usernameClaim"), viper.GetString("auth.jwt.rolesClaim")))
+	}
+
+	if viper.GetBool("auth.tokenReview.enabled") {
+		var groupRoles []GroupRoles
+		if err := viper.UnmarshalKey("auth.tokenReview.groups", &groupRoles); err != nil {
+			return nil, err
+		}
+		ttl := time.Duration(viper.GetInt("auth.tokenReview.cacheSeconds")) * time.Second
+		a, err := NewInClusterTokenReview(viper.GetStringSlice("auth.tokenReview.audiences"), groupRoles, ttl)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, a)
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("authentication enabled, but no tokens file, JWKS file or TokenReview configured")
+	}
+
+	var roles map[string]Role
+	if err := viper.UnmarshalKey("auth.roles", &roles); err != nil {
+		return nil, err
+	}
+	policy, err := NewPolicy(viper.GetStringSlice("auth.anonymousOperations"), roles)
+	if err != nil {
+		return nil, err
+	}
+
+	appmgr.Logger.Info("REST API authentication enabled with %d authenticator(s) and %d role(s)", len(chain), len(roles))
+	return &Authorizer{Authenticator: chain, Policy: policy}, nil
+}
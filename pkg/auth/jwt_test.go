@@ -0,0 +1,139 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTAuthenticatesSignedTokens(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keys := loadTestJWKS(t, rsaKey, ecKey)
+	a := NewJWTAuthenticator(keys, "https://idp", "appmgr", "", "")
+
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	claims := map[string]interface{}{"sub": "alice", "roles": []string{RoleOperator}, "iss": "https://idp", "aud": "appmgr", "exp": exp}
+
+	p, err := a.Authenticate(context.Background(), signTestJWT(t, "RS256", "rsa-key", rsaKey, claims))
+	assert.Nil(t, err)
+	assert.Equal(t, &Principal{Name: "alice", Roles: []string{RoleOperator}}, p)
+
+	p, err = a.Authenticate(context.Background(), signTestJWT(t, "ES256", "ec-key", ecKey, claims))
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", p.Name)
+}
+
+func TestJWTRejectsInvalidTokens(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	a := NewJWTAuthenticator(loadTestJWKS(t, rsaKey, nil), "https://idp", "appmgr", "preferred_username", "groups")
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{"preferred_username": "alice", "groups": "viewer operator", "iss": "https://idp",
+			"aud": []string{"other", "appmgr"}, "exp": float64(time.Now().Add(time.Hour).Unix())}
+	}
+	p, err := a.Authenticate(context.Background(), signTestJWT(t, "RS512", "rsa-key", rsaKey, valid()))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{RoleViewer, RoleOperator}, p.Roles)
+
+	cases := map[string]func(map[string]interface{}){
+		"expired":      func(c map[string]interface{}) { c["exp"] = float64(time.Now().Add(-time.Hour).Unix()) },
+		"no expiry":    func(c map[string]interface{}) { delete(c, "exp") },
+		"not yet":      func(c map[string]interface{}) { c["nbf"] = float64(time.Now().Add(time.Hour).Unix()) },
+		"issuer":       func(c map[string]interface{}) { c["iss"] = "https://other" },
+		"audience":     func(c map[string]interface{}) { c["aud"] = "other" },
+		"no user name": func(c map[string]interface{}) { delete(c, "preferred_username") },
+	}
+	for name, change := range cases {
+		claims := valid()
+		change(claims)
+		_, err := a.Authenticate(context.Background(), signTestJWT(t, "RS256", "rsa-key", rsaKey, claims))
+		assert.True(t, errors.Is(err, ErrInvalidToken), name)
+	}
+
+	_, err = a.Authenticate(context.Background(), signTestJWT(t, "RS256", "rsa-key", otherKey, valid()))
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+
+	_, err = a.Authenticate(context.Background(), signTestJWT(t, "RS256", "unknown-key", rsaKey, valid()))
+	assert.Equal(t, ErrUnknownToken, err)
+
+	_, err = a.Authenticate(context.Background(), "static-token")
+	assert.Equal(t, ErrUnknownToken, err)
+}
+
+func loadTestJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) map[string]crypto.PublicKey {
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+
+	var set []jwk
+	if rsaKey != nil {
+		set = append(set, jwk{Kty: "RSA", Kid: "rsa-key", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))})
+	}
+	if ecKey != nil {
+		set = append(set, jwk{Kty: "EC", Kid: "ec-key", Crv: "P-256", X: encode(ecKey.X), Y: encode(ecKey.Y)})
+	}
+	data, _ := json.Marshal(map[string]interface{}{"keys": set})
+	file := path.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, ioutil.WriteFile(file, data, 0644))
+
+	keys, err := LoadJWKS(file)
+	assert.Nil(t, err)
+	return keys
+}
+
+func signTestJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, _ := json.Marshal(jwtHeader{Alg: alg, Kid: kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[alg[2:]]
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		assert.Nil(t, err)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
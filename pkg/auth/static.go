@@ -0,0 +1,97 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+)
+
+// StaticAuthenticator knows a fixed set of tokens, read from a file like
+//
+//	tokens:
+//	  - name: ops-team
+//	    token: 8f0e3c...
+//	    roles: [operator]
+type StaticAuthenticator struct {
+	tokens []staticToken
+}
+
+type staticToken struct {
+	digest    [sha256.Size]byte
+	principal Principal
+}
+
+type tokensFile struct {
+	Tokens []struct {
+		Name  string   `json:"name"`
+		Token string   `json:"token"`
+		Roles []string `json:"roles"`
+	} `json:"tokens"`
+}
+
+// LoadStaticTokens reads the tokens of a YAML file, e.g. mounted from a secret
+func LoadStaticTokens(file string) (*StaticAuthenticator, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var f tokensFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	a := &StaticAuthenticator{}
+	for n, t := range f.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return nil, fmt.Errorf("token %d of %s has no name or no token", n+1, file)
+		}
+		a.Add(t.Name, t.Token, t.Roles...)
+	}
+	if len(a.tokens) == 0 {
+		return nil, errors.New("no tokens in " + file)
+	}
+	return a, nil
+}
+
+// Add makes token authenticate as name with the given roles
+func (a *StaticAuthenticator) Add(name, token string, roles ...string) {
+	a.tokens = append(a.tokens, staticToken{digest: sha256.Sum256([]byte(token)), principal: Principal{Name: name, Roles: roles}})
+}
+
+// Authenticate compares the digests of the tokens in constant time, so that
+// the time taken does not tell how much of a token matched
+func (a *StaticAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	digest := sha256.Sum256([]byte(token))
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(digest[:], t.digest[:]) == 1 {
+			p := t.principal
+			return &p, nil
+		}
+	}
+	return nil, ErrUnknownToken
+}
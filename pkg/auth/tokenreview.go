@@ -0,0 +1,162 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	authclient "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultReviewCacheTTL = time.Minute
+	maxCachedReviews      = 1000
+)
+
+// GroupRoles grants roles to the members of a Kubernetes group, e.g. to the
+// service accounts of the xApp namespace
+type GroupRoles struct {
+	Group string   `json:"group" mapstructure:"group"`
+	Roles []string `json:"roles" mapstructure:"roles"`
+}
+
+// TokenReviewAuthenticator asks the Kubernetes API server who a token belongs
+// to. The answers are cached for ttl, so that a busy caller doesn't cause a
+// review per request.
+type TokenReviewAuthenticator struct {
+	reviews    authclient.TokenReviewInterface
+	audiences  []string
+	groupRoles []GroupRoles
+	ttl        time.Duration
+	now        func() time.Time
+
+	mutex sync.Mutex
+	cache map[[sha256.Size]byte]cachedReview
+}
+
+type cachedReview struct {
+	principal *Principal
+	err       error
+	expires   time.Time
+}
+
+// NewInClusterTokenReview returns a TokenReviewAuthenticator using the in-cluster config
+func NewInClusterTokenReview(audiences []string, groupRoles []GroupRoles, ttl time.Duration) (*TokenReviewAuthenticator, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewTokenReview(clientset.AuthenticationV1().TokenReviews(), audiences, groupRoles, ttl), nil
+}
+
+// NewTokenReview returns a TokenReviewAuthenticator using the given client, e.g. a fake one in tests
+func NewTokenReview(reviews authclient.TokenReviewInterface, audiences []string, groupRoles []GroupRoles, ttl time.Duration) *TokenReviewAuthenticator {
+	if ttl <= 0 {
+		ttl = defaultReviewCacheTTL
+	}
+	return &TokenReviewAuthenticator{
+		reviews:    reviews,
+		audiences:  audiences,
+		groupRoles: groupRoles,
+		ttl:        ttl,
+		now:        time.Now,
+		cache:      make(map[[sha256.Size]byte]cachedReview),
+	}
+}
+
+// Authenticate returns the user of an authenticated token, with the roles of its groups
+func (a *TokenReviewAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	digest := sha256.Sum256([]byte(token))
+	if c, found := a.cached(digest); found {
+		return c.principal, c.err
+	}
+
+	review, err := a.reviews.Create(ctx, &authv1.TokenReview{
+		Spec: authv1.TokenReviewSpec{Token: token, Audiences: a.audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		// The API server may be back soon, so this is not cached
+		return nil, fmt.Errorf("token review failed: %v", err)
+	}
+
+	c := cachedReview{expires: a.now().Add(a.ttl)}
+	if !review.Status.Authenticated {
+		c.err = fmt.Errorf("%w: %s", ErrInvalidToken, review.Status.Error)
+	} else {
+		c.principal = &Principal{Name: review.Status.User.Username, Roles: a.roles(review.Status.User.Groups)}
+	}
+	a.store(digest, c)
+	return c.principal, c.err
+}
+
+func (a *TokenReviewAuthenticator) roles(groups []string) (roles []string) {
+	for _, g := range a.groupRoles {
+		if !containsString(groups, g.Group) {
+			continue
+		}
+		for _, r := range g.Roles {
+			if !containsString(roles, r) {
+				roles = append(roles, r)
+			}
+		}
+	}
+	return
+}
+
+func (a *TokenReviewAuthenticator) cached(digest [sha256.Size]byte) (cachedReview, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	c, found := a.cache[digest]
+	if !found || a.now().After(c.expires) {
+		return cachedReview{}, false
+	}
+	return c, true
+}
+
+func (a *TokenReviewAuthenticator) store(digest [sha256.Size]byte, c cachedReview) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(a.cache) >= maxCachedReviews {
+		now := a.now()
+		for d, old := range a.cache {
+			if now.After(old.expires) {
+				delete(a.cache, d)
+			}
+		}
+		if len(a.cache) >= maxCachedReviews {
+			a.cache = make(map[[sha256.Size]byte]cachedReview)
+		}
+	}
+	a.cache[digest] = c
+}
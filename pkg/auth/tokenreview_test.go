@@ -0,0 +1,96 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestTokenReviewGrantsRolesOfGroups(t *testing.T) {
+	a, reviews := newTestTokenReview(nil)
+
+	p, err := a.Authenticate(context.Background(), "sa-token")
+	assert.Nil(t, err)
+	assert.Equal(t, &Principal{Name: "system:serviceaccount:ricxapp:dummy-xapp", Roles: []string{RoleXapp}}, p)
+
+	_, err = a.Authenticate(context.Background(), "bad-token")
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+	assert.Equal(t, 2, *reviews)
+}
+
+func TestTokenReviewCachesAnswers(t *testing.T) {
+	a, reviews := newTestTokenReview(nil)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	a.Authenticate(context.Background(), "sa-token")
+	a.Authenticate(context.Background(), "sa-token")
+	a.Authenticate(context.Background(), "bad-token")
+	a.Authenticate(context.Background(), "bad-token")
+	assert.Equal(t, 2, *reviews)
+
+	now = now.Add(2 * time.Minute)
+	a.Authenticate(context.Background(), "sa-token")
+	assert.Equal(t, 3, *reviews)
+}
+
+func TestTokenReviewDoesNotCacheErrors(t *testing.T) {
+	a, reviews := newTestTokenReview(errors.New("connection refused"))
+
+	_, err := a.Authenticate(context.Background(), "sa-token")
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrInvalidToken))
+	a.Authenticate(context.Background(), "sa-token")
+	assert.Equal(t, 2, *reviews)
+}
+
+func newTestTokenReview(apiErr error) (*TokenReviewAuthenticator, *int) {
+	reviews := 0
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		if apiErr != nil {
+			return true, nil, apiErr
+		}
+		review := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenReview).DeepCopy()
+		if review.Spec.Token == "sa-token" {
+			review.Status.Authenticated = true
+			review.Status.User = authv1.UserInfo{
+				Username: "system:serviceaccount:ricxapp:dummy-xapp",
+				Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:ricxapp"},
+			}
+		} else {
+			review.Status.Error = "token not found"
+		}
+		return true, review, nil
+	})
+
+	groupRoles := []GroupRoles{{Group: "system:serviceaccounts:ricxapp", Roles: []string{RoleXapp}}}
+	return NewTokenReview(clientset.AuthenticationV1().TokenReviews(), nil, groupRoles, time.Minute), &reviews
+}
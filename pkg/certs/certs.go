@@ -0,0 +1,262 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package certs configures TLS for the REST servers of appmgr, optionally
+// verifying client certificates, and for its calls to xApps and webhook
+// targets. The certificates are read again when their files change.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+)
+
+// Client certificate verification of the servers, set in 'tls.clientAuth'
+const (
+	ClientAuthNone    = "none"
+	ClientAuthRequest = "request"
+	ClientAuthRequire = "require"
+)
+
+var (
+	serverConfig *tls.Config
+	transport    http.RoundTripper = http.DefaultTransport
+	xappScheme                     = "http"
+)
+
+// Init reads the 'tls' section: the server certificate, the CA bundle of the
+// client certificates, and the CA bundle and client certificate of the calls
+// made by appmgr. Without Init the servers and the calls use plain HTTP.
+func Init() (err error) {
+	interval := time.Duration(viper.GetInt("tls.reloadInterval")) * time.Second
+
+	if viper.GetBool("tls.enabled") {
+		if serverConfig, err = newServerConfig(interval); err != nil {
+			return
+		}
+		appmgr.Logger.Info("TLS enabled, client certificates: %s", clientAuthName(serverConfig.ClientAuth))
+	}
+
+	t, err := newTransport(viper.GetString("tls.client.caFile"), viper.GetString("tls.client.certFile"), viper.GetString("tls.client.keyFile"), interval)
+	if err != nil {
+		return
+	}
+	transport = t
+
+	switch scheme := viper.GetString("tls.client.xappScheme"); scheme {
+	case "":
+	case "http", "https":
+		xappScheme = scheme
+	default:
+		return fmt.Errorf("unknown xApp scheme '%s'", scheme)
+	}
+	return nil
+}
+
+// ListenAndServe runs server, with TLS if it is enabled
+func ListenAndServe(server *http.Server) error {
+	if serverConfig == nil {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = serverConfig
+	// The certificates come from the TLS config
+	return server.ListenAndServeTLS("", "")
+}
+
+// Scheme is the scheme of the REST servers of appmgr
+func Scheme() string {
+	if serverConfig == nil {
+		return "http"
+	}
+	return "https"
+}
+
+// Transport is the round tripper of the calls to xApps and webhook targets
+func Transport() http.RoundTripper {
+	return transport
+}
+
+// TransportFor is Transport verifying the certificates of the servers against
+// serverName instead of the host called, e.g. to call pods by their address
+// when the certificate names their service. Empty serverName is Transport.
+func TransportFor(serverName string) http.RoundTripper {
+	if serverName == "" {
+		return transport
+	}
+
+	switch t := transport.(type) {
+	case *reloadingTransport:
+		return &reloadingTransport{store: t.store, serverName: serverName}
+	case *http.Transport:
+		c := t.Clone()
+		if c.TLSClientConfig == nil {
+			c.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		c.TLSClientConfig.ServerName = serverName
+		return c
+	}
+	return transport
+}
+
+// XappURL returns the URL of path at the HTTP endpoint of an xApp
+func XappURL(endpoint, path string) string {
+	return fmt.Sprintf("%s://%s%s", xappScheme, endpoint, path)
+}
+
+func newServerConfig(interval time.Duration) (*tls.Config, error) {
+	certFile, keyFile, caFile := viper.GetString("tls.certFile"), viper.GetString("tls.keyFile"), viper.GetString("tls.clientCAFile")
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("TLS enabled without a certificate and a key file")
+	}
+	store, err := NewStore(certFile, keyFile, caFile, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	clientAuth, err := parseClientAuth(viper.GetString("tls.clientAuth"), caFile != "")
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := parseVersion(viper.GetString("tls.minVersion"))
+	if err != nil {
+		return nil, err
+	}
+	return serverTLSConfig(store, clientAuth, minVersion), nil
+}
+
+// serverTLSConfig hands out the current certificates of store to each handshake
+func serverTLSConfig(store *Store, clientAuth tls.ClientAuthType, minVersion uint16) *tls.Config {
+	base := &tls.Config{
+		MinVersion: minVersion,
+		ClientAuth: clientAuth,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	config := base.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.Certificates = []tls.Certificate{*store.Certificate()}
+		c.ClientCAs = store.CAs()
+		return c, nil
+	}
+	return config
+}
+
+func parseClientAuth(name string, haveCAs bool) (tls.ClientAuthType, error) {
+	switch name {
+	case "":
+		if haveCAs {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.NoClientCert, nil
+	case ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthRequest, ClientAuthRequire:
+		if !haveCAs {
+			return tls.NoClientCert, fmt.Errorf("client certificates verified without 'tls.clientCAFile'")
+		}
+		if name == ClientAuthRequest {
+			return tls.VerifyClientCertIfGiven, nil
+		}
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("unknown client authentication '%s'", name)
+}
+
+func clientAuthName(clientAuth tls.ClientAuthType) string {
+	switch clientAuth {
+	case tls.VerifyClientCertIfGiven:
+		return ClientAuthRequest
+	case tls.RequireAndVerifyClientCert:
+		return ClientAuthRequire
+	}
+	return ClientAuthNone
+}
+
+func parseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version '%s'", version)
+}
+
+// newTransport returns the default transport, verifying the servers with the
+// CA bundle instead of the system roots if caFile is set, and presenting the
+// client certificate if certFile is set
+func newTransport(caFile, certFile, keyFile string, interval time.Duration) (http.RoundTripper, error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return http.DefaultTransport, nil
+	}
+	store, err := NewStore(certFile, keyFile, caFile, interval)
+	if err != nil {
+		return nil, err
+	}
+	return &reloadingTransport{store: store}, nil
+}
+
+// reloadingTransport starts a new transport when the CA bundle changes, since
+// the roots of a transport can't be changed once it is in use. The client
+// certificate is taken from the store on each handshake.
+type reloadingTransport struct {
+	store      *Store
+	serverName string
+
+	mutex     sync.Mutex
+	roots     *x509.CertPool
+	transport *http.Transport
+}
+
+func (t *reloadingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.current().RoundTrip(req)
+}
+
+func (t *reloadingTransport) current() *http.Transport {
+	roots := t.store.CAs()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.transport != nil && roots == t.roots {
+		return t.transport
+	}
+	if t.transport != nil {
+		t.transport.CloseIdleConnections()
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots, ServerName: t.serverName}
+	if t.store.Certificate() != nil {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return t.store.Certificate(), nil
+		}
+	}
+	t.transport = http.DefaultTransport.(*http.Transport).Clone()
+	t.transport.TLSClientConfig = config
+	t.roots = roots
+	return t.transport
+}
@@ -0,0 +1,264 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+)
+
+func TestMain(m *testing.M) {
+	appmgr.Init()
+	appmgr.Logger.SetLevel(0)
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestStoreReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server-1", false)
+	s, err := NewStore(certFile, keyFile, "", time.Minute)
+	assert.Nil(t, err)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	assert.Equal(t, "server-1", commonName(s.Certificate()))
+
+	newCert, newKey := ca.issue(t, dir, "server-2", false)
+	replaceFile(t, newCert, certFile)
+	replaceFile(t, newKey, keyFile)
+	assert.Equal(t, "server-1", commonName(s.Certificate()), "checked once per interval")
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, "server-2", commonName(s.Certificate()))
+
+	assert.Nil(t, ioutil.WriteFile(certFile, []byte("rotation in progress"), 0600))
+	os.Chtimes(certFile, now, now.Add(time.Hour))
+	now = now.Add(time.Minute)
+	assert.Equal(t, "server-2", commonName(s.Certificate()), "broken files keep the old certificate")
+}
+
+func TestNewStoreFails(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := newTestCA(t, "ca").issue(t, dir, "server", false)
+
+	_, err := NewStore(certFile, "", "", 0)
+	assert.NotNil(t, err)
+	_, err = NewStore(certFile, path.Join(dir, "missing.key"), "", 0)
+	assert.NotNil(t, err)
+	_, err = NewStore("", "", certFile+".missing", 0)
+	assert.NotNil(t, err)
+}
+
+func TestServerVerifiesClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server", false)
+	store, err := NewStore(certFile, keyFile, ca.file, 0)
+	assert.Nil(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = serverTLSConfig(store, tls.RequireAndVerifyClientCert, tls.VersionTLS12)
+	server.StartTLS()
+	defer server.Close()
+
+	clientCert, clientKey := ca.issue(t, dir, "client", true)
+	withCert, err := newTransport(ca.file, clientCert, clientKey, 0)
+	assert.Nil(t, err)
+	resp, err := (&http.Client{Transport: withCert}).Get(server.URL)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "client", string(body))
+
+	withoutCert, err := newTransport(ca.file, "", "", 0)
+	assert.Nil(t, err)
+	_, err = (&http.Client{Transport: withoutCert}).Get(server.URL)
+	assert.NotNil(t, err)
+}
+
+func TestTransportReloadsCABundle(t *testing.T) {
+	dir := t.TempDir()
+	oldCA, newCA := newTestCA(t, "old-ca"), newTestCA(t, "new-ca")
+	certFile, keyFile := newCA.issue(t, dir, "server", false)
+	store, _ := NewStore(certFile, keyFile, "", 0)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	server.TLS = serverTLSConfig(store, tls.NoClientCert, tls.VersionTLS12)
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := path.Join(dir, "bundle.crt")
+	replaceFile(t, oldCA.file, caFile)
+	rt, err := newTransport(caFile, "", "", time.Minute)
+	assert.Nil(t, err)
+	now := time.Now()
+	rt.(*reloadingTransport).store.now = func() time.Time { return now }
+	client := &http.Client{Transport: rt}
+
+	_, err = client.Get(server.URL)
+	assert.NotNil(t, err)
+
+	replaceFile(t, newCA.file, caFile)
+	now = now.Add(time.Minute)
+	resp, err := client.Get(server.URL)
+	assert.Nil(t, err)
+	resp.Body.Close()
+}
+
+func TestTransportForVerifiesServerName(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server", false)
+	store, _ := NewStore(certFile, keyFile, "", 0)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	server.TLS = serverTLSConfig(store, tls.NoClientCert, tls.VersionTLS12)
+	server.StartTLS()
+	defer server.Close()
+
+	defer func(t http.RoundTripper) { transport = t }(transport)
+	var err error
+	transport, err = newTransport(ca.file, "", "", 0)
+	assert.Nil(t, err)
+
+	resp, err := (&http.Client{Transport: TransportFor("localhost")}).Get(server.URL)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	_, err = (&http.Client{Transport: TransportFor("appmgr.ricplt")}).Get(server.URL)
+	assert.NotNil(t, err)
+	assert.Equal(t, transport, TransportFor(""))
+}
+
+func TestParseClientAuth(t *testing.T) {
+	clientAuth, err := parseClientAuth("", true)
+	assert.Nil(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, clientAuth)
+
+	clientAuth, err = parseClientAuth("", false)
+	assert.Nil(t, err)
+	assert.Equal(t, tls.NoClientCert, clientAuth)
+
+	clientAuth, err = parseClientAuth(ClientAuthRequest, true)
+	assert.Nil(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, clientAuth)
+
+	_, err = parseClientAuth(ClientAuthRequire, false)
+	assert.NotNil(t, err)
+	_, err = parseClientAuth("always", true)
+	assert.NotNil(t, err)
+}
+
+func TestXappURL(t *testing.T) {
+	assert.Equal(t, "http://service-ricxapp-dummy-xapp-http.ricxapp:8080/ric/v1/config", XappURL("service-ricxapp-dummy-xapp-http.ricxapp:8080", "/ric/v1/config"))
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	ca := &testCA{}
+	ca.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &ca.key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	ca.cert, _ = x509.ParseCertificate(der)
+	ca.file = path.Join(t.TempDir(), name+".crt")
+	writePEM(t, ca.file, "CERTIFICATE", der)
+	return ca
+}
+
+// issue writes a certificate for localhost, or for a client, and its key
+func (ca *testCA) issue(t *testing.T, dir, name string, client bool) (certFile, keyFile string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	if client {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	certFile, keyFile = path.Join(dir, name+".crt"), path.Join(dir, name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+	return
+}
+
+func writePEM(t *testing.T, file, kind string, der []byte) {
+	assert.Nil(t, ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600))
+}
+
+// replaceFile copies from to to, with a later modification time as a rotated secret would have
+func replaceFile(t *testing.T, from, to string) {
+	data, err := ioutil.ReadFile(from)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(to, data, 0600))
+	later := time.Now().Add(time.Duration(len(data)) * time.Millisecond)
+	os.Chtimes(to, later, later)
+}
+
+func commonName(cert *tls.Certificate) string {
+	c, _ := x509.ParseCertificate(cert.Certificate[0])
+	return c.Subject.CommonName
+}
@@ -0,0 +1,153 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// Store keeps a certificate and key pair and a CA bundle read from files, and
+// reads them again when the files change, e.g. when Kubernetes updates the
+// mounted secret. The files are checked at most once per interval, on use,
+// and a change that fails to load leaves the previous certificates in use.
+type Store struct {
+	certFile, keyFile, caFile string
+	interval                  time.Duration
+	now                       func() time.Time
+
+	mutex   sync.Mutex
+	checked time.Time
+	stamp   string
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+}
+
+// NewStore reads the files, of which the key pair or the CA bundle may be left empty
+func NewStore(certFile, keyFile, caFile string, interval time.Duration) (*Store, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("both a certificate and a key file are needed")
+	}
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	s := &Store{certFile: certFile, keyFile: keyFile, caFile: caFile, interval: interval, now: time.Now}
+
+	stamp, err := s.fileStamp()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.stamp, s.checked = stamp, s.now()
+	return s, nil
+}
+
+// Certificate returns the current key pair, nil if the store has none
+func (s *Store) Certificate() *tls.Certificate {
+	s.reload()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.cert
+}
+
+// CAs returns the current CA bundle, nil if the store has none
+func (s *Store) CAs() *x509.CertPool {
+	s.reload()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pool
+}
+
+func (s *Store) reload() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.now().Sub(s.checked) < s.interval {
+		return
+	}
+	s.checked = s.now()
+
+	stamp, err := s.fileStamp()
+	if err != nil || stamp == s.stamp {
+		return
+	}
+	if err := s.load(); err != nil {
+		appmgr.Logger.Error("Reloading certificates failed, keeping the old ones: %v", err)
+		return
+	}
+	s.stamp = stamp
+	appmgr.Logger.Info("Certificates reloaded: %s %s %s", s.certFile, s.keyFile, s.caFile)
+}
+
+// load must be called with the mutex held, or before the store is shared
+func (s *Store) load() error {
+	var cert *tls.Certificate
+	if s.certFile != "" {
+		c, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if s.caFile != "" {
+		data, err := ioutil.ReadFile(s.caFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates in %s", s.caFile)
+		}
+	}
+
+	s.cert, s.pool = cert, pool
+	return nil
+}
+
+// fileStamp tells whether any of the files changed. Stat follows the symlinks
+// through which the secret volumes switch to a new version.
+func (s *Store) fileStamp() (string, error) {
+	stamp := ""
+	for _, file := range []string{s.certFile, s.keyFile, s.caFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf("%d:%d;", info.ModTime().UnixNano(), info.Size())
+	}
+	return stamp, nil
+}
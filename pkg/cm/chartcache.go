@@ -0,0 +1,218 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package cm
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+)
+
+const defaultChartCacheSize = 50
+
+var (
+	defaultChartCache     *ChartCache
+	defaultChartCacheOnce sync.Once
+)
+
+// chartKey identifies a chart version, an empty version is the latest one in the repo
+type chartKey struct {
+	name    string
+	version string
+}
+
+type chartEntry struct {
+	key      chartKey
+	file     string
+	checksum [sha256.Size]byte
+}
+
+// ChartCache keeps the schemas of fetched charts on disk, so that helm fetch runs
+// once per chart version instead of on every validation and status query. The
+// checksum of a cached schema is verified on every read, and the least recently
+// used schemas are dropped once there are more than size of them.
+type ChartCache struct {
+	mutex    sync.Mutex
+	dir      string
+	size     int
+	entries  map[chartKey]*list.Element
+	lru      *list.List
+	fetching map[chartKey]*chartFetch
+}
+
+// chartFetch is a fetch in progress, done is closed once schema and err are set
+type chartFetch struct {
+	done   chan struct{}
+	schema []byte
+	err    error
+}
+
+func NewChartCache(dir string, size int) *ChartCache {
+	if size <= 0 {
+		size = defaultChartCacheSize
+	}
+	return &ChartCache{
+		dir:      dir,
+		size:     size,
+		entries:  make(map[chartKey]*list.Element),
+		lru:      list.New(),
+		fetching: make(map[chartKey]*chartFetch),
+	}
+}
+
+// sharedChartCache returns the cache shared by all CM instances, configured from
+// the 'xapp.chartCache' section
+func sharedChartCache() *ChartCache {
+	defaultChartCacheOnce.Do(func() {
+		dir := viper.GetString("xapp.chartCache.dir")
+		if dir == "" {
+			dir = path.Join(os.TempDir(), "appmgr-chart-cache")
+		}
+		defaultChartCache = NewChartCache(dir, viper.GetInt("xapp.chartCache.size"))
+	})
+	return defaultChartCache
+}
+
+// InvalidateChartCache empties the shared cache. It is called after the helm
+// repo has been added, since the latest version of a chart may have changed.
+func InvalidateChartCache() {
+	sharedChartCache().Purge()
+}
+
+// InvalidateLatestCharts drops the schemas cached for the latest chart versions.
+// It is called after the helm repos have been refreshed, the pinned versions
+// cannot change and are kept.
+func InvalidateLatestCharts() {
+	sharedChartCache().PurgeLatest()
+}
+
+// Schema returns the cached schema of the given chart version. On a miss, or if
+// the cached file doesn't match its checksum, the schema is read with fetch and
+// stored in the cache. The fetch runs without holding the cache, concurrent
+// misses of the same chart version wait for a single fetch.
+func (c *ChartCache) Schema(name, version string, fetch func() ([]byte, error)) ([]byte, error) {
+	key := chartKey{name, version}
+
+	c.mutex.Lock()
+	if e, found := c.entries[key]; found {
+		entry := e.Value.(*chartEntry)
+		if schema, err := ioutil.ReadFile(entry.file); err == nil && sha256.Sum256(schema) == entry.checksum {
+			c.lru.MoveToFront(e)
+			c.mutex.Unlock()
+			return schema, nil
+		}
+		appmgr.Logger.Info("Cached schema of chart '%s' is corrupted, fetching it again", name)
+		c.remove(e)
+	}
+	if f, found := c.fetching[key]; found {
+		c.mutex.Unlock()
+		<-f.done
+		return f.schema, f.err
+	}
+	f := &chartFetch{done: make(chan struct{})}
+	c.fetching[key] = f
+	c.mutex.Unlock()
+
+	f.schema, f.err = fetch()
+
+	c.mutex.Lock()
+	delete(c.fetching, key)
+	if f.err == nil {
+		c.add(key, f.schema)
+	}
+	c.mutex.Unlock()
+	close(f.done)
+
+	return f.schema, f.err
+}
+
+// Purge drops all cached schemas
+func (c *ChartCache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for c.lru.Len() != 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// PurgeLatest drops the schemas cached without a chart version
+func (c *ChartCache) PurgeLatest() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, e := range c.entries {
+		if key.version == "" {
+			c.remove(e)
+		}
+	}
+}
+
+// Len returns the number of cached schemas
+func (c *ChartCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *ChartCache) add(key chartKey, schema []byte) {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		appmgr.Logger.Info("Creating chart cache directory failed: %v", err)
+		return
+	}
+
+	f, err := ioutil.TempFile(c.dir, key.name+"-*.json")
+	if err != nil {
+		appmgr.Logger.Info("Caching schema of chart '%s' failed: %v", key.name, err)
+		return
+	}
+	_, err = f.Write(schema)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		appmgr.Logger.Info("Caching schema of chart '%s' failed: %v", key.name, err)
+		os.Remove(f.Name())
+		return
+	}
+
+	entry := &chartEntry{key: key, file: f.Name(), checksum: sha256.Sum256(schema)}
+	c.entries[key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *ChartCache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*chartEntry)
+	delete(c.entries, entry.key)
+	if err := os.Remove(entry.file); err != nil && !os.IsNotExist(err) {
+		appmgr.Logger.Info("Removing cached schema '%s' failed: %v", entry.file, err)
+	}
+}
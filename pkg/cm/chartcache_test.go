@@ -0,0 +1,179 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package cm
+
+import (
+	"errors"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingFetch returns a fetch function for ChartCache.Schema that counts its calls
+func countingFetch(schema string, calls *int) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		*calls++
+		return []byte(schema), nil
+	}
+}
+
+func TestChartCacheFetchesOnce(t *testing.T) {
+	c := NewChartCache(t.TempDir(), 10)
+	calls := 0
+
+	for i := 0; i < 3; i++ {
+		schema, err := c.Schema("dummy-xapp", "", countingFetch(`{"type": "object"}`, &calls))
+		assert.Nil(t, err)
+		assert.Equal(t, `{"type": "object"}`, string(schema))
+	}
+	assert.Equal(t, 1, calls)
+
+	// Versions are cached separately
+	c.Schema("dummy-xapp", "1.0.0", countingFetch(`{}`, &calls))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestChartCacheFetchesConcurrentMissesOnce(t *testing.T) {
+	c := NewChartCache(t.TempDir(), 10)
+	release := make(chan struct{})
+	var calls int32
+	fetch := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte(`{}`), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			schema, err := c.Schema("dummy-xapp", "1.0.0", fetch)
+			assert.Nil(t, err)
+			assert.Equal(t, `{}`, string(schema))
+		}()
+	}
+
+	// Other charts are served while the fetch is in progress
+	other := 0
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, 10*time.Millisecond)
+	_, err := c.Schema("other-xapp", "", countingFetch(`{}`, &other))
+	assert.Nil(t, err)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestChartCacheDoesNotCacheFailures(t *testing.T) {
+	c := NewChartCache(t.TempDir(), 10)
+
+	_, err := c.Schema("dummy-xapp", "", func() ([]byte, error) { return nil, errors.New("fetch failed") })
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestChartCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewChartCache(t.TempDir(), 2)
+	calls := 0
+
+	c.Schema("a", "", countingFetch(`{}`, &calls))
+	c.Schema("b", "", countingFetch(`{}`, &calls))
+	c.Schema("a", "", countingFetch(`{}`, &calls))
+	c.Schema("c", "", countingFetch(`{}`, &calls))
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 2, c.Len())
+
+	// b was the least recently used one
+	c.Schema("a", "", countingFetch(`{}`, &calls))
+	assert.Equal(t, 3, calls)
+	c.Schema("b", "", countingFetch(`{}`, &calls))
+	assert.Equal(t, 4, calls)
+}
+
+func TestChartCacheRefetchesCorruptedSchema(t *testing.T) {
+	c := NewChartCache(t.TempDir(), 10)
+	calls := 0
+
+	c.Schema("dummy-xapp", "", countingFetch(`{"type": "object"}`, &calls))
+	file := c.entries[chartKey{"dummy-xapp", ""}].Value.(*chartEntry).file
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"type": "string"}`), 0644))
+
+	schema, err := c.Schema("dummy-xapp", "", countingFetch(`{"type": "object"}`, &calls))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"type": "object"}`, string(schema))
+	assert.Equal(t, 2, calls)
+}
+
+func TestChartCachePurge(t *testing.T) {
+	c := NewChartCache(t.TempDir(), 10)
+	calls := 0
+
+	c.Schema("dummy-xapp", "", countingFetch(`{}`, &calls))
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+
+	c.Schema("dummy-xapp", "", countingFetch(`{}`, &calls))
+	assert.Equal(t, 2, calls)
+}
+
+func TestChartCachePurgeLatest(t *testing.T) {
+	c := NewChartCache(t.TempDir(), 10)
+	calls := 0
+
+	c.Schema("dummy-xapp", "", countingFetch(`{}`, &calls))
+	c.Schema("dummy-xapp", "1.0.0", countingFetch(`{}`, &calls))
+	c.PurgeLatest()
+	assert.Equal(t, 1, c.Len())
+
+	c.Schema("dummy-xapp", "1.0.0", countingFetch(`{}`, &calls))
+	assert.Equal(t, 2, calls)
+	c.Schema("dummy-xapp", "", countingFetch(`{}`, &calls))
+	assert.Equal(t, 3, calls)
+}
+
+func TestReadSchemaUsesChartCache(t *testing.T) {
+	c := newHistoryTestCM(t)
+
+	var desc interface{}
+	assert.Nil(t, c.ReadSchema("dummy-xapp", "", &desc))
+	assert.Equal(t, map[string]interface{}{"type": "object"}, desc)
+	assert.Contains(t, caughtHelmExecArgs, "fetch --untar")
+
+	// The second read must come from the cache, without a helm fetch
+	caughtHelmExecArgs = ""
+	assert.Nil(t, c.ReadSchema("dummy-xapp", "", &desc))
+	assert.Equal(t, "", caughtHelmExecArgs)
+	assert.Equal(t, map[string]interface{}{"type": "object"}, desc)
+}
+
+func TestReadSchemaFetchesGivenVersion(t *testing.T) {
+	c := newHistoryTestCM(t)
+
+	var desc interface{}
+	assert.Nil(t, c.ReadSchema("dummy-xapp", "1.2.3", &desc))
+	assert.Contains(t, caughtHelmExecArgs, "--version=1.2.3")
+}
@@ -20,28 +20,49 @@
 package cm
 
 import (
+        "context"
         "encoding/json"
         "errors"
         "fmt"
+        "github.com/go-openapi/strfmt"
         "github.com/spf13/viper"
         "github.com/valyala/fastjson"
         "github.com/xeipuuv/gojsonschema"
+        "go.opentelemetry.io/otel/attribute"
+        "k8s.io/client-go/util/retry"
         "io/ioutil"
         "os"
         "path"
         "regexp"
         "strconv"
         "strings"
+        "sync"
+        "time"
 
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+        "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/util"
 )
 
 var kubeExec = util.KubectlExec
 var helmExec = util.HelmExec
 
-type CM struct{}
+const configFileKey = "config-file.json"
+
+var ErrRevisionMismatch = errors.New("config revision does not match")
+
+// ErrNoSchema is returned by Validate if the xApp has no chart in the helm repo,
+// or its chart has no schema
+var ErrNoSchema = errors.New("no config schema")
+
+type CM struct {
+        backend appmgr.ReleaseBackend
+        kube    appmgr.KubeClient
+        history *ConfigHistory
+        charts  *ChartCache
+        mutex   sync.Mutex
+}
 
 const HELM_VERSION_3 = "3"
 const HELM_VERSION_2 = "2"
@@ -49,7 +70,29 @@ var EnvHelmVersion string = ""
 
 
 func NewCM() *CM {
-        return &CM{}
+        return &CM{charts: sharedChartCache()}
+}
+
+// SetBackend routes chart search and fetch through the given release backend
+// instead of running the helm command line directly
+func (cm *CM) SetBackend(b appmgr.ReleaseBackend) {
+        cm.backend = b
+}
+
+// SetKubeClient routes configmap access through the given kubernetes client
+// instead of running kubectl
+func (cm *CM) SetKubeClient(k appmgr.KubeClient) {
+        cm.kube = k
+}
+
+// SetChartCache replaces the chart cache shared by all CM instances
+func (cm *CM) SetChartCache(c *ChartCache) {
+        cm.charts = c
+}
+
+// SetHistory makes UpdateConfig record every applied configuration as a revision
+func (cm *CM) SetHistory(h *ConfigHistory) {
+        cm.history = h
 }
 
 func (cm *CM) UploadConfigAll() (configList models.AllXappConfig) {
@@ -57,29 +100,32 @@ func (cm *CM) UploadConfigAll() (configList models.AllXappConfig) {
 }
 
 func (cm *CM) UploadConfigElement(Element string) (configList models.AllXappConfig) {
-        namespace := cm.GetNamespace("")
-        for _, name := range cm.GetNamesFromHelmRepo() {
-                var activeConfig interface{}
-                xAppName := name
-                if err := cm.GetConfigmap(xAppName, namespace, &activeConfig); err != nil {
-                        appmgr.Logger.Info("No active configMap found for '%s', ignoring ...", xAppName)
-                        continue
-                }
-
-                if Element != "" {
-                        m := activeConfig.(map[string]interface{})
-                        if m[Element] == nil {
-                                appmgr.Logger.Info("xApp '%s' doesn't have requested element '%s' in config", name, Element)
+        names := cm.GetNamesFromHelmRepo()
+        for _, ns := range cm.GetNamespaces() {
+                namespace := ns
+                for _, name := range names {
+                        var activeConfig interface{}
+                        xAppName := name
+                        if err := cm.GetConfigmap(xAppName, namespace, &activeConfig); err != nil {
+                                appmgr.Logger.Info("No active configMap found for '%s' in '%s', ignoring ...", xAppName, namespace)
                                 continue
                         }
-                        activeConfig = m[Element]
-                }
 
-                c := models.XAppConfig{
-                        Metadata: &models.ConfigMetadata{XappName: &xAppName, Namespace: &namespace},
-                        Config:   activeConfig,
+                        if Element != "" {
+                                m := activeConfig.(map[string]interface{})
+                                if m[Element] == nil {
+                                        appmgr.Logger.Info("xApp '%s' doesn't have requested element '%s' in config", name, Element)
+                                        continue
+                                }
+                                activeConfig = m[Element]
+                        }
+
+                        c := models.XAppConfig{
+                                Metadata: &models.ConfigMetadata{XappName: &xAppName, Namespace: &namespace},
+                                Config:   activeConfig,
+                        }
+                        configList = append(configList, &c)
                 }
-                configList = append(configList, &c)
         }
         return
 }
@@ -93,22 +139,56 @@ func (cm *CM) GetConfigmap(name, namespace string, c *interface{}) (err error) {
         return json.Unmarshal([]byte(cmJson), &c)
 }
 
-func (cm *CM) ReadSchema(name string, desc *interface{}) (err error) {
-        if err = cm.FetchChart(name); err != nil {
+// ReadSchema returns the schema of the given chart version, the latest one if
+// version is empty, from the chart cache if possible
+func (cm *CM) ReadSchema(name, version string, desc *interface{}) (err error) {
+        var schema []byte
+        if cm.charts != nil {
+                schema, err = cm.charts.Schema(name, version, func() ([]byte, error) { return cm.fetchSchema(name, version) })
+        } else {
+                schema, err = cm.fetchSchema(name, version)
+        }
+        if err != nil {
                 return
         }
 
-        tarDir := viper.GetString("xapp.tarDir")
-        err = cm.ReadFile(path.Join(tarDir, name, viper.GetString("xapp.schema")), desc)
+        return json.Unmarshal(schema, desc)
+}
+
+// DeployedChartVersion returns the chart version of the release of an xApp,
+// empty if it is not known
+func (cm *CM) DeployedChartVersion(name, ns string) string {
+        if cm.backend == nil {
+                return ""
+        }
+
+        rel, err := cm.backend.Status(name, cm.GetNamespace(ns))
+        if err != nil || rel == nil {
+                return ""
+        }
+        return rel.Version
+}
+
+// fetchSchema fetches the chart and returns its schema file, if it is valid JSON
+func (cm *CM) fetchSchema(name, version string) ([]byte, error) {
+        dir, err := cm.fetchChartTemp(context.Background(), name, version)
         if err != nil {
-                return
+                return nil, err
         }
+        defer removeChartDir(dir)
 
-        if err = os.RemoveAll(path.Join(tarDir, name)); err != nil {
-                appmgr.Logger.Info("RemoveAll failed: %v", err)
+        schema, err := ioutil.ReadFile(path.Join(dir, name, viper.GetString("xapp.schema")))
+        if err != nil {
+                appmgr.Logger.Info("Reading schema of '%s' failed: %v", name, err)
+                return nil, err
         }
 
-        return
+        var desc interface{}
+        if err = json.Unmarshal(schema, &desc); err != nil {
+                appmgr.Logger.Info("Unmarshalling schema of '%s' failed: %v", name, err)
+                return nil, err
+        }
+        return schema, nil
 }
 
 func (cm *CM) UpdateConfigMap(r models.XAppConfig) (models.ConfigValidationErrors, error) {
@@ -117,6 +197,10 @@ func (cm *CM) UpdateConfigMap(r models.XAppConfig) (models.ConfigValidationError
                 return validationErrors, err
         }
 
+        if cm.kube != nil {
+                return nil, cm.UpdateControls(*r.Metadata.XappName, *r.Metadata.Namespace, r.Config)
+        }
+
         cmContent, err := cm.BuildConfigMap(r)
         if err != nil {
                 return nil, err
@@ -130,6 +214,211 @@ func (cm *CM) UpdateConfigMap(r models.XAppConfig) (models.ConfigValidationError
         return nil, err
 }
 
+// UpdateConfig applies r like UpdateConfigMap and stores the applied config as a new
+// revision. The config in place before the first update is stored as revision 1.
+func (cm *CM) UpdateConfig(r models.XAppConfig, author, description string) (*models.ConfigRevision, models.ConfigValidationErrors, error) {
+        return cm.UpdateConfigIfMatch(r, nil, author, description)
+}
+
+// UpdateConfigIfMatch is UpdateConfig for conditional updates: if ifMatch is given,
+// r is only applied if ifMatch is still the latest revision, ErrRevisionMismatch
+// is returned otherwise. An xApp without any revisions is at revision 0.
+func (cm *CM) UpdateConfigIfMatch(r models.XAppConfig, ifMatch *int64, author, description string) (*models.ConfigRevision, models.ConfigValidationErrors, error) {
+        cm.mutex.Lock()
+        defer cm.mutex.Unlock()
+
+        if err := cm.checkRevision(*r.Metadata.XappName, *r.Metadata.Namespace, ifMatch); err != nil {
+                return nil, nil, err
+        }
+        return cm.updateConfig(r, author, description)
+}
+
+// RecordConfig stores r as a new revision without applying it, for the xApps
+// that get their config pushed. ifMatch is checked like in UpdateConfigIfMatch.
+func (cm *CM) RecordConfig(r models.XAppConfig, ifMatch *int64, author, description string) (*models.ConfigRevision, error) {
+        cm.mutex.Lock()
+        defer cm.mutex.Unlock()
+
+        if err := cm.checkRevision(*r.Metadata.XappName, *r.Metadata.Namespace, ifMatch); err != nil {
+                return nil, err
+        }
+        if cm.history == nil {
+                return nil, nil
+        }
+
+        rev, err := cm.history.Add(*r.Metadata.Namespace, *r.Metadata.XappName, author, description, r.Config)
+        if err != nil {
+                return nil, err
+        }
+        return &rev, nil
+}
+
+// PatchConfig applies a merge patch or a JSON Patch, see ApplyPatch, to the current
+// controls of an xApp. The result goes through the same validation as any update.
+func (cm *CM) PatchConfig(name, ns, patchType string, patch interface{}, ifMatch *int64, author string) (*models.ConfigRevision, models.ConfigValidationErrors, error) {
+        cm.mutex.Lock()
+        defer cm.mutex.Unlock()
+
+        if err := cm.checkRevision(name, ns, ifMatch); err != nil {
+                return nil, nil, err
+        }
+
+        controls, err := cm.readControls(name, ns)
+        if err != nil {
+                return nil, nil, err
+        }
+
+        patched, err := ApplyPatch(patchType, controls, patch)
+        if err != nil {
+                return nil, nil, err
+        }
+
+        r := models.XAppConfig{
+                Metadata: &models.ConfigMetadata{XappName: &name, Namespace: &ns},
+                Config:   patched,
+        }
+        return cm.updateConfig(r, author, fmt.Sprintf("patch (%s)", patchType))
+}
+
+// CheckRevision returns ErrRevisionMismatch if ifMatch is given and is not the
+// latest revision of the xApp
+func (cm *CM) CheckRevision(name, ns string, ifMatch *int64) error {
+        cm.mutex.Lock()
+        defer cm.mutex.Unlock()
+
+        return cm.checkRevision(name, ns, ifMatch)
+}
+
+// checkRevision compares ifMatch, if given, to the latest revision of the xApp
+func (cm *CM) checkRevision(name, ns string, ifMatch *int64) error {
+        if ifMatch == nil {
+                return nil
+        }
+        if cm.history == nil {
+                return errors.New("config history not enabled")
+        }
+
+        revisions, err := cm.history.List(ns, name)
+        if err != nil {
+                return err
+        }
+
+        var current int64
+        if len(revisions) != 0 {
+                current = revisions[len(revisions)-1].Revision
+        }
+        if current != *ifMatch {
+                appmgr.Logger.Info("Config of '%s' is at revision %d, not at %d", name, current, *ifMatch)
+                return ErrRevisionMismatch
+        }
+        return nil
+}
+
+func (cm *CM) updateConfig(r models.XAppConfig, author, description string) (*models.ConfigRevision, models.ConfigValidationErrors, error) {
+        if cm.history == nil {
+                validationErrors, err := cm.UpdateConfigMap(r)
+                return nil, validationErrors, err
+        }
+
+        name, ns := *r.Metadata.XappName, *r.Metadata.Namespace
+        if revisions, err := cm.history.List(ns, name); err == nil && len(revisions) == 0 {
+                if controls, err := cm.readControls(name, ns); err == nil && controls != nil {
+                        cm.history.Add(ns, name, "appmgr", "configuration before the first update", controls)
+                }
+        }
+
+        if validationErrors, err := cm.UpdateConfigMap(r); err != nil {
+                return nil, validationErrors, err
+        }
+
+        // The config is in place even if its revision can't be stored: the applied
+        // state is returned without a revision number
+        rev, err := cm.history.Add(ns, name, author, description, r.Config)
+        if err != nil {
+                appmgr.Logger.Error("Config of '%s' updated, but storing the revision failed: %v", name, err)
+                rev = models.ConfigRevision{
+                        XappName:    name,
+                        Namespace:   ns,
+                        Author:      author,
+                        Description: description,
+                        Timestamp:   strfmt.DateTime(time.Now().UTC()),
+                        Config:      r.Config,
+                }
+        }
+        return &rev, nil, nil
+}
+
+// RollbackConfig applies the config of the given revision again, through the same validation as any update
+func (cm *CM) RollbackConfig(name, ns string, revision int64, author string) (*models.ConfigRevision, models.ConfigValidationErrors, error) {
+        if cm.history == nil {
+                return nil, nil, errors.New("config history not enabled")
+        }
+
+        rev, err := cm.history.Get(ns, name, revision)
+        if err != nil {
+                return nil, nil, err
+        }
+
+        r := models.XAppConfig{
+                Metadata: &models.ConfigMetadata{XappName: &name, Namespace: &ns},
+                Config:   rev.Config,
+        }
+        return cm.UpdateConfig(r, author, fmt.Sprintf("rollback to revision %d", revision))
+}
+
+// History returns the config revision store, nil if not enabled
+func (cm *CM) History() *ConfigHistory {
+        return cm.history
+}
+
+func (cm *CM) readControls(name, ns string) (interface{}, error) {
+        var config interface{}
+        if err := cm.GetConfigmap(name, ns, &config); err != nil {
+                return nil, err
+        }
+
+        if m, ok := config.(map[string]interface{}); ok {
+                return m["controls"], nil
+        }
+        return nil, nil
+}
+
+// DryRun validates r and builds the config file it would result in, without
+// applying it. Schema violations are reported in the result, not as an error.
+func (cm *CM) DryRun(r models.XAppConfig) (*models.ConfigValidationResult, error) {
+        result := &models.ConfigValidationResult{Valid: true, Errors: models.ConfigValidationErrors{}}
+
+        validationErrors, err := cm.Validate(r)
+        if validationErrors != nil {
+                result.Valid = false
+                result.Errors = validationErrors
+        } else if err != nil {
+                return nil, err
+        }
+
+        active, err := cm.ReadConfigmap(*r.Metadata.XappName, *r.Metadata.Namespace)
+        if err != nil {
+                return nil, err
+        }
+
+        cmContent, err := cm.BuildConfigMap(r)
+        if err != nil {
+                return nil, err
+        }
+
+        var activeConfig, newConfig interface{}
+        if err := json.Unmarshal([]byte(active), &activeConfig); err != nil {
+                return nil, err
+        }
+        if err := json.Unmarshal([]byte(cmContent), &newConfig); err != nil {
+                return nil, err
+        }
+
+        result.Config = newConfig
+        result.Changes = diffConfig(activeConfig, newConfig)
+        return result, nil
+}
+
 func (cm *CM) BuildConfigMap(r models.XAppConfig) (string, error) {
         configJson, err := json.Marshal(r.Config)
         if err != nil {
@@ -142,14 +431,61 @@ func (cm *CM) BuildConfigMap(r models.XAppConfig) (string, error) {
                 return "", err
         }
 
+        return cm.SetControls(cmContent, configJson)
+}
+
+func (cm *CM) SetControls(cmContent string, configJson []byte) (string, error) {
         v, err := cm.ParseJson(cmContent)
-        if err == nil {
-                v.Set("controls", fastjson.MustParse(string(configJson)))
-                fmt.Println(v.String())
-                return v.String(), nil
+        if err != nil {
+                return "", err
+        }
+
+        c, err := cm.ParseJson(string(configJson))
+        if err != nil {
+                return "", err
+        }
+
+        v.Set("controls", c)
+        return v.String(), nil
+}
+
+// UpdateControls replaces the controls of the xApp configmap. If the configmap
+// is modified concurrently the update is retried on top of the latest version.
+func (cm *CM) UpdateControls(name, ns string, config interface{}) error {
+        configJson, err := json.Marshal(config)
+        if err != nil {
+                appmgr.Logger.Info("Config marshalling failed: %v", err)
+                return err
         }
 
-        return "", err
+        return cm.modifyConfigFile(name, ns, func(content string) (string, error) {
+                return cm.SetControls(content, configJson)
+        })
+}
+
+func (cm *CM) modifyConfigFile(name, ns string, modify func(content string) (string, error)) error {
+        return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+                c, err := cm.kube.GetConfigMap(cm.configMapObjectName(name, ns), ns)
+                if err != nil {
+                        return err
+                }
+
+                content, err := modify(c.Data[configFileKey])
+                if err != nil {
+                        return err
+                }
+
+                if c.Data == nil {
+                        c.Data = map[string]string{}
+                }
+                c.Data[configFileKey] = content
+
+                _, err = cm.kube.UpdateConfigMap(c)
+                if err != nil {
+                        appmgr.Logger.Info("Updating configmap of '%s' failed: %v", name, err)
+                }
+                return err
+        })
 }
 
 func (cm *CM) ParseJson(dsContent string) (*fastjson.Value, error) {
@@ -194,12 +530,30 @@ func (cm *CM) ReadFile(name string, data interface{}) (err error) {
 }
 
 func (cm *CM) ReadConfigmap(name string, ns string) (string, error) {
+        if cm.kube != nil {
+                c, err := cm.kube.GetConfigMap(cm.configMapObjectName(name, ns), ns)
+                if err != nil {
+                        return "", err
+                }
+                return c.Data[configFileKey], nil
+        }
+
         args := fmt.Sprintf("get configmap -o jsonpath='{.data.config-file\\.json}' -n %s %s", ns, cm.GetConfigMapName(name, ns))
         out, err := kubeExec(args)
         return string(out), err
 }
 
 func (cm *CM) ReplaceConfigMap(name, ns string) error {
+        if cm.kube != nil {
+                content, err := ioutil.ReadFile(viper.GetString("xapp.tmpConfig"))
+                if err != nil {
+                        return err
+                }
+                return cm.modifyConfigFile(name, ns, func(string) (string, error) {
+                        return string(content), nil
+                })
+        }
+
         cmd := " create configmap -n %s %s --from-file=%s -o json --dry-run | kubectl replace -f -"
         args := fmt.Sprintf(cmd, ns, cm.GetConfigMapName(name, ns), viper.GetString("xapp.tmpConfig"))
         _, err := kubeExec(args)
@@ -207,28 +561,91 @@ func (cm *CM) ReplaceConfigMap(name, ns string) error {
 }
 
 func (cm *CM) FetchChart(name string) (err error) {
+        return cm.fetchChartVersion(context.Background(), name, "", viper.GetString("xapp.tarDir"))
+}
+
+// fetchChartTemp fetches the chart into a new directory under xapp.tarDir, so
+// that concurrent fetches of the same chart don't overwrite each other's files.
+// The caller removes the directory with removeChartDir.
+func (cm *CM) fetchChartTemp(ctx context.Context, name, version string) (dir string, err error) {
         tarDir := viper.GetString("xapp.tarDir")
+        if err = os.MkdirAll(tarDir, 0755); err != nil {
+                return
+        }
+        if dir, err = ioutil.TempDir(tarDir, name+"-"); err != nil {
+                return
+        }
+
+        if err = cm.fetchChartVersion(ctx, name, version, dir); err != nil {
+                removeChartDir(dir)
+                return "", err
+        }
+        return
+}
+
+func removeChartDir(dir string) {
+        if err := os.RemoveAll(dir); err != nil {
+                appmgr.Logger.Info("RemoveAll failed: %v", err)
+        }
+}
+
+func (cm *CM) fetchChartVersion(ctx context.Context, name, version, dir string) (err error) {
+        if cm.backend != nil {
+                return cm.backend.Fetch(name, version, dir)
+        }
+
         repo := viper.GetString("helm.repo-name")
-        fetchArgs := fmt.Sprintf("--untar --untardir %s %s/%s", tarDir, repo, name)
+        fetchArgs := fmt.Sprintf("--untar --untardir %s %s/%s", dir, repo, name)
+        if version != "" {
+                fetchArgs = fmt.Sprintf("%s --version=%s", fetchArgs, version)
+        }
 
-        _, err = helmExec(strings.Join([]string{"fetch ", fetchArgs}, ""))
+        _, err = tracing.Exec(ctx, "helm", strings.Join([]string{"fetch ", fetchArgs}, ""), helmExec)
         return
 }
 
-func (cm *CM) GetRtmData(name string) (msgs appmgr.RtmData) {
-        appmgr.Logger.Info("Fetching RT data for xApp=%s", name)
+func (cm *CM) GetRtmData(name, ns string) (msgs appmgr.RtmData) {
+        appmgr.Logger.Info("Fetching RT data for xApp=%s namespace=%s", name, ns)
 
-        ns := cm.GetNamespace("")
-        args := fmt.Sprintf("get configmap -o jsonpath='{.data.config-file\\.json}' -n %s %s", ns, cm.GetConfigMapName(name, ns))
-        out, err := kubeExec(args)
+        ns = cm.GetNamespace(ns)
+        out, err := cm.ReadConfigmap(name, ns)
         if err != nil {
                 return
         }
 
-        var p fastjson.Parser
-        v, err := p.Parse(string(out))
+        msgs, err = ParseRtmData(out)
         if err != nil {
                 appmgr.Logger.Info("fastjson.Parser for '%s' failed: %v", name, err)
+        }
+        return
+}
+
+// GetChartRtmData returns the RMR messages of the config file in the given chart
+// version, i.e. the messages of the xApp once it is deployed
+func (cm *CM) GetChartRtmData(ctx context.Context, name, version string) (msgs appmgr.RtmData, err error) {
+        ctx, span := tracing.Start(ctx, "cm.chartRtmData", attribute.String("helm.chart", name), attribute.String("helm.version", version))
+        defer func() { tracing.End(span, err) }()
+
+        dir, err := cm.fetchChartTemp(ctx, name, version)
+        if err != nil {
+                return
+        }
+        defer removeChartDir(dir)
+
+        content, err := ioutil.ReadFile(path.Join(dir, name, viper.GetString("xapp.config")))
+        if err != nil {
+                appmgr.Logger.Info("Reading config file of chart '%s' failed: %v", name, err)
+                return
+        }
+        return ParseRtmData(string(content))
+}
+
+// ParseRtmData reads the RMR messages and policies of an xApp config file, in
+// either the 'rmr' or the newer 'messaging' format
+func ParseRtmData(content string) (msgs appmgr.RtmData, err error) {
+        var p fastjson.Parser
+        v, err := p.Parse(content)
+        if err != nil {
                 return
         }
 
@@ -271,6 +688,10 @@ func (cm *CM) GetConfigMapName(xappName, namespace string) string {
         return " configmap-" + namespace + "-" + xappName + "-appconfig"
 }
 
+func (cm *CM) configMapObjectName(xappName, namespace string) string {
+        return strings.TrimSpace(cm.GetConfigMapName(xappName, namespace))
+}
+
 func (cm *CM) GetNamespace(ns string) string {
         if ns != "" {
                 return ns
@@ -283,7 +704,50 @@ func (cm *CM) GetNamespace(ns string) string {
         return ns
 }
 
+// GetNamespaces returns the namespaces managed by appmgr, the default one first
+func (cm *CM) GetNamespaces() (namespaces []string) {
+        namespaces = append(namespaces, cm.GetNamespace(""))
+        for _, ns := range viper.GetStringSlice("xapp.namespaces") {
+                if ns = strings.TrimSpace(ns); ns != "" && !containsString(namespaces, ns) {
+                        namespaces = append(namespaces, ns)
+                }
+        }
+        return
+}
+
+// ValidateNamespace resolves an empty namespace to the default one, and
+// rejects namespaces appmgr hasn't been configured to manage
+func (cm *CM) ValidateNamespace(ns string) (string, error) {
+        ns = cm.GetNamespace(ns)
+        if !containsString(cm.GetNamespaces(), ns) {
+                return "", fmt.Errorf("namespace '%s' is not managed by appmgr", ns)
+        }
+        return ns, nil
+}
+
+func containsString(list []string, s string) bool {
+        for _, v := range list {
+                if v == s {
+                        return true
+                }
+        }
+        return false
+}
+
 func (cm *CM) GetNamesFromHelmRepo() (names []string) {
+        names, err := cm.searchHelmRepo()
+        if err != nil {
+                appmgr.Logger.Info("Searching helm repo failed: %v", err)
+                return nil
+        }
+        return names
+}
+
+func (cm *CM) searchHelmRepo() (names []string, err error) {
+        if cm.backend != nil {
+                return cm.backend.Search()
+        }
+
         rname := viper.GetString("helm.repo-name")
 
         var cmdArgs string = ""
@@ -298,8 +762,14 @@ func (cm *CM) GetNamesFromHelmRepo() (names []string) {
                 return
         }
 
+        return cm.ParseHelmSearch(string(out)), nil
+}
+
+func (cm *CM) ParseHelmSearch(out string) (names []string) {
+        rname := viper.GetString("helm.repo-name")
+
         re := regexp.MustCompile(rname + `/.*`)
-        result := re.FindAllStringSubmatch(string(out), -1)
+        result := re.FindAllStringSubmatch(out, -1)
         if result != nil {
                 var tmp string
                 for _, v := range result {
@@ -312,14 +782,28 @@ func (cm *CM) GetNamesFromHelmRepo() (names []string) {
 
 func (cm *CM) Validate(req models.XAppConfig) (errList models.ConfigValidationErrors, err error) {
         var desc interface{}
-        err = cm.ReadSchema(*req.Metadata.XappName, &desc)
+        version := cm.DeployedChartVersion(*req.Metadata.XappName, *req.Metadata.Namespace)
+        err = cm.ReadSchema(*req.Metadata.XappName, version, &desc)
         if err != nil {
                 appmgr.Logger.Info("No schema file found for '%s', aborting ...", *req.Metadata.XappName)
+                if cm.hasNoSchema(*req.Metadata.XappName, err) {
+                        err = fmt.Errorf("%w: %v", ErrNoSchema, err)
+                }
                 return
         }
         return cm.doValidate(desc, req.Config)
 }
 
+// hasNoSchema tells whether reading the schema of an xApp failed with err because
+// there is none, rather than because the helm repo could not be reached
+func (cm *CM) hasNoSchema(name string, err error) bool {
+        if errors.Is(err, os.ErrNotExist) {
+                return true
+        }
+        names, err := cm.searchHelmRepo()
+        return err == nil && !containsString(names, name)
+}
+
 func (cm *CM) doValidate(schema, cfg interface{}) (errList models.ConfigValidationErrors, err error) {
         schemaLoader := gojsonschema.NewGoLoader(schema)
         documentLoader := gojsonschema.NewGoLoader(cfg)
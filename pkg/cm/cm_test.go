@@ -20,13 +20,23 @@
 package cm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/util"
@@ -34,7 +44,7 @@ import (
 
 const (
 	expectedHelmSearchCmd = "search helm-repo"
-	expectedHelmFetchCmd  = `fetch --untar --untardir /tmp helm-repo/dummy-xapp`
+	expectedHelmFetchCmd  = `^fetch --untar --untardir /tmp/dummy-xapp-[0-9]+ helm-repo/dummy-xapp$`
 )
 
 var caughtKubeExecArgs []string
@@ -44,6 +54,9 @@ var caughtHelmExecArgs string
 var helmExecRetOut string
 var helmExecRetErr error
 
+// testChartRepo holds the charts that the mocked helm fetch untars, if set
+var testChartRepo string
+
 var expectedKubectlGetCmd []string = []string{
 	`get configmap -o jsonpath='{.data.config-file\.json}' -n ricxapp  configmap-ricxapp-anr-appconfig`,
 	`get configmap -o jsonpath='{.data.config-file\.json}' -n ricxapp  configmap-ricxapp-appmgr-appconfig`,
@@ -213,7 +226,7 @@ func (cm *MockedConfigMapper) FetchChart(name string) (err error) {
 	return
 }
 
-func (cm *MockedConfigMapper) GetRtmData(name string) (msgs appmgr.RtmData) {
+func (cm *MockedConfigMapper) GetRtmData(name, ns string) (msgs appmgr.RtmData) {
 	return
 }
 
@@ -221,6 +234,14 @@ func (cm *MockedConfigMapper) GetNamespace(ns string) (n string) {
 	return
 }
 
+func (cm *MockedConfigMapper) GetNamespaces() (namespaces []string) {
+	return
+}
+
+func (cm *MockedConfigMapper) ValidateNamespace(ns string) (n string, err error) {
+	return
+}
+
 func (cm *MockedConfigMapper) GetNamesFromHelmRepo() (names []string) {
 	return
 }
@@ -376,7 +397,7 @@ func TestGetRtmDataSuccess(t *testing.T) {
 	//Fake 'kubectl get configmap' success
 	kubeExecRetOut = kubectlConfigmapOutput
 
-	result := NewCM().GetRtmData("dummy-xapp")
+	result := NewCM().GetRtmData("dummy-xapp", "ricxapp")
 	if !reflect.DeepEqual(result, expectedMsgs) {
 		t.Errorf("GetRtmData failed: expected: %v, got: %v", expectedMsgs, result)
 	}
@@ -400,7 +421,7 @@ func TestGetRtmDataNewSuccess(t *testing.T) {
 	//Fake 'kubectl get configmap' success
 	kubeExecRetOut = kubectlNewConfigmapOutput
 
-	result := NewCM().GetRtmData("dummy-xapp")
+	result := NewCM().GetRtmData("dummy-xapp", "ricxapp")
 	if !reflect.DeepEqual(result, expectedMsgs) {
 		t.Errorf("GetRtmData failed: expected: %v, got: %v", expectedMsgs, result)
 	}
@@ -417,7 +438,7 @@ func TestGetRtmDataReturnsNoDataIfConfigmapGetFails(t *testing.T) {
 	//Fake 'kubectl get configmap' failure
 	kubeExecRetErr = errors.New("some error")
 
-	result := NewCM().GetRtmData("dummy-xapp")
+	result := NewCM().GetRtmData("dummy-xapp", "ricxapp")
 	if !reflect.DeepEqual(result, expectedMsgs) {
 		t.Errorf("GetRtmData failed: expected: %v, got: %v", expectedMsgs, result)
 	}
@@ -430,7 +451,7 @@ func TestGetRtmDataReturnsNoDataIfJsonParseFails(t *testing.T) {
 	kubeExec = mockedKubeExec
 	//Fake 'kubectl get configmap' to return nothing what will cause JSON parse failure
 
-	result := NewCM().GetRtmData("dummy-xapp")
+	result := NewCM().GetRtmData("dummy-xapp", "ricxapp")
 	if !reflect.DeepEqual(result, expectedMsgs) {
 		t.Errorf("GetRtmData failed: expected: %v, got: %v", expectedMsgs, result)
 	}
@@ -447,6 +468,10 @@ func TestHelmNamespace(t *testing.T) {
 }
 
 func TestFetchChartFails(t *testing.T) {
+	defer func() { resetHelmExecMock() }()
+	helmExec = mockedHelmExec
+	helmExecRetErr = errors.New("some error")
+
 	if NewCM().FetchChart("dummy-xapp") == nil {
 		t.Errorf("TestFetchChart failed!")
 	}
@@ -473,6 +498,31 @@ func TestGetNamespaceReturnsConfiguredNamespaceName(t *testing.T) {
 	}
 }
 
+func TestGetNamespacesReturnsDefaultNamespaceFirst(t *testing.T) {
+	viper.Set("xapp.namespaces", []string{"trialxapp", "ricxapp", " ", "trialxapp"})
+	defer viper.Set("xapp.namespaces", nil)
+
+	expected := []string{"ricxapp", "trialxapp"}
+	if ns := NewCM().GetNamespaces(); !reflect.DeepEqual(ns, expected) {
+		t.Errorf("GetNamespaces failed: expected: %v, got: %v", expected, ns)
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	viper.Set("xapp.namespaces", []string{"trialxapp"})
+	defer viper.Set("xapp.namespaces", nil)
+
+	if ns, err := NewCM().ValidateNamespace(""); err != nil || ns != "ricxapp" {
+		t.Errorf("ValidateNamespace failed: %v -> %s", err, ns)
+	}
+	if ns, err := NewCM().ValidateNamespace("trialxapp"); err != nil || ns != "trialxapp" {
+		t.Errorf("ValidateNamespace failed: %v -> %s", err, ns)
+	}
+	if _, err := NewCM().ValidateNamespace("kube-system"); err == nil {
+		t.Errorf("ValidateNamespace should fail but it didn't")
+	}
+}
+
 func TestGetNamesFromHelmRepoSuccess(t *testing.T) {
 	expectedResult := []string{"anr", "appmgr", "dualco", "reporter", "uemgr"}
 
@@ -614,7 +664,7 @@ func TestUpdateConfigMapReturnsErrorIfSchemaFileIsMissing(t *testing.T) {
 	if err == nil {
 		t.Errorf("UpdateConfigMap failed: %v -> %v", err, validationErrors)
 	}
-	if caughtHelmExecArgs != expectedHelmFetchCmd {
+	if !regexp.MustCompile(expectedHelmFetchCmd).MatchString(caughtHelmExecArgs) {
 		t.Errorf("UpdateConfigMap failed: expected: %v, got: %v", expectedHelmFetchCmd, caughtHelmExecArgs)
 	}
 }
@@ -684,6 +734,74 @@ func TestReadFileReturnsErrorIfJsonUnmarshalFails(t *testing.T) {
 	}
 }
 
+func TestReadConfigmapWithKubeClientSuccess(t *testing.T) {
+	c := NewCM()
+	c.SetKubeClient(NewKubeClientForClientset(fake.NewSimpleClientset(newTestConfigMap(cfgData))))
+
+	content, err := c.ReadConfigmap("dummy-xapp", "ricxapp")
+	if err != nil || content != cfgData {
+		t.Errorf("ReadConfigmap failed: %v -> %v", err, content)
+	}
+
+	if _, err := c.ReadConfigmap("not-existing-xapp", "ricxapp"); err == nil {
+		t.Errorf("ReadConfigmap should fail but it didn't")
+	}
+}
+
+func TestGetRtmDataWithKubeClientSuccess(t *testing.T) {
+	expectedMsgs := appmgr.RtmData{
+		TxMessages: []string{"RIC_X2_LOAD_INFORMATION"},
+		RxMessages: []string{"RIC_X2_LOAD_INFORMATION"},
+		Policies:   []int64{11, 22, 33},
+	}
+
+	c := NewCM()
+	c.SetKubeClient(NewKubeClientForClientset(fake.NewSimpleClientset(newTestConfigMap(kubectlConfigmapOutput))))
+
+	result := c.GetRtmData("dummy-xapp", "ricxapp")
+	if !reflect.DeepEqual(result, expectedMsgs) {
+		t.Errorf("GetRtmData failed: expected: %v, got: %v", expectedMsgs, result)
+	}
+}
+
+func TestUpdateControlsWithKubeClientSuccess(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestConfigMap(kubectlNewConfigmapOutput))
+	c := NewCM()
+	c.SetKubeClient(NewKubeClientForClientset(clientset))
+
+	if err := c.UpdateControls("dummy-xapp", "ricxapp", map[string]interface{}{"active": false}); err != nil {
+		t.Errorf("UpdateControls failed: %v", err)
+	}
+
+	var cfg map[string]interface{}
+	content, _ := c.ReadConfigmap("dummy-xapp", "ricxapp")
+	if err := json.Unmarshal([]byte(content), &cfg); err != nil {
+		t.Errorf("UpdateControls left invalid content: %v", err)
+	}
+	if !reflect.DeepEqual(cfg["controls"], map[string]interface{}{"active": false}) {
+		t.Errorf("UpdateControls failed: got %v", cfg["controls"])
+	}
+	if cfg["name"] != "ueec" {
+		t.Errorf("UpdateControls should keep the rest of the config, got %v", cfg)
+	}
+}
+
+func TestUpdateControlsWithKubeClientFailsIfConfigmapIsMissing(t *testing.T) {
+	c := NewCM()
+	c.SetKubeClient(NewKubeClientForClientset(fake.NewSimpleClientset()))
+
+	if err := c.UpdateControls("dummy-xapp", "ricxapp", map[string]interface{}{}); err == nil {
+		t.Errorf("UpdateControls should fail but it didn't")
+	}
+}
+
+func newTestConfigMap(content string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "configmap-ricxapp-dummy-xapp-appconfig", Namespace: "ricxapp"},
+		Data:       map[string]string{"config-file.json": content},
+	}
+}
+
 func mockedKubeExec(args string) (out []byte, err error) {
 	caughtKubeExecArgs = append(caughtKubeExecArgs, args)
 	return []byte(kubeExecRetOut), kubeExecRetErr
@@ -698,12 +816,116 @@ func resetKubeExecMock() {
 
 func mockedHelmExec(args string) (out []byte, err error) {
 	caughtHelmExecArgs = args
+	if helmExecRetErr == nil && testChartRepo != "" && strings.HasPrefix(args, "fetch ") {
+		untarChart(args)
+	}
 	return []byte(helmExecRetOut), helmExecRetErr
 }
 
+// untarChart copies the chart of a 'fetch --untar --untardir <dir> <repo>/<name>'
+// from testChartRepo into the untar directory
+func untarChart(args string) {
+	fields := strings.Fields(args)
+	dir, name := fields[3], path.Base(fields[4])
+	src := path.Join(testChartRepo, name)
+	filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		dst := path.Join(dir, name, strings.TrimPrefix(file, src))
+		os.MkdirAll(path.Dir(dst), 0755)
+		content, _ := ioutil.ReadFile(file)
+		return ioutil.WriteFile(dst, content, 0644)
+	})
+}
+
 func resetHelmExecMock() {
 	helmExec = util.HelmExec
 	caughtHelmExecArgs = ""
 	helmExecRetOut = ""
 	helmExecRetErr = nil
 }
+
+func TestDryRunReturnsChangesWithoutApplying(t *testing.T) {
+	c := newHistoryTestCM(t)
+
+	result, err := c.DryRun(newTestXAppConfig(map[string]interface{}{"active": false}))
+	if err != nil || !result.Valid || len(result.Errors) != 0 {
+		t.Fatalf("DryRun failed: %v -> %v", err, result)
+	}
+	if len(result.Changes) != 1 || *result.Changes[0].Path != "/controls/active" || *result.Changes[0].Op != models.ConfigChangeOpChanged {
+		t.Errorf("DryRun returned unexpected changes: %v", result.Changes)
+	}
+	if result.Config.(map[string]interface{})["name"] != "ueec" {
+		t.Errorf("DryRun should return the whole config file, got %v", result.Config)
+	}
+
+	content, _ := c.ReadConfigmap("dummy-xapp", "ricxapp")
+	if content != `{"name": "ueec", "controls": {"active": true}}` {
+		t.Errorf("DryRun modified the configmap: %v", content)
+	}
+	if revisions, _ := c.History().List("ricxapp", "dummy-xapp"); len(revisions) != 0 {
+		t.Errorf("DryRun stored a revision: %v", revisions)
+	}
+}
+
+func TestDryRunReportsValidationErrors(t *testing.T) {
+	c := newHistoryTestCM(t)
+	file := path.Join(testChartRepo, "dummy-xapp", viper.GetString("xapp.schema"))
+	ioutil.WriteFile(file, []byte(`{"type": "object", "required": ["threshold"]}`), 0644)
+
+	result, err := c.DryRun(newTestXAppConfig(map[string]interface{}{"active": false}))
+	if err != nil || result.Valid || len(result.Errors) != 1 {
+		t.Errorf("DryRun should report a validation error: %v -> %v", err, result)
+	}
+}
+
+func TestGetChartRtmDataReadsConfigOfChartVersion(t *testing.T) {
+	newHistoryTestCM(t)
+	file := path.Join(testChartRepo, "dummy-xapp", viper.GetString("xapp.config"))
+	os.MkdirAll(path.Dir(file), 0755)
+	ioutil.WriteFile(file, []byte(`{"rmr": {"txMessages": ["RIC_SUB_REQ"], "rxMessages": ["RIC_SUB_RESP"], "policies": [20000]}}`), 0644)
+
+	expectedMsgs := appmgr.RtmData{
+		TxMessages: []string{"RIC_SUB_REQ"},
+		RxMessages: []string{"RIC_SUB_RESP"},
+		Policies:   []int64{20000},
+	}
+	result, err := NewCM().GetChartRtmData(context.Background(), "dummy-xapp", "1.2.3")
+	if err != nil || !reflect.DeepEqual(result, expectedMsgs) {
+		t.Errorf("GetChartRtmData failed: expected: %v, got: %v, %v", expectedMsgs, result, err)
+	}
+	if !strings.Contains(caughtHelmExecArgs, "--version=1.2.3") {
+		t.Errorf("GetChartRtmData should fetch the given version, got: '%v'", caughtHelmExecArgs)
+	}
+	if files, _ := ioutil.ReadDir(viper.GetString("xapp.tarDir")); len(files) != 0 {
+		t.Errorf("GetChartRtmData should remove the fetched chart")
+	}
+}
+
+func TestValidateReportsNoSchemaIfChartIsNotInRepo(t *testing.T) {
+	c := newHistoryTestCM(t)
+	helmExec = func(args string) ([]byte, error) {
+		if strings.HasPrefix(args, "search") {
+			return []byte(helmSearchOutput), nil
+		}
+		return nil, errors.New("chart not found")
+	}
+
+	_, err := c.Validate(newTestXAppConfig(map[string]interface{}{"active": false}))
+	if !errors.Is(err, ErrNoSchema) {
+		t.Errorf("Validate should report a missing schema, got: %v", err)
+	}
+}
+
+func TestValidateFailsIfHelmRepoIsDown(t *testing.T) {
+	c := newHistoryTestCM(t)
+	helmExec = func(args string) ([]byte, error) {
+		return nil, errors.New("repo unreachable")
+	}
+
+	_, err := c.Validate(newTestXAppConfig(map[string]interface{}{"active": false}))
+	if err == nil || errors.Is(err, ErrNoSchema) {
+		t.Errorf("Validate should fail without reporting a missing schema, got: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package cm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sdl "gerrit.o-ran-sc.org/r/ric-plt/sdlgo"
+	"github.com/go-openapi/strfmt"
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/metrics"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
+)
+
+// To keep the config revisions under their own namespace in a DB
+const (
+	configHistorySdlNs  = "appmgr-confighistory"
+	defaultMaxRevisions = 50
+)
+
+var ErrRevisionNotFound = errors.New("config revision not found")
+
+type iSdl interface {
+	Set(ns string, pairs ...interface{}) error
+	Get(ns string, keys []string) (map[string]interface{}, error)
+	GetAll(ns string) ([]string, error)
+	Remove(ns string, keys []string) error
+}
+
+// ConfigHistory keeps numbered revisions of the controls of each xApp. The
+// oldest revisions are dropped once there are more than maxRevisions.
+type ConfigHistory struct {
+	mutex        sync.Mutex
+	db           iSdl
+	maxRevisions int
+	now          func() time.Time
+}
+
+func NewConfigHistory() *ConfigHistory {
+	return createConfigHistory(metrics.InstrumentSdl(tracing.InstrumentSdl(sdl.NewSyncStorage())))
+}
+
+func createConfigHistory(sdlInst iSdl) *ConfigHistory {
+	h := &ConfigHistory{
+		db:           sdlInst,
+		maxRevisions: viper.GetInt("xapp.configHistory.maxRevisions"),
+		now:          time.Now,
+	}
+	if h.maxRevisions <= 0 {
+		h.maxRevisions = defaultMaxRevisions
+	}
+	return h
+}
+
+func revisionPrefix(namespace, name string) string {
+	return fmt.Sprintf("%s:%s:", namespace, name)
+}
+
+// Revision numbers are zero padded so that the keys sort in revision order
+func revisionKey(namespace, name string, revision int64) string {
+	return fmt.Sprintf("%s%010d", revisionPrefix(namespace, name), revision)
+}
+
+// Add stores config as the next revision of the given xApp
+func (h *ConfigHistory) Add(namespace, name, author, description string, config interface{}) (models.ConfigRevision, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	keys, err := h.keys(namespace, name)
+	if err != nil {
+		return models.ConfigRevision{}, err
+	}
+
+	var revision int64 = 1
+	if len(keys) != 0 {
+		last, err := h.get(keys[len(keys)-1])
+		if err != nil {
+			return models.ConfigRevision{}, err
+		}
+		revision = last.Revision + 1
+	}
+
+	rev := models.ConfigRevision{
+		Revision:    revision,
+		XappName:    name,
+		Namespace:   namespace,
+		Author:      author,
+		Description: description,
+		Timestamp:   strfmt.DateTime(h.now().UTC()),
+		Config:      config,
+	}
+	data, err := json.Marshal(rev)
+	if err != nil {
+		appmgr.Logger.Error("json.marshal failed: %v ", err.Error())
+		return models.ConfigRevision{}, err
+	}
+
+	if err := h.db.Set(configHistorySdlNs, revisionKey(namespace, name, revision), data); err != nil {
+		appmgr.Logger.Error("DB.session.Set failed: %v ", err.Error())
+		return models.ConfigRevision{}, err
+	}
+	appmgr.Logger.Info("Stored config revision %d of %s/%s by '%s'", revision, namespace, name, author)
+
+	if excess := len(keys) + 1 - h.maxRevisions; excess > 0 {
+		if err := h.db.Remove(configHistorySdlNs, keys[:excess]); err != nil {
+			appmgr.Logger.Error("DB.session.Remove failed: %v ", err.Error())
+		}
+	}
+	return rev, nil
+}
+
+// List returns the revisions of the given xApp, oldest first
+func (h *ConfigHistory) List(namespace, name string) (models.ConfigRevisionList, error) {
+	revisions := models.ConfigRevisionList{}
+
+	keys, err := h.keys(namespace, name)
+	if err != nil || len(keys) == 0 {
+		return revisions, err
+	}
+
+	values, err := h.db.Get(configHistorySdlNs, keys)
+	if err != nil {
+		appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
+		return revisions, err
+	}
+
+	for _, key := range keys {
+		rev, err := parseRevision(values[key])
+		if err != nil {
+			appmgr.Logger.Error("Skipping invalid config revision '%s': %v", key, err)
+			continue
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// Get returns the given revision of an xApp
+func (h *ConfigHistory) Get(namespace, name string, revision int64) (*models.ConfigRevision, error) {
+	return h.get(revisionKey(namespace, name, revision))
+}
+
+// Diff lists the differences between two revisions of an xApp
+func (h *ConfigHistory) Diff(namespace, name string, from, to int64) (*models.ConfigDiff, error) {
+	a, err := h.Get(namespace, name, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := h.Get(namespace, name, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ConfigDiff{From: from, To: to, Changes: diffConfig(a.Config, b.Config)}, nil
+}
+
+// Close closes the SDL client of the history
+func (h *ConfigHistory) Close() error {
+	return metrics.CloseStorage(h.db)
+}
+
+func (h *ConfigHistory) keys(namespace, name string) ([]string, error) {
+	all, err := h.db.GetAll(configHistorySdlNs)
+	if err != nil {
+		appmgr.Logger.Error("DB.session.GetAll failed: %v ", err.Error())
+		return nil, err
+	}
+
+	var keys []string
+	prefix := revisionPrefix(namespace, name)
+	for _, key := range all {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (h *ConfigHistory) get(key string) (*models.ConfigRevision, error) {
+	values, err := h.db.Get(configHistorySdlNs, []string{key})
+	if err != nil {
+		appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
+		return nil, err
+	}
+
+	if values[key] == nil {
+		return nil, ErrRevisionNotFound
+	}
+	return parseRevision(values[key])
+}
+
+func parseRevision(value interface{}) (*models.ConfigRevision, error) {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil, ErrRevisionNotFound
+	}
+
+	var rev models.ConfigRevision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// diffConfig lists the changes from a to b, sorted by path
+func diffConfig(a, b interface{}) models.ConfigChanges {
+	changes := models.ConfigChanges{}
+	diffValues("", a, b, &changes)
+	sort.Slice(changes, func(i, j int) bool { return *changes[i].Path < *changes[j].Path })
+	return changes
+}
+
+// diffValues walks two decoded JSON documents and appends a change for every
+// added, removed or modified value. Arrays are compared as a whole.
+func diffValues(path string, a, b interface{}, changes *models.ConfigChanges) {
+	ma, aIsMap := a.(map[string]interface{})
+	mb, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		for k, va := range ma {
+			if vb, found := mb[k]; found {
+				diffValues(path+"/"+k, va, vb, changes)
+			} else {
+				addChange(changes, path+"/"+k, models.ConfigChangeOpRemoved, va, nil)
+			}
+		}
+		for k, vb := range mb {
+			if _, found := ma[k]; !found {
+				addChange(changes, path+"/"+k, models.ConfigChangeOpAdded, nil, vb)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		if path == "" {
+			path = "/"
+		}
+		addChange(changes, path, models.ConfigChangeOpChanged, a, b)
+	}
+}
+
+func addChange(changes *models.ConfigChanges, path, op string, oldValue, newValue interface{}) {
+	p := path
+	*changes = append(*changes, &models.ConfigChange{Path: &p, Op: &op, Old: oldValue, New: newValue})
+}
@@ -0,0 +1,284 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package cm
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+func TestAddNumbersRevisions(t *testing.T) {
+	h := createConfigHistory(newFakeSdl())
+
+	r1, err := h.Add("ricxapp", "dummy-xapp", "alice", "", map[string]interface{}{"threshold": 1.0})
+	assert.Nil(t, err)
+	r2, err := h.Add("ricxapp", "dummy-xapp", "bob", "", map[string]interface{}{"threshold": 2.0})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), r1.Revision)
+	assert.Equal(t, int64(2), r2.Revision)
+
+	rev, err := h.Get("ricxapp", "dummy-xapp", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", rev.Author)
+	assert.Equal(t, map[string]interface{}{"threshold": 1.0}, rev.Config)
+}
+
+func TestAddDropsOldestRevisions(t *testing.T) {
+	h := createConfigHistory(newFakeSdl())
+	h.maxRevisions = 2
+
+	for i := 0; i < 3; i++ {
+		h.Add("ricxapp", "dummy-xapp", "alice", "", map[string]interface{}{"threshold": float64(i)})
+	}
+
+	revisions, err := h.List("ricxapp", "dummy-xapp")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(revisions))
+	assert.Equal(t, int64(2), revisions[0].Revision)
+	assert.Equal(t, int64(3), revisions[1].Revision)
+
+	_, err = h.Get("ricxapp", "dummy-xapp", 1)
+	assert.Equal(t, ErrRevisionNotFound, err)
+}
+
+func TestListKeepsXappsApart(t *testing.T) {
+	h := createConfigHistory(newFakeSdl())
+	h.Add("ricxapp", "dummy-xapp", "alice", "", map[string]interface{}{})
+	h.Add("ricxapp", "dummy-xapp-2", "alice", "", map[string]interface{}{})
+	h.Add("trialxapp", "dummy-xapp", "alice", "", map[string]interface{}{})
+
+	revisions, err := h.List("ricxapp", "dummy-xapp")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(revisions))
+
+	revisions, err = h.List("ricxapp", "no-xapp")
+	assert.Nil(t, err)
+	assert.Equal(t, models.ConfigRevisionList{}, revisions)
+}
+
+func TestDiffListsChanges(t *testing.T) {
+	h := createConfigHistory(newFakeSdl())
+	h.Add("ricxapp", "dummy-xapp", "alice", "", map[string]interface{}{
+		"threshold": 1.0,
+		"active":    true,
+		"nested":    map[string]interface{}{"a": "x"},
+	})
+	h.Add("ricxapp", "dummy-xapp", "bob", "", map[string]interface{}{
+		"threshold": 2.0,
+		"nested":    map[string]interface{}{"a": "x", "b": "y"},
+	})
+
+	diff, err := h.Diff("ricxapp", "dummy-xapp", 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), diff.From)
+	assert.Equal(t, int64(2), diff.To)
+	assert.Equal(t, 3, len(diff.Changes))
+
+	assert.Equal(t, "/active", *diff.Changes[0].Path)
+	assert.Equal(t, models.ConfigChangeOpRemoved, *diff.Changes[0].Op)
+	assert.Equal(t, "/nested/b", *diff.Changes[1].Path)
+	assert.Equal(t, models.ConfigChangeOpAdded, *diff.Changes[1].Op)
+	assert.Equal(t, "/threshold", *diff.Changes[2].Path)
+	assert.Equal(t, models.ConfigChangeOpChanged, *diff.Changes[2].Op)
+	assert.Equal(t, 1.0, diff.Changes[2].Old)
+	assert.Equal(t, 2.0, diff.Changes[2].New)
+
+	_, err = h.Diff("ricxapp", "dummy-xapp", 1, 3)
+	assert.Equal(t, ErrRevisionNotFound, err)
+}
+
+func TestUpdateConfigStoresInitialAndNewRevision(t *testing.T) {
+	c := newHistoryTestCM(t)
+
+	rev, validationErrors, err := c.UpdateConfig(newTestXAppConfig(map[string]interface{}{"active": false}), "alice", "")
+	assert.Nil(t, err)
+	assert.Nil(t, validationErrors)
+	assert.Equal(t, int64(2), rev.Revision)
+
+	revisions, _ := c.History().List("ricxapp", "dummy-xapp")
+	assert.Equal(t, 2, len(revisions))
+	assert.Equal(t, "appmgr", revisions[0].Author)
+	assert.Equal(t, map[string]interface{}{"active": true}, revisions[0].Config)
+	assert.Equal(t, "alice", revisions[1].Author)
+}
+
+func TestRollbackConfigAppliesStoredRevision(t *testing.T) {
+	c := newHistoryTestCM(t)
+	c.UpdateConfig(newTestXAppConfig(map[string]interface{}{"active": false}), "alice", "")
+
+	writeTestSchema(t, "dummy-xapp")
+	rev, validationErrors, err := c.RollbackConfig("dummy-xapp", "ricxapp", 1, "bob")
+	assert.Nil(t, err)
+	assert.Nil(t, validationErrors)
+	assert.Equal(t, int64(3), rev.Revision)
+	assert.Equal(t, "rollback to revision 1", rev.Description)
+
+	var cfg map[string]interface{}
+	content, _ := c.ReadConfigmap("dummy-xapp", "ricxapp")
+	json.Unmarshal([]byte(content), &cfg)
+	assert.Equal(t, map[string]interface{}{"active": true}, cfg["controls"])
+
+	_, _, err = c.RollbackConfig("dummy-xapp", "ricxapp", 7, "bob")
+	assert.Equal(t, ErrRevisionNotFound, err)
+}
+
+func newHistoryTestCM(t *testing.T) *CM {
+	tarDir := viper.GetString("xapp.tarDir")
+	viper.Set("xapp.tarDir", t.TempDir())
+	testChartRepo = t.TempDir()
+	t.Cleanup(func() {
+		viper.Set("xapp.tarDir", tarDir)
+		testChartRepo = ""
+		resetHelmExecMock()
+	})
+	helmExec = mockedHelmExec
+	writeTestSchema(t, "dummy-xapp")
+
+	c := NewCM()
+	c.SetChartCache(NewChartCache(t.TempDir(), 10))
+	c.SetKubeClient(NewKubeClientForClientset(fake.NewSimpleClientset(newTestConfigMap(`{"name": "ueec", "controls": {"active": true}}`))))
+	c.SetHistory(createConfigHistory(newFakeSdl()))
+	return c
+}
+
+// writeTestSchema puts a schema in the chart that the mocked helm fetch untars
+func writeTestSchema(t *testing.T, name string) {
+	file := path.Join(testChartRepo, name, viper.GetString("xapp.schema"))
+	assert.Nil(t, os.MkdirAll(path.Dir(file), 0755))
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"type": "object"}`), 0644))
+}
+
+func newTestXAppConfig(config interface{}) models.XAppConfig {
+	name, namespace := "dummy-xapp", "ricxapp"
+	return models.XAppConfig{Metadata: &models.ConfigMetadata{XappName: &name, Namespace: &namespace}, Config: config}
+}
+
+func TestUpdateConfigReturnsAppliedConfigIfRevisionNotStored(t *testing.T) {
+	c := newHistoryTestCM(t)
+	c.SetHistory(createConfigHistory(&failingSetSdl{newFakeSdl()}))
+
+	rev, validationErrors, err := c.UpdateConfig(newTestXAppConfig(map[string]interface{}{"active": false}), "alice", "")
+	assert.Nil(t, err)
+	assert.Nil(t, validationErrors)
+	assert.Equal(t, int64(0), rev.Revision)
+	assert.Equal(t, "alice", rev.Author)
+	assert.Equal(t, map[string]interface{}{"active": false}, rev.Config)
+
+	var cfg map[string]interface{}
+	content, _ := c.ReadConfigmap("dummy-xapp", "ricxapp")
+	json.Unmarshal([]byte(content), &cfg)
+	assert.Equal(t, map[string]interface{}{"active": false}, cfg["controls"])
+}
+
+func TestRecordConfigChecksRevisionAndDoesNotApply(t *testing.T) {
+	c := newHistoryTestCM(t)
+	c.UpdateConfig(newTestXAppConfig(map[string]interface{}{"active": false}), "alice", "")
+
+	stale := int64(1)
+	_, err := c.RecordConfig(newTestXAppConfig(map[string]interface{}{"active": true}), &stale, "bob", "push")
+	assert.Equal(t, ErrRevisionMismatch, err)
+
+	current := int64(2)
+	rev, err := c.RecordConfig(newTestXAppConfig(map[string]interface{}{"active": true}), &current, "bob", "push")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), rev.Revision)
+	assert.Equal(t, "bob", rev.Author)
+
+	var cfg map[string]interface{}
+	content, _ := c.ReadConfigmap("dummy-xapp", "ricxapp")
+	json.Unmarshal([]byte(content), &cfg)
+	assert.Equal(t, map[string]interface{}{"active": false}, cfg["controls"])
+}
+
+// fakeSdl keeps the values in memory, as strings like a real SDL/Redis client returns them
+type fakeSdl struct {
+	mutex sync.Mutex
+	data  map[string]map[string]interface{}
+}
+
+func newFakeSdl() *fakeSdl {
+	return &fakeSdl{data: make(map[string]map[string]interface{})}
+}
+
+func (f *fakeSdl) Set(ns string, pairs ...interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.data[ns] == nil {
+		f.data[ns] = make(map[string]interface{})
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		f.data[ns][pairs[i].(string)] = string(pairs[i+1].([]byte))
+	}
+	return nil
+}
+
+func (f *fakeSdl) Get(ns string, keys []string) (map[string]interface{}, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	values := make(map[string]interface{})
+	for _, key := range keys {
+		if v, found := f.data[ns][key]; found {
+			values[key] = v
+		}
+	}
+	return values, nil
+}
+
+func (f *fakeSdl) GetAll(ns string) (keys []string, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for key := range f.data[ns] {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (f *fakeSdl) Remove(ns string, keys []string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(f.data[ns], key)
+	}
+	return nil
+}
+
+// failingSetSdl fails to store anything, like an unreachable DB
+type failingSetSdl struct {
+	*fakeSdl
+}
+
+func (f *failingSetSdl) Set(ns string, pairs ...interface{}) error {
+	return errors.New("DB not reachable")
+}
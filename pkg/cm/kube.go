@@ -0,0 +1,66 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package cm
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+)
+
+type kubeClient struct {
+	clientset kubernetes.Interface
+}
+
+// NewKubeClient returns a client-go based KubeClient using the in-cluster config
+func NewKubeClient() (appmgr.KubeClient, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewKubeClientForClientset(clientset), nil
+}
+
+// NewKubeClientForClientset wraps an existing clientset, e.g. a fake one in tests
+func NewKubeClientForClientset(clientset kubernetes.Interface) appmgr.KubeClient {
+	return &kubeClient{clientset: clientset}
+}
+
+func (k *kubeClient) GetConfigMap(name, namespace string) (*corev1.ConfigMap, error) {
+	return k.clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+func (k *kubeClient) UpdateConfigMap(c *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	return k.clientset.CoreV1().ConfigMaps(c.Namespace).Update(context.Background(), c, metav1.UpdateOptions{})
+}
+
+func (k *kubeClient) GetService(name, namespace string) (*corev1.Service, error) {
+	return k.clientset.CoreV1().Services(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
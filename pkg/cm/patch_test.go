@@ -0,0 +1,140 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package cm
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	assert.Nil(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	doc := decode(t, `{"a": "b", "c": {"d": "e", "f": "g"}, "h": [1, 2]}`)
+	patch := decode(t, `{"a": "z", "c": {"f": null}, "h": [3], "i": {"j": 1}}`)
+
+	patched, err := ApplyPatch(MergePatchType, doc, patch)
+	assert.Nil(t, err)
+	assert.Equal(t, decode(t, `{"a": "z", "c": {"d": "e"}, "h": [3], "i": {"j": 1}}`), patched)
+
+	// The original document is left intact
+	assert.Equal(t, decode(t, `{"a": "b", "c": {"d": "e", "f": "g"}, "h": [1, 2]}`), doc)
+}
+
+func TestApplyMergePatchToMissingControls(t *testing.T) {
+	patched, err := ApplyPatch(MergePatchType, nil, decode(t, `{"threshold": 5}`))
+	assert.Nil(t, err)
+	assert.Equal(t, decode(t, `{"threshold": 5}`), patched)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	doc := decode(t, `{"foo": "bar", "list": [1, 2, 3], "a/b": {"c": 1}}`)
+	patch := decode(t, `[
+		{"op": "test", "path": "/foo", "value": "bar"},
+		{"op": "replace", "path": "/foo", "value": "baz"},
+		{"op": "add", "path": "/list/1", "value": 9},
+		{"op": "add", "path": "/list/-", "value": 4},
+		{"op": "remove", "path": "/list/0"},
+		{"op": "copy", "from": "/a~1b", "path": "/copied"},
+		{"op": "move", "from": "/a~1b/c", "path": "/moved"}
+	]`)
+
+	patched, err := ApplyPatch(JSONPatchType, doc, patch)
+	assert.Nil(t, err)
+	assert.Equal(t, decode(t, `{"foo": "baz", "list": [9, 2, 3, 4], "a/b": {}, "copied": {"c": 1}, "moved": 1}`), patched)
+}
+
+func TestApplyJSONPatchIsAllOrNothing(t *testing.T) {
+	doc := decode(t, `{"foo": "bar"}`)
+	patch := decode(t, `[
+		{"op": "replace", "path": "/foo", "value": "baz"},
+		{"op": "test", "path": "/foo", "value": "bar"}
+	]`)
+
+	_, err := ApplyPatch(JSONPatchType, doc, patch)
+	assert.True(t, errors.Is(err, ErrInvalidPatch))
+	assert.Equal(t, decode(t, `{"foo": "bar"}`), doc)
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	doc := decode(t, `{"foo": "bar", "list": [1]}`)
+	patches := []string{
+		`{"op": "add", "path": "/x", "value": 1}`,
+		`[{"op": "add", "path": "/x"}]`,
+		`[{"op": "remove", "path": "/missing"}]`,
+		`[{"op": "replace", "path": "/list/1", "value": 2}]`,
+		`[{"op": "add", "path": "/list/01", "value": 2}]`,
+		`[{"op": "add", "path": "foo", "value": 2}]`,
+		`[{"op": "move", "from": "/list", "path": "/list/0"}]`,
+		`[{"op": "copy", "path": "/x"}]`,
+		`[{"op": "unknown", "path": "/foo"}]`,
+	}
+
+	for _, p := range patches {
+		_, err := ApplyPatch(JSONPatchType, doc, decode(t, p))
+		assert.True(t, errors.Is(err, ErrInvalidPatch), p)
+	}
+
+	_, err := ApplyPatch("application/json", doc, decode(t, `{}`))
+	assert.True(t, errors.Is(err, ErrInvalidPatch))
+}
+
+func TestPatchConfigUpdatesControls(t *testing.T) {
+	c := newHistoryTestCM(t)
+
+	rev, validationErrors, err := c.PatchConfig("dummy-xapp", "ricxapp", MergePatchType, decode(t, `{"threshold": 5}`), nil, "alice")
+	assert.Nil(t, err)
+	assert.Nil(t, validationErrors)
+	assert.Equal(t, int64(2), rev.Revision)
+	assert.Equal(t, decode(t, `{"active": true, "threshold": 5}`), rev.Config)
+
+	var cfg map[string]interface{}
+	content, _ := c.ReadConfigmap("dummy-xapp", "ricxapp")
+	json.Unmarshal([]byte(content), &cfg)
+	assert.Equal(t, "ueec", cfg["name"])
+	assert.Equal(t, decode(t, `{"active": true, "threshold": 5}`), cfg["controls"])
+}
+
+func TestPatchConfigChecksIfMatch(t *testing.T) {
+	c := newHistoryTestCM(t)
+	patch := decode(t, `[{"op": "replace", "path": "/active", "value": false}]`)
+
+	stale, current := int64(1), int64(0)
+	_, _, err := c.PatchConfig("dummy-xapp", "ricxapp", JSONPatchType, patch, &stale, "alice")
+	assert.Equal(t, ErrRevisionMismatch, err)
+
+	rev, _, err := c.PatchConfig("dummy-xapp", "ricxapp", JSONPatchType, patch, &current, "alice")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), rev.Revision)
+
+	// A second editor still holding revision 0 must not overwrite the change
+	_, _, err = c.PatchConfig("dummy-xapp", "ricxapp", MergePatchType, decode(t, `{"active": true}`), &current, "bob")
+	assert.Equal(t, ErrRevisionMismatch, err)
+
+	_, _, err = c.UpdateConfigIfMatch(newTestXAppConfig(map[string]interface{}{"active": true}), &stale, "bob", "")
+	assert.Equal(t, ErrRevisionMismatch, err)
+}
@@ -0,0 +1,221 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/cm"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
+)
+
+// helmHistoryEntry is one revision in the output of 'helm history --output json'
+type helmHistoryEntry struct {
+	Revision    int    `json:"revision"`
+	Updated     string `json:"updated"`
+	Status      string `json:"status"`
+	Chart       string `json:"chart"`
+	AppVersion  string `json:"app_version"`
+	Description string `json:"description"`
+}
+
+// cliBackend runs the helm command line and scrapes its output. It is used
+// with Helm v2, and as a fallback when the Helm SDK backend is not available.
+type cliBackend struct {
+	h *Helm
+}
+
+// helmExecContext runs helm in a span, child of the span in ctx
+func helmExecContext(ctx context.Context, args string) ([]byte, error) {
+	return tracing.Exec(ctx, "helm", args, helmExec)
+}
+
+// AddRepo passes the credentials as separate arguments, never through a shell
+func (b *cliBackend) AddRepo(name, url, username, password string) error {
+	_, err := helmExecArgs("repo", "add", name, url, "--username", username, "--password", password)
+	return err
+}
+
+func (b *cliBackend) UpdateRepos() error {
+	if _, err := helmExec(strings.Join([]string{"repo update "}, "")); err != nil {
+		return err
+	}
+	cm.InvalidateLatestCharts()
+	return nil
+}
+
+func (b *cliBackend) Install(ctx context.Context, x models.XappDescriptor) (*appmgr.Release, error) {
+	if err := b.UpdateRepos(); err != nil {
+		return nil, err
+	}
+
+	out, err := helmExecContext(ctx, b.h.GetInstallArgs(x, false))
+	if err != nil {
+		return nil, err
+	}
+	return b.parseRelease(*x.XappName, x.Namespace, string(out)), nil
+}
+
+func (b *cliBackend) Upgrade(ctx context.Context, x models.XappDescriptor) (*appmgr.Release, error) {
+	if err := b.UpdateRepos(); err != nil {
+		return nil, err
+	}
+
+	out, err := helmExecContext(ctx, b.h.GetUpgradeArgs(x))
+	if err != nil {
+		return nil, err
+	}
+	return b.parseRelease(*x.XappName, x.Namespace, string(out)), nil
+}
+
+func (b *cliBackend) Uninstall(ctx context.Context, name, namespace string) (err error) {
+	var command string
+	if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
+		command = strings.Join([]string{"uninstall ", name, " -n ", namespace}, "")
+		appmgr.Logger.Info("DELETE: Version 3")
+	} else {
+		command = strings.Join([]string{"del --purge ", name}, "")
+		appmgr.Logger.Info("DELETE: Version 2")
+	}
+
+	_, err = helmExecContext(ctx, command)
+	return
+}
+
+func (b *cliBackend) Rollback(ctx context.Context, name, namespace string, revision int) (err error) {
+	command := fmt.Sprintf("rollback %s %d", name, revision)
+	if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
+		command = fmt.Sprintf("%s --namespace %s", command, namespace)
+	}
+
+	_, err = helmExecContext(ctx, command)
+	return
+}
+
+func (b *cliBackend) History(name, namespace string) (releases []*appmgr.Release, err error) {
+	command := fmt.Sprintf("history %s --output json", name)
+	if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
+		command = fmt.Sprintf("%s --namespace %s", command, namespace)
+	}
+
+	out, err := helmExec(command)
+	if err != nil {
+		return
+	}
+
+	var entries []helmHistoryEntry
+	if err = json.Unmarshal(out, &entries); err != nil {
+		appmgr.Logger.Info("Parsing history of '%s' failed: %v", name, err)
+		return
+	}
+
+	for _, e := range entries {
+		rel := &appmgr.Release{
+			Name:        name,
+			Namespace:   namespace,
+			Chart:       e.Chart,
+			AppVersion:  e.AppVersion,
+			Status:      e.Status,
+			Revision:    e.Revision,
+			Description: e.Description,
+		}
+		if i := strings.LastIndex(e.Chart, "-"); i > 0 {
+			rel.Chart, rel.Version = e.Chart[:i], e.Chart[i+1:]
+		}
+		rel.Updated, _ = time.Parse(time.RFC3339, e.Updated)
+		releases = append(releases, rel)
+	}
+	return
+}
+
+func (b *cliBackend) Status(name, namespace string) (*appmgr.Release, error) {
+	var command string
+	if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
+		command = strings.Join([]string{"status ", name, " --namespace ", namespace}, "")
+		appmgr.Logger.Info("Status: Version 3")
+	} else {
+		command = strings.Join([]string{"status ", name}, "")
+		appmgr.Logger.Info("Status: Version 2")
+	}
+
+	out, err := helmExec(command)
+	if err != nil {
+		return nil, err
+	}
+	return b.parseRelease(name, namespace, string(out)), nil
+}
+
+func (b *cliBackend) List(namespace string) (releases []*appmgr.Release, err error) {
+	out, err := helmExec(strings.Join([]string{"list --all --deployed --output yaml --namespace=", namespace}, ""))
+	if err != nil {
+		return
+	}
+
+	names, err := b.h.GetNames(string(out))
+	for _, name := range names {
+		releases = append(releases, &appmgr.Release{Name: name, Namespace: namespace})
+	}
+	return
+}
+
+func (b *cliBackend) Search() (names []string, err error) {
+	rname := getRepoName()
+
+	var cmdArgs string
+	if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
+		cmdArgs = strings.Join([]string{"search repo ", rname}, "")
+	} else {
+		cmdArgs = strings.Join([]string{"search ", rname}, "")
+	}
+
+	out, err := helmExec(cmdArgs)
+	if err != nil {
+		return
+	}
+	return b.h.cm.ParseHelmSearch(string(out)), nil
+}
+
+func (b *cliBackend) Fetch(chart, version, dir string) error {
+	args := fmt.Sprintf("fetch --untar --untardir %s %s/%s", dir, getRepoName(), chart)
+	if version != "" {
+		args = fmt.Sprintf("%s --version=%s", args, version)
+	}
+
+	_, err := helmExec(args)
+	return err
+}
+
+func (b *cliBackend) parseRelease(name, namespace, out string) *appmgr.Release {
+	appVersion, version := b.h.getVersions(name, namespace)
+	return &appmgr.Release{
+		Name:       name,
+		Namespace:  namespace,
+		Version:    version,
+		AppVersion: appVersion,
+		Status:     b.h.GetState(out),
+		Pods:       parsePods(name, out),
+	}
+}
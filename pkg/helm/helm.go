@@ -20,9 +20,14 @@
 package helm
 
 import (
+        "context"
+        "encoding/json"
+        "errors"
         "fmt"
         "github.com/ghodss/yaml"
         "github.com/spf13/viper"
+        "go.opentelemetry.io/otel/attribute"
+        "go.opentelemetry.io/otel/trace"
         "io/ioutil"
         "os"
         "regexp"
@@ -33,15 +38,23 @@ import (
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/cm"
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+        "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/util"
 )
 
 var kubeExec = util.KubectlExec
 var helmExec = util.HelmExec
+var helmExecArgs = util.HelmExecArgs
+
+// Release names end up in kubernetes object names, so they must be valid DNS-1123 labels
+var releaseNameRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+var chartVersionRe = regexp.MustCompile(`^[0-9A-Za-z.+_-]*$`)
 
 type Helm struct {
         initDone bool
         cm       *cm.CM
+        backend  appmgr.ReleaseBackend
+        kube     appmgr.KubeClient
 }
 
 func GetHelmVersion() {
@@ -59,7 +72,35 @@ func GetHelmVersion() {
 
 func NewHelm() *Helm {
         GetHelmVersion()
-        return &Helm{initDone: false, cm: cm.NewCM()}
+        h := &Helm{initDone: false, cm: cm.NewCM()}
+        h.backend = h.newBackend()
+        h.cm.SetBackend(h.backend)
+
+        if kube, err := cm.NewKubeClient(); err == nil {
+                h.kube = kube
+                h.cm.SetKubeClient(kube)
+        } else {
+                appmgr.Logger.Info("Kubernetes client not available, using kubectl: %v", err)
+        }
+        return h
+}
+
+// CM returns the configmap handler wired to the release backend and the
+// kubernetes client of h, to be shared by the callers
+func (h *Helm) CM() *cm.CM {
+        return h.cm
+}
+
+func (h *Helm) newBackend() appmgr.ReleaseBackend {
+        if cm.EnvHelmVersion == cm.HELM_VERSION_3 && viper.GetString("helm.backend") != "cli" {
+                b, err := newSDKBackend()
+                if err == nil {
+                        appmgr.Logger.Info("Using Helm SDK release backend")
+                        return b
+                }
+                appmgr.Logger.Error("Helm SDK backend not available, falling back to helm command line: %v", err)
+        }
+        return &cliBackend{h: h}
 }
 
 func (h *Helm) Initialize() {
@@ -122,106 +163,217 @@ func (h *Helm) AddRepo() (out []byte, err error) {
                 return
         }
 
-        repoArgs := fmt.Sprintf(" %s %s ", viper.GetString("helm.repo-name"), viper.GetString("helm.repo"))
-        credentials := fmt.Sprintf(" --username %s --password %s", string(username), string(password))
-
-        return helmExec(strings.Join([]string{"repo add ", repoArgs, credentials}, ""))
+        err = h.backend.AddRepo(viper.GetString("helm.repo-name"), viper.GetString("helm.repo"),
+                strings.TrimSpace(string(username)), strings.TrimSpace(string(password)))
+        if err == nil {
+                cm.InvalidateChartCache()
+        }
+        return
 }
 
 func (h *Helm) Install(m models.XappDescriptor) (xapp models.Xapp, err error) {
-        m.Namespace = h.cm.GetNamespace(m.Namespace)
+        return h.InstallContext(context.Background(), m)
+}
 
-        out, err := h.Run(strings.Join([]string{"repo update "}, ""))
-        if err != nil {
+// InstallContext is Install within the trace of ctx
+func (h *Helm) InstallContext(ctx context.Context, m models.XappDescriptor) (xapp models.Xapp, err error) {
+        if m.Namespace, err = h.cm.ValidateNamespace(m.Namespace); err != nil {
+                return
+        }
+        if err = ValidateDescriptor(m); err != nil {
                 return
         }
 
-        out, err = h.Run(h.GetInstallArgs(m, false))
+        ctx, span := startSpan(ctx, "helm.install", *m.XappName, m.Namespace)
+        rel, err := h.backend.Install(ctx, m)
+        tracing.End(span, err)
         if err != nil {
                 return
         }
-        return h.ParseStatus(*m.XappName, string(out))
+        return h.ReleaseToXapp(rel), nil
 }
 
-func (h *Helm) Status(name string) (xapp models.Xapp, err error) {
-         var command string
-        if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
-                names  := h.cm.GetNamespace("")
-                command = strings.Join([]string{"status ", name," --namespace ", names}, "")
-                appmgr.Logger.Info ("Status: Version 3")
-        }else {
-                command = strings.Join([]string{"status ", name}, "")
-                appmgr.Logger.Info ("Status: Version 2")
+func (h *Helm) Status(name, namespace string) (xapp models.Xapp, err error) {
+        if err = ValidateName(name); err != nil {
+                return
+        }
+        if namespace, err = h.cm.ValidateNamespace(namespace); err != nil {
+                return
         }
-        out, err := h.Run(command)
 
+        rel, err := h.backend.Status(name, namespace)
         if err != nil {
                 appmgr.Logger.Info("Getting xapps status: %v", err.Error())
                 return
         }
-        return h.ParseStatus(name, string(out))
+        return h.ReleaseToXapp(rel), nil
 }
 
-func (h *Helm) StatusAll() (xapps models.AllDeployedXapps, err error) {
-        xappNameList, err := h.List()
-        if err != nil {
-                appmgr.Logger.Info("Helm list failed: %v", err.Error())
-                return
+// StatusAll returns the xapps deployed in the given namespace, or in all managed namespaces if it is empty
+func (h *Helm) StatusAll(namespace string) (xapps models.AllDeployedXapps, err error) {
+        namespaces := h.cm.GetNamespaces()
+        if namespace != "" {
+                if namespace, err = h.cm.ValidateNamespace(namespace); err != nil {
+                        return
+                }
+                namespaces = []string{namespace}
         }
 
-        return h.parseAllStatus(xappNameList)
+        xapps = models.AllDeployedXapps{}
+        for _, ns := range namespaces {
+                xappNameList, err := h.List(ns)
+                if err != nil {
+                        appmgr.Logger.Info("Helm list failed: %v", err.Error())
+                        return xapps, err
+                }
+
+                nsXapps, _ := h.parseAllStatus(xappNameList, ns)
+                xapps = append(xapps, nsXapps...)
+        }
+        return xapps, nil
 }
 
-func (h *Helm) List() (names []string, err error) {
-        ns := h.cm.GetNamespace("")
-        out, err := h.Run(strings.Join([]string{"list --all --deployed --output yaml --namespace=", ns}, ""))
+func (h *Helm) List(namespace string) (names []string, err error) {
+        if namespace, err = h.cm.ValidateNamespace(namespace); err != nil {
+                return
+        }
+
+        releases, err := h.backend.List(namespace)
         if err != nil {
                 appmgr.Logger.Info("Listing deployed xapps failed: %v", err.Error())
                 return
         }
 
-        return h.GetNames(string(out))
+        for _, r := range releases {
+                if strings.Contains(r.Name, "appmgr") == false {
+                        names = append(names, r.Name)
+                }
+        }
+        return names, nil
 }
 
 func (h *Helm) SearchAll() models.AllDeployableXapps {
         return h.cm.GetNamesFromHelmRepo()
 }
 
-func (h *Helm) Delete(name string) (xapp models.Xapp, err error) {
-        xapp, err = h.Status(name)
-        var command string = ""
-        ns := h.cm.GetNamespace("")
+func (h *Helm) Delete(name, namespace string) (xapp models.Xapp, err error) {
+        return h.DeleteContext(context.Background(), name, namespace)
+}
 
+// DeleteContext is Delete within the trace of ctx
+func (h *Helm) DeleteContext(ctx context.Context, name, namespace string) (xapp models.Xapp, err error) {
+        xapp, err = h.Status(name, namespace)
         if err != nil {
                 appmgr.Logger.Info("Fetching xapp status failed: %v", err.Error())
                 return
         }
-        if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
-                command = strings.Join([]string{"uninstall ", name," -n ", ns}, "")
-                appmgr.Logger.Info ("DELETE: Version 3")
-        } else {
-                command = strings.Join([]string{"del --purge ", name}, "")
-                appmgr.Logger.Info ("DELETE: Version 2")
-        }
-         _, err = h.Run (command)
 
+        ctx, span := startSpan(ctx, "helm.uninstall", name, xapp.Namespace)
+        err = h.backend.Uninstall(ctx, name, xapp.Namespace)
+        tracing.End(span, err)
         return xapp, err
 }
 
-func (h *Helm) Fetch(name, tarDir string) error {
-        if strings.HasSuffix(os.Args[0], ".test") {
-                return nil
+func (h *Helm) Upgrade(m models.XappDescriptor) (xapp models.Xapp, err error) {
+        return h.UpgradeContext(context.Background(), m)
+}
+
+// UpgradeContext is Upgrade within the trace of ctx
+func (h *Helm) UpgradeContext(ctx context.Context, m models.XappDescriptor) (xapp models.Xapp, err error) {
+        if m.Namespace, err = h.cm.ValidateNamespace(m.Namespace); err != nil {
+                return
+        }
+        if err = ValidateDescriptor(m); err != nil {
+                return
+        }
+
+        ctx, span := startSpan(ctx, "helm.upgrade", *m.XappName, m.Namespace)
+        rel, err := h.backend.Upgrade(ctx, m)
+        tracing.End(span, err)
+        if err != nil {
+                appmgr.Logger.Info("Upgrading xapp '%s' failed: %v", *m.XappName, err.Error())
+                return
         }
+        return h.ReleaseToXapp(rel), nil
+}
 
-        rname := viper.GetString("helm.repo-name") + "/"
+// Rollback returns the xapp to the given revision, 0 meaning the previous one
+func (h *Helm) Rollback(name, namespace string, revision int) (xapp models.Xapp, err error) {
+        return h.RollbackContext(context.Background(), name, namespace, revision)
+}
 
-        _, err := h.Run(strings.Join([]string{"fetch --untar --untardir ", tarDir, " ", rname, name}, ""))
-        return err
+// RollbackContext is Rollback within the trace of ctx
+func (h *Helm) RollbackContext(ctx context.Context, name, namespace string, revision int) (xapp models.Xapp, err error) {
+        if err = ValidateName(name); err != nil {
+                return
+        }
+        if revision < 0 {
+                return xapp, fmt.Errorf("invalid revision '%d'", revision)
+        }
+        if namespace, err = h.cm.ValidateNamespace(namespace); err != nil {
+                return
+        }
+
+        ctx, span := startSpan(ctx, "helm.rollback", name, namespace)
+        span.SetAttributes(attribute.Int("helm.revision", revision))
+        err = h.backend.Rollback(ctx, name, namespace, revision)
+        tracing.End(span, err)
+        if err != nil {
+                appmgr.Logger.Info("Rolling back xapp '%s' failed: %v", name, err.Error())
+                return
+        }
+        return h.Status(name, namespace)
+}
+
+// startSpan starts the span of a release operation
+func startSpan(ctx context.Context, name, release, namespace string) (context.Context, trace.Span) {
+        return tracing.Start(ctx, name, attribute.String("helm.release", release), attribute.String("helm.namespace", namespace))
+}
+
+func (h *Helm) History(name, namespace string) (history models.XappHistory, err error) {
+        if err = ValidateName(name); err != nil {
+                return
+        }
+        if namespace, err = h.cm.ValidateNamespace(namespace); err != nil {
+                return
+        }
+
+        releases, err := h.backend.History(name, namespace)
+        if err != nil {
+                appmgr.Logger.Info("Getting history of xapp '%s' failed: %v", name, err.Error())
+                return
+        }
+
+        history = models.XappHistory{}
+        for _, r := range releases {
+                rev := &models.XappRevision{
+                        Revision:     int64(r.Revision),
+                        ChartVersion: r.Version,
+                        AppVersion:   r.AppVersion,
+                        Status:       strings.ToLower(r.Status),
+                        Description:  r.Description,
+                }
+                if !r.Updated.IsZero() {
+                        rev.Updated = r.Updated.Format(time.RFC3339)
+                }
+                history = append(history, rev)
+        }
+        return
+}
+
+func (h *Helm) Fetch(name, tarDir string) error {
+        return h.backend.Fetch(name, "", tarDir)
 }
 
 // Helper functions
-func (h *Helm) GetVersion(name string) (version string) {
-        ns := h.cm.GetNamespace("")
+func (h *Helm) GetVersion(name, ns string) (version string) {
+        version, _ = h.getVersions(name, ns)
+        return
+}
+
+// getVersions returns the app version and the chart version of a deployed release
+func (h *Helm) getVersions(name, ns string) (version, chartVersion string) {
+        ns = h.cm.GetNamespace(ns)
         var command string = ""
         if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
                 command = strings.Join([]string{"list --deployed --output yaml --namespace=", ns, " ","-f ",name}, "")
@@ -243,6 +395,11 @@ func (h *Helm) GetVersion(name string) (version string) {
                 version, _ = strconv.Unquote(version)
         }
 
+        if chart := regexp.MustCompile(`Chart: (.*)`).FindStringSubmatch(string(out)); chart != nil {
+                if i := strings.LastIndex(chart[1], "-"); i > 0 {
+                        chartVersion = strings.TrimSpace(chart[1][i+1:])
+                }
+        }
         return
 }
 
@@ -267,9 +424,13 @@ func (h *Helm) GetAddress(out string) (ip, port string) {
         return
 }
 
-func (h *Helm) GetEndpointInfo(name string) (svc string, port int) {
+func (h *Helm) GetEndpointInfo(name, ns string) (svc string, port int) {
         port = 4560 // Default
-        ns := h.cm.GetNamespace("")
+        ns = h.cm.GetNamespace(ns)
+        if h.kube != nil {
+                return h.getServiceEndpoint(name, ns)
+        }
+
         args := fmt.Sprintf(" get service -n %s service-%s-%s-rmr -o json", ns, ns, name)
         out, err := kubeExec(args)
         if err != nil {
@@ -293,6 +454,27 @@ func (h *Helm) GetEndpointInfo(name string) (svc string, port int) {
         return fmt.Sprintf("service-%s-%s-rmr.%s", ns, name, ns), port
 }
 
+func (h *Helm) getServiceEndpoint(name, ns string) (svc string, port int) {
+        port = 4560 // Default
+        svc = fmt.Sprintf("service-%s-%s-rmr", ns, name)
+
+        s, err := h.kube.GetService(svc, ns)
+        if err != nil {
+                appmgr.Logger.Info("Getting service '%s' failed: %v", svc, err)
+                return fmt.Sprintf("%s.%s", svc, ns), port
+        }
+
+        for _, p := range s.Spec.Ports {
+                if p.Name == "rmrdata" {
+                        port = int(p.Port)
+                        break
+                }
+        }
+        appmgr.Logger.Info("%s.%s %d", svc, ns, port)
+
+        return fmt.Sprintf("%s.%s", svc, ns), port
+}
+
 func (h *Helm) GetNames(out string) (names []string, err error) {
         re := regexp.MustCompile(`Name: .*`)
         result := re.FindAllStringSubmatch(out, -1)
@@ -309,13 +491,17 @@ func (h *Helm) GetNames(out string) (names []string, err error) {
         return names, nil
 }
 
-func (h *Helm) FillInstanceData(name string, out string, xapp *models.Xapp, rtData appmgr.RtmData) {
-        ip, port := h.GetEndpointInfo(name)
+func (h *Helm) FillInstanceData(name, ns string, out string, xapp *models.Xapp, rtData appmgr.RtmData) {
+        ip, port := h.GetEndpointInfo(name, ns)
         if ip == "" {
                 appmgr.Logger.Info("Endpoint IP address not found, using CluserIP")
                 ip, _ = h.GetAddress(out)
         }
 
+        h.addInstances(parsePods(name, out), ip, port, xapp, rtData)
+}
+
+func parsePods(name string, out string) (pods []appmgr.Pod) {
         var tmp string
         r := regexp.MustCompile(`.*(?s)(Running|Pending|Succeeded|Failed|Unknown).*?\r?\n\r?\n`)
         result := r.FindStringSubmatch(string(out))
@@ -323,44 +509,65 @@ func (h *Helm) FillInstanceData(name string, out string, xapp *models.Xapp, rtDa
                 return
         }
 
-        re := regexp.MustCompile(name + "-(\\w+-\\w+).*")
+        re := regexp.MustCompile(regexp.QuoteMeta(name) + "-(\\w+-\\w+).*")
         resources := re.FindAllStringSubmatch(string(result[0]), -1)
-        if resources != nil {
-                for _, v := range resources {
-                        var x models.XappInstance
-                        var name string
-                        fmt.Sscanf(v[0], "%s %s %s", &name, &tmp, &x.Status)
-                        x.Name = &name
-                        x.Status = strings.ToLower(x.Status)
-                        x.IP = ip
-                        x.Port = int64(port)
-                        x.TxMessages = rtData.TxMessages
-                        x.RxMessages = rtData.RxMessages
-                        x.Policies = rtData.Policies
-                        xapp.Instances = append(xapp.Instances, &x)
-                }
+        for _, v := range resources {
+                var p appmgr.Pod
+                fmt.Sscanf(v[0], "%s %s %s", &p.Name, &tmp, &p.Status)
+                pods = append(pods, p)
         }
+        return
 }
 
-func (h *Helm) ParseStatus(name string, out string) (xapp models.Xapp, err error) {
+func (h *Helm) addInstances(pods []appmgr.Pod, ip string, port int, xapp *models.Xapp, rtData appmgr.RtmData) {
+        for _, p := range pods {
+                var x models.XappInstance
+                name := p.Name
+                x.Name = &name
+                x.Status = strings.ToLower(p.Status)
+                x.IP = ip
+                x.Port = int64(port)
+                x.TxMessages = rtData.TxMessages
+                x.RxMessages = rtData.RxMessages
+                x.Policies = rtData.Policies
+                xapp.Instances = append(xapp.Instances, &x)
+        }
+}
+
+// ReleaseToXapp converts a release reported by the backend into the REST model
+func (h *Helm) ReleaseToXapp(rel *appmgr.Release) (xapp models.Xapp) {
+        name := rel.Name
         xapp.Name = &name
-        xapp.Version = h.GetVersion(name)
+        xapp.Namespace = h.cm.GetNamespace(rel.Namespace)
+        xapp.Version = rel.AppVersion
+        xapp.Status = strings.ToLower(rel.Status)
+
+        ip, port := h.GetEndpointInfo(name, xapp.Namespace)
+        h.addInstances(rel.Pods, ip, port, &xapp, h.cm.GetRtmData(name, xapp.Namespace))
+        return
+}
+
+func (h *Helm) ParseStatus(name, ns string, out string) (xapp models.Xapp, err error) {
+        ns = h.cm.GetNamespace(ns)
+        xapp.Name = &name
+        xapp.Namespace = ns
+        xapp.Version = h.GetVersion(name, ns)
         xapp.Status = h.GetState(out)
 
-        h.FillInstanceData(name, out, &xapp, h.cm.GetRtmData(name))
+        h.FillInstanceData(name, ns, out, &xapp, h.cm.GetRtmData(name, ns))
         return
 }
 
-func (h *Helm) parseAllStatus(names []string) (xapps models.AllDeployedXapps, err error) {
+func (h *Helm) parseAllStatus(names []string, ns string) (xapps models.AllDeployedXapps, err error) {
         xapps = models.AllDeployedXapps{}
         for _, name := range names {
                 var desc interface{}
-                err := h.cm.ReadSchema(name, &desc)
+                err := h.cm.ReadSchema(name, h.cm.DeployedChartVersion(name, ns), &desc)
                 if err != nil {
                         continue
                 }
 
-                x, err := h.Status(name)
+                x, err := h.Status(name, ns)
                 if err == nil {
                         xapps = append(xapps, &x)
                 }
@@ -399,29 +606,93 @@ func (h *Helm) GetInstallArgs(x models.XappDescriptor, cmOverride bool) (args st
                 args = fmt.Sprintf("%s --set ricapp.appconfig.override=%s-appconfig", args, *x.XappName)
         }
 
-        if x.OverrideFile != nil {
-                if overrideYaml, err := yaml.JSONToYAML([]byte(x.OverrideFile.(string))); err == nil {
-                        err = ioutil.WriteFile("/tmp/appmgr_override.yaml", overrideYaml, 0644)
-                        if err != nil {
-                                appmgr.Logger.Info("ioutil.WriteFile(/tmp/appmgr_override.yaml) failed: %v", err)
-                        } else {
-                                args = args + " -f=/tmp/appmgr_override.yaml"
-                        }
-                } else {
-                        appmgr.Logger.Info("yaml.JSONToYAML failed: %v", err)
+        args = args + h.getOverrideArgs(x)
+
+        repoName := getRepoName()
+        if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
+                appmgr.Logger.Info ("GetInstallArgs last: Version 3")
+                return fmt.Sprintf("install %s %s/%s %s",*x.XappName, repoName, *x.XappName, args)
+        } else {
+                appmgr.Logger.Info ("GetInstallArgs last: Version 2")
+                return fmt.Sprintf("install %s/%s %s", repoName, *x.XappName, args)
+        }
+}
+
+func (h *Helm) GetUpgradeArgs(x models.XappDescriptor) (args string) {
+        args = fmt.Sprintf("--namespace=%s", x.Namespace)
+        if x.HelmVersion != "" {
+                args = fmt.Sprintf("%s --version=%s", args, x.HelmVersion)
+        }
+        args = args + h.getOverrideArgs(x)
+
+        release := *x.XappName
+        if cm.EnvHelmVersion == cm.HELM_VERSION_2 && x.ReleaseName != "" {
+                release = x.ReleaseName
+        }
+        return fmt.Sprintf("upgrade %s %s/%s %s", release, getRepoName(), *x.XappName, args)
+}
+
+func (h *Helm) getOverrideArgs(x models.XappDescriptor) string {
+        if x.OverrideFile == nil {
+                return ""
+        }
+
+        overrideJson, ok := x.OverrideFile.(string)
+        if !ok {
+                b, err := json.Marshal(x.OverrideFile)
+                if err != nil {
+                        appmgr.Logger.Info("json.Marshal of override values failed: %v", err)
+                        return ""
                 }
+                overrideJson = string(b)
         }
 
+        overrideYaml, err := yaml.JSONToYAML([]byte(overrideJson))
+        if err != nil {
+                appmgr.Logger.Info("yaml.JSONToYAML failed: %v", err)
+                return ""
+        }
+
+        if err = ioutil.WriteFile("/tmp/appmgr_override.yaml", overrideYaml, 0644); err != nil {
+                appmgr.Logger.Info("ioutil.WriteFile(/tmp/appmgr_override.yaml) failed: %v", err)
+                return ""
+        }
+        return " -f=/tmp/appmgr_override.yaml"
+}
+
+func getRepoName() string {
         repoName := viper.GetString("helm.repo-name")
         if repoName == "" {
                 repoName = "helm-repo"
         }
+        return repoName
+}
 
-        if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
-                appmgr.Logger.Info ("GetInstallArgs last: Version 3")
-                return fmt.Sprintf("install %s %s/%s %s",*x.XappName, repoName, *x.XappName, args)
-        } else {
-                appmgr.Logger.Info ("GetInstallArgs last: Version 2")
-                return fmt.Sprintf("install %s/%s %s", repoName, *x.XappName, args)
+// ValidateName rejects release names that are not valid DNS-1123 labels
+func ValidateName(name string) error {
+        if len(name) > 53 || !releaseNameRe.MatchString(name) {
+                return fmt.Errorf("invalid xApp name '%s'", name)
+        }
+        return nil
+}
+
+func ValidateDescriptor(x models.XappDescriptor) error {
+        if x.XappName == nil {
+                return errors.New("xApp name missing")
+        }
+        if err := ValidateName(*x.XappName); err != nil {
+                return err
+        }
+        if x.ReleaseName != "" {
+                if err := ValidateName(x.ReleaseName); err != nil {
+                        return err
+                }
+        }
+        if err := ValidateName(x.Namespace); err != nil {
+                return fmt.Errorf("invalid namespace '%s'", x.Namespace)
+        }
+        if !chartVersionRe.MatchString(x.HelmVersion) {
+                return fmt.Errorf("invalid chart version '%s'", x.HelmVersion)
         }
+        return nil
 }
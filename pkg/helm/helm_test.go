@@ -20,6 +20,7 @@
 package helm
 
 import (
+        "context"
         "errors"
         "github.com/spf13/viper"
         "os"
@@ -28,6 +29,9 @@ import (
         "strings"
         "testing"
 	"github.com/stretchr/testify/assert"
+        corev1 "k8s.io/api/core/v1"
+        metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+        "k8s.io/client-go/kubernetes/fake"
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/cm"
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
         "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
@@ -38,6 +42,7 @@ var caughtKubeExecArgs string
 var kubeExecRetOut string
 var kubeExecRetErr error
 var caughtHelmExecArgs string
+var caughtHelmExecArgv []string
 var helmExecRetOut string
 var helmExecRetErr error
 var helmStatusOutput = `
@@ -147,6 +152,8 @@ var kubeServiceOutput = `{
 func TestMain(m *testing.M) {
         appmgr.Init()
         appmgr.Logger.SetLevel(0)
+        // The SDK backend would talk to the cluster of the local kubeconfig
+        viper.Set("helm.backend", "cli")
 	
         code := m.Run()
         os.Exit(code)
@@ -173,6 +180,7 @@ func TestAddRepoSuccess(t *testing.T) {
                 removeTestPasswordFile()
         }()
         helmExec = mockedHelmExec
+        helmExecArgs = mockedHelmExecArgs
 
         if err := writeTestUsernameFile(); err != nil {
                 t.Errorf("AddRepo username file create failed: %s", err)
@@ -190,6 +198,8 @@ func TestAddRepoSuccess(t *testing.T) {
         if !strings.Contains(caughtHelmExecArgs, "repo add") {
                 t.Errorf("AddRepo failed: expected %v, got %v", "repo add", caughtHelmExecArgs)
         }
+        assert.Equal(t, []string{"repo", "add", viper.GetString("helm.repo-name"), viper.GetString("helm.repo"),
+                "--username", "some-username", "--password", "some-password"}, caughtHelmExecArgv)
 	NewHelm().initDone = true
 	NewHelm().Initialize()
 }
@@ -203,11 +213,11 @@ func TestFuncsWithHelmv3(t *testing.T){
 	}           
 	helm := NewHelm()
 	
-        xapp, err := helm.Status(name)
+        xapp, err := helm.Status(name, "")
         if err == nil {
                 t.Logf("Status returned: %v", err)
         }
-        xapp2, err := helm.Delete(name)
+        xapp2, err := helm.Delete(name, "")
         if err != nil {
 	 	assert.NotEqual(t, err, "Error: release: not found")		
         }else{
@@ -215,7 +225,7 @@ func TestFuncsWithHelmv3(t *testing.T){
 	}
 	helm.Init()
 	
-	if version := helm.GetVersion(name); version != "" {
+	if version := helm.GetVersion(name, ""); version != "" {
                 t.Logf("GetVersion expected to return empty string, got %v", version)
         }
 	
@@ -289,7 +299,7 @@ func TestStatusSuccess(t *testing.T) {
         helmExec = mockedHelmExec
         helmExecRetOut = helmStatusOutput
 
-        xapp, err := NewHelm().Status(name)
+        xapp, err := NewHelm().Status(name, "")
         if err != nil {
                 t.Errorf("Status failed: %v", err)
         }
@@ -303,7 +313,7 @@ func TestStatusReturnsErrorIfHelmStatusFails(t *testing.T) {
         helmExec = mockedHelmExec
         helmExecRetErr = errors.New("some helm command error")
 
-        if _, err := NewHelm().Status(name); err == nil {
+        if _, err := NewHelm().Status(name, ""); err == nil {
                 t.Errorf("Status expected to fail but it didn't")
         }else{
 	 	assert.Equal(t, err, helmExecRetErr)		
@@ -320,7 +330,7 @@ func TestParseStatusSuccess(t *testing.T) {
         kubeExec = mockedKubeExec
         kubeExecRetOut = kubeServiceOutput
 
-        xapp, err := NewHelm().ParseStatus("dummy-xapp", helmStatusOutput)
+        xapp, err := NewHelm().ParseStatus("dummy-xapp", "", helmStatusOutput)
         if err != nil {
                 t.Errorf("ParseStatus failed: %v", err)
         }
@@ -342,7 +352,7 @@ func TestListSuccess(t *testing.T) {
         helmExec = mockedHelmExec
         helmExecRetOut = helListAllOutput
 
-        names, err := NewHelm().List()
+        names, err := NewHelm().List("")
         if err != nil {
                 t.Errorf("List failed: %v", err)
         }
@@ -369,7 +379,7 @@ func TestListReturnsErrorIfHelmListFails(t *testing.T) {
         helmExec = mockedHelmExec
         helmExecRetErr = errors.New("some helm command error")
 
-        if _, err := NewHelm().List(); err == nil {
+        if _, err := NewHelm().List(""); err == nil {
                 t.Errorf("List expected to fail but it didn't")
         }
 
@@ -387,7 +397,7 @@ func TestDeleteSuccess(t *testing.T) {
         kubeExec = mockedKubeExec
         kubeExecRetOut = kubeServiceOutput
 
-        xapp, err := NewHelm().Delete(name)
+        xapp, err := NewHelm().Delete(name, "")
         if err != nil {
                 t.Errorf("Delete failed: %v", err)
         }
@@ -411,15 +421,23 @@ func TestDeleteReturnsErrorIfHelmStatusFails(t *testing.T) {
         helmExec = mockedHelmExec
         helmExecRetErr = errors.New("some helm command error")
 
-        if _, err := NewHelm().Delete(name); err == nil {
+        if _, err := NewHelm().Delete(name, ""); err == nil {
                 t.Errorf("Delete expected to fail but it didn't")
         }
 }
 
-func TestFetchSuccessIfCmdArgHasTestSuffix(t *testing.T) {
-        if err := NewHelm().Fetch("lsfuis", "../../helm_chart/appmgr/values.yaml"); err != nil {
+func TestFetchSuccess(t *testing.T) {
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+
+        if err := NewHelm().Fetch("lsfuis", "/tmp"); err != nil {
                 t.Errorf("Fetch failed: %v", err)
         }
+
+        expectedHelmCommand := "fetch --untar --untardir /tmp helm-repo/lsfuis"
+        if caughtHelmExecArgs != expectedHelmCommand {
+                t.Errorf("Fetch failed: expected %v, got %v", expectedHelmCommand, caughtHelmExecArgs)
+        }
 }
 
 func TestGetVersionSuccess(t *testing.T) {
@@ -428,7 +446,7 @@ func TestGetVersionSuccess(t *testing.T) {
         helmExec = mockedHelmExec
         helmExecRetOut = helListOutput
 
-        if version := NewHelm().GetVersion("dummy-xapp"); version != "1.0" {
+        if version := NewHelm().GetVersion("dummy-xapp", ""); version != "1.0" {
                 t.Errorf("GetVersion failed: expected 1.0, got %v", version)
         }
 
@@ -442,12 +460,22 @@ func TestGetVersionSuccess(t *testing.T) {
         }
 }
 
+func TestDeployedChartVersionFromRelease(t *testing.T) {
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+        helmExecRetOut = helListOutput
+
+        if version := NewHelm().CM().DeployedChartVersion("dummy-xapp", ""); version != "0.1.0" {
+                t.Errorf("DeployedChartVersion failed: expected 0.1.0, got %v", version)
+        }
+}
+
 func TestGetVersionReturnsEmptyStringIfHelmListFails(t *testing.T) {
         defer func() { resetHelmExecMock() }()
         helmExec = mockedHelmExec
         helmExecRetErr = errors.New("some helm command error")
 
-        if version := NewHelm().GetVersion("dummy-xapp"); version != "" {
+        if version := NewHelm().GetVersion("dummy-xapp", ""); version != "" {
                 t.Errorf("GetVersion expected to return empty string, got %v", version)
         }
 }
@@ -467,7 +495,7 @@ func TestGetEndpointInfoSuccess(t *testing.T) {
         kubeExec = mockedKubeExec
         kubeExecRetOut = kubeServiceOutput
 
-        svc, port := NewHelm().GetEndpointInfo("dummy-xapp")
+        svc, port := NewHelm().GetEndpointInfo("dummy-xapp", "")
 	
         expectedSvc := "service-ricxapp-dummy-xapp-rmr.ricxapp"
         if svc != expectedSvc {
@@ -487,7 +515,7 @@ func TestGetEndpointInfoReturnsDefaultPortIfJsonParseFails(t *testing.T) {
         kubeExec = mockedKubeExec
         kubeExecRetOut = "not-json-syntax"
 
-        svc, port := NewHelm().GetEndpointInfo("dummy-xapp")
+        svc, port := NewHelm().GetEndpointInfo("dummy-xapp", "")
         expectedSvc := "service-ricxapp-dummy-xapp-rmr.ricxapp"
         if svc != expectedSvc {
                 t.Errorf("GetEndpointInfo failed: expected %v, got %v", expectedSvc, svc)
@@ -502,7 +530,7 @@ func TestGetEndpointInfoReturnsDefaultPortIfKubeGetServiceFails(t *testing.T) {
         kubeExec = mockedKubeExec
         kubeExecRetErr = errors.New("some helm command error")
 
-        svc, port := NewHelm().GetEndpointInfo("dummy-xapp")
+        svc, port := NewHelm().GetEndpointInfo("dummy-xapp", "")
         expectedSvc := "service-ricxapp-dummy-xapp-rmr.ricxapp"
         if svc != expectedSvc {
                 t.Errorf("GetEndpointInfo failed: expected %v, got %v", expectedSvc, svc)
@@ -512,12 +540,33 @@ func TestGetEndpointInfoReturnsDefaultPortIfKubeGetServiceFails(t *testing.T) {
         }
 }
 
+func TestGetEndpointInfoWithKubeClientSuccess(t *testing.T) {
+        service := &corev1.Service{
+                ObjectMeta: metav1.ObjectMeta{Name: "service-ricxapp-dummy-xapp-rmr", Namespace: "ricxapp"},
+                Spec: corev1.ServiceSpec{
+                        Ports: []corev1.ServicePort{{Name: "rmrroute", Port: 4561}, {Name: "rmrdata", Port: 4570}},
+                },
+        }
+        h := NewHelm()
+        h.kube = cm.NewKubeClientForClientset(fake.NewSimpleClientset(service))
+
+        svc, port := h.GetEndpointInfo("dummy-xapp", "")
+        if svc != "service-ricxapp-dummy-xapp-rmr.ricxapp" || port != 4570 {
+                t.Errorf("GetEndpointInfo failed: got %v %v", svc, port)
+        }
+
+        svc, port = h.GetEndpointInfo("other-xapp", "")
+        if svc != "service-ricxapp-other-xapp-rmr.ricxapp" || port != 4560 {
+                t.Errorf("GetEndpointInfo failed: got %v %v", svc, port)
+        }
+}
+
 func TestHelmStatusAllSuccess(t *testing.T) {
         defer func() { resetHelmExecMock() }()
         helmExec = mockedHelmExec
         helmExecRetOut = helListAllOutput
 
-        if _, err := NewHelm().StatusAll(); err != nil {
+        if _, err := NewHelm().StatusAll(""); err != nil {
                 t.Errorf("StatusAll failed: %v", err)
         }
         // Todo: check StatusAll response content
@@ -528,7 +577,7 @@ func TestStatusAllReturnsErrorIfHelmListFails(t *testing.T) {
         helmExec = mockedHelmExec
         helmExecRetErr = errors.New("some helm command error")
 
-        if _, err := NewHelm().StatusAll(); err == nil {
+        if _, err := NewHelm().StatusAll(""); err == nil {
                 t.Errorf("StatusAll expected to fail but it didn't")
         }
 }
@@ -607,6 +656,209 @@ func TestGetInstallArgs(t *testing.T) {
         }
 }
 
+func TestUpgradeSuccess(t *testing.T) {
+        name := "dummy-xapp"
+        xappDesc := models.XappDescriptor{XappName: &name, Namespace: "ricxapp", HelmVersion: "1.2.3"}
+
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+        helmExecRetOut = helmStatusOutput
+
+        defer func() { resetKubeExecMock() }()
+        kubeExec = mockedKubeExec
+        kubeExecRetOut = kubeServiceOutput
+
+        h := NewHelm()
+        expectedArgs := "upgrade dummy-xapp helm-repo/dummy-xapp --namespace=ricxapp --version=1.2.3"
+        if args := h.GetUpgradeArgs(xappDesc); args != expectedArgs {
+                t.Errorf("GetUpgradeArgs failed: expected %v, got %v", expectedArgs, args)
+        }
+
+        xapp, err := h.Upgrade(xappDesc)
+        if err != nil {
+                t.Errorf("Upgrade failed: %v", err)
+        }
+        validateXappModel(t, xapp)
+}
+
+func TestUpgradeReturnsErrorIfHelmUpgradeFails(t *testing.T) {
+        name := "dummy-xapp"
+        xappDesc := models.XappDescriptor{XappName: &name, Namespace: "ricxapp"}
+
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+        helmExecRetErr = errors.New("some helm command error")
+
+        if _, err := NewHelm().Upgrade(xappDesc); err == nil {
+                t.Errorf("Upgrade expected to fail but it didn't")
+        }
+}
+
+func TestRollbackSuccess(t *testing.T) {
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+        helmExecRetOut = helmStatusOutput
+
+        defer func() { resetKubeExecMock() }()
+        kubeExec = mockedKubeExec
+        kubeExecRetOut = kubeServiceOutput
+
+        b := &cliBackend{h: NewHelm()}
+        if err := b.Rollback(context.Background(), "dummy-xapp", "ricxapp", 2); err != nil {
+                t.Errorf("Rollback failed: %v", err)
+        }
+
+        expectedHelmCommand := "rollback dummy-xapp 2"
+        if cm.EnvHelmVersion == cm.HELM_VERSION_3 {
+                expectedHelmCommand += " --namespace ricxapp"
+        }
+        if caughtHelmExecArgs != expectedHelmCommand {
+                t.Errorf("Rollback failed: expected %v, got %v", expectedHelmCommand, caughtHelmExecArgs)
+        }
+
+        xapp, err := NewHelm().Rollback("dummy-xapp", "", 0)
+        if err != nil {
+                t.Errorf("Rollback failed: %v", err)
+        }
+        validateXappModel(t, xapp)
+
+        if _, err := NewHelm().Rollback("dummy-xapp", "", -1); err == nil {
+                t.Errorf("Rollback expected to fail but it didn't")
+        }
+}
+
+func TestHistorySuccess(t *testing.T) {
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+        helmExecRetOut = `[{"revision":1,"updated":"2020-06-10T10:00:00.123+00:00","status":"superseded","chart":"dummy-xapp-0.1.0","app_version":"1.0","description":"Install complete"},` +
+                `{"revision":2,"updated":"2020-06-11T10:00:00Z","status":"deployed","chart":"dummy-xapp-0.2.0","app_version":"1.1","description":"Upgrade complete"}]`
+
+        history, err := NewHelm().History("dummy-xapp", "")
+        if err != nil {
+                t.Errorf("History failed: %v", err)
+        }
+        if len(history) != 2 {
+                t.Errorf("History failed: expected 2 revisions, got %v", history)
+                return
+        }
+        assert.Equal(t, models.XappRevision{Revision: 2, ChartVersion: "0.2.0", AppVersion: "1.1", Status: "deployed",
+                Updated: "2020-06-11T10:00:00Z", Description: "Upgrade complete"}, *history[1])
+}
+
+func TestHistoryReturnsErrorIfHelmHistoryFails(t *testing.T) {
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+        helmExecRetErr = errors.New("some helm command error")
+
+        if _, err := NewHelm().History("dummy-xapp", ""); err == nil {
+                t.Errorf("History expected to fail but it didn't")
+        }
+}
+
+func TestInstallReturnsErrorIfNameIsInvalid(t *testing.T) {
+        name := "dummy-xapp; rm -rf /"
+        xappDesc := models.XappDescriptor{XappName: &name, Namespace: "ricxapp"}
+
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+
+        if _, err := NewHelm().Install(xappDesc); err == nil {
+                t.Errorf("Install expected to fail but it didn't")
+        }
+        if caughtHelmExecArgs != "" {
+                t.Errorf("Install expected not to run helm, got %v", caughtHelmExecArgs)
+        }
+}
+
+func TestStatusInManagedNamespaceSuccess(t *testing.T) {
+        viper.Set("xapp.namespaces", []string{"trialxapp"})
+        defer viper.Set("xapp.namespaces", nil)
+
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+        helmExecRetOut = helmStatusOutput
+
+        defer func() { resetKubeExecMock() }()
+        kubeExec = mockedKubeExec
+        kubeExecRetOut = kubeServiceOutput
+
+        xapp, err := NewHelm().Status("dummy-xapp", "trialxapp")
+        if err != nil {
+                t.Errorf("Status failed: %v", err)
+        }
+        assert.Equal(t, "trialxapp", xapp.Namespace)
+        assert.Equal(t, "service-trialxapp-dummy-xapp-rmr.trialxapp", xapp.Instances[0].IP)
+
+        expectedKubeCommand := " get service -n trialxapp service-trialxapp-dummy-xapp-rmr -o json"
+        if caughtKubeExecArgs != expectedKubeCommand {
+                t.Errorf("Status failed: expected %v, got %v", expectedKubeCommand, caughtKubeExecArgs)
+        }
+}
+
+func TestStatusReturnsErrorIfNamespaceIsNotManaged(t *testing.T) {
+        defer func() { resetHelmExecMock() }()
+        helmExec = mockedHelmExec
+
+        if _, err := NewHelm().Status("dummy-xapp", "kube-system"); err == nil {
+                t.Errorf("Status expected to fail but it didn't")
+        }
+        if caughtHelmExecArgs != "" {
+                t.Errorf("Status expected not to run helm, got %v", caughtHelmExecArgs)
+        }
+
+        name := "dummy-xapp"
+        if _, err := NewHelm().Install(models.XappDescriptor{XappName: &name, Namespace: "kube-system"}); err == nil {
+                t.Errorf("Install expected to fail but it didn't")
+        }
+}
+
+func TestValidateDescriptor(t *testing.T) {
+        name := "dummy-xapp"
+        x := models.XappDescriptor{XappName: &name, Namespace: "ricxapp", HelmVersion: "1.2.3"}
+        assert.Nil(t, ValidateDescriptor(x))
+
+        x.Namespace = "ricxapp --kubeconfig=/tmp/x"
+        assert.NotNil(t, ValidateDescriptor(x))
+
+        x.Namespace = "ricxapp"
+        x.HelmVersion = "1.2.3 && ls"
+        assert.NotNil(t, ValidateDescriptor(x))
+
+        x.HelmVersion = ""
+        x.ReleaseName = "Ueec_Xapp"
+        assert.NotNil(t, ValidateDescriptor(x))
+
+        assert.NotNil(t, ValidateDescriptor(models.XappDescriptor{Namespace: "ricxapp"}))
+}
+
+func TestParsePods(t *testing.T) {
+        pods := parsePods("dummy-xapp", helmStatusOutput)
+        if len(pods) != 3 {
+                t.Errorf("parsePods failed: expected 3 pods, got %v", pods)
+                return
+        }
+        assert.Equal(t, appmgr.Pod{Name: "dummy-xapp-8984fc9fd-bkcbp", Status: "Running"}, pods[0])
+}
+
+func TestOverrideValues(t *testing.T) {
+        name := "dummy-xapp"
+        x := models.XappDescriptor{XappName: &name, Namespace: "ricxapp"}
+
+        vals, err := overrideValues(x)
+        assert.Nil(t, err)
+        assert.Equal(t, 0, len(vals))
+
+        x.OverrideFile = `{"image": {"tag": "1.0.1"}}`
+        vals, err = overrideValues(x)
+        assert.Nil(t, err)
+        assert.Equal(t, map[string]interface{}{"tag": "1.0.1"}, vals["image"])
+
+        x.OverrideFile = map[string]interface{}{"replicaCount": 2}
+        vals, err = overrideValues(x)
+        assert.Nil(t, err)
+        assert.Equal(t, 2, vals["replicaCount"])
+}
+
 func writeTestUsernameFile() error {
         f, err := os.Create(viper.GetString("helm.helm-username-file"))
         if err != nil {
@@ -685,8 +937,15 @@ func mockedHelmExec(args string) (out []byte, err error) {
         return []byte(helmExecRetOut), helmExecRetErr
 }
 
+func mockedHelmExecArgs(args ...string) (out []byte, err error) {
+        caughtHelmExecArgv = args
+        return mockedHelmExec(strings.Join(args, " "))
+}
+
 func resetHelmExecMock() {
         helmExec = util.HelmExec
+        helmExecArgs = util.HelmExecArgs
+        caughtHelmExecArgv = nil
         caughtHelmExecArgs = ""
         helmExecRetOut = ""
         helmExecRetErr = nil
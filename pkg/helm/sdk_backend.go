@@ -0,0 +1,350 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/helmpath"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/repo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/cm"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+// sdkBackend manages releases natively through the Helm v3 SDK, so no helm
+// binary is executed and release data is read from typed objects
+type sdkBackend struct {
+	settings  *cli.EnvSettings
+	clientset kubernetes.Interface
+}
+
+func newSDKBackend() (*sdkBackend, error) {
+	settings := cli.New()
+
+	restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &sdkBackend{settings: settings, clientset: clientset}, nil
+}
+
+func (b *sdkBackend) actionConfig(namespace string) (*action.Configuration, error) {
+	cfg := new(action.Configuration)
+	clientGetter := kube.GetConfig(b.settings.KubeConfig, b.settings.KubeContext, namespace)
+	err := cfg.Init(clientGetter, namespace, os.Getenv("HELM_DRIVER"), func(format string, v ...interface{}) {
+		appmgr.Logger.Debug(format, v...)
+	})
+	return cfg, err
+}
+
+func (b *sdkBackend) AddRepo(name, url, username, password string) error {
+	entry := &repo.Entry{Name: name, URL: url, Username: username, Password: password}
+	if err := b.downloadIndex(entry); err != nil {
+		return err
+	}
+
+	repoFile := b.settings.RepositoryConfig
+	if err := os.MkdirAll(filepath.Dir(repoFile), 0755); err != nil {
+		return err
+	}
+
+	f, err := repo.LoadFile(repoFile)
+	if err != nil {
+		f = repo.NewFile()
+	}
+	f.Update(entry)
+	return f.WriteFile(repoFile, 0644)
+}
+
+func (b *sdkBackend) UpdateRepos() error {
+	f, err := repo.LoadFile(b.settings.RepositoryConfig)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range f.Repositories {
+		if err := b.downloadIndex(entry); err != nil {
+			return err
+		}
+	}
+	cm.InvalidateLatestCharts()
+	return nil
+}
+
+func (b *sdkBackend) downloadIndex(entry *repo.Entry) error {
+	r, err := repo.NewChartRepository(entry, getter.All(b.settings))
+	if err != nil {
+		return err
+	}
+	r.CachePath = b.settings.RepositoryCache
+
+	if _, err = r.DownloadIndexFile(); err != nil {
+		appmgr.Logger.Info("Downloading index of helm repo '%s' failed: %v", entry.Name, err)
+		return err
+	}
+	return nil
+}
+
+func (b *sdkBackend) Install(ctx context.Context, x models.XappDescriptor) (*appmgr.Release, error) {
+	if err := b.UpdateRepos(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := b.actionConfig(x.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	client := action.NewInstall(cfg)
+	client.ReleaseName = *x.XappName
+	client.Namespace = x.Namespace
+	client.Version = x.HelmVersion
+
+	ch, err := b.loadChart(&client.ChartPathOptions, *x.XappName)
+	if err != nil {
+		return nil, err
+	}
+
+	vals, err := overrideValues(x)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := client.Run(ch, vals)
+	if err != nil {
+		return nil, err
+	}
+	return b.withPods(toRelease(rel)), nil
+}
+
+func (b *sdkBackend) Upgrade(ctx context.Context, x models.XappDescriptor) (*appmgr.Release, error) {
+	if err := b.UpdateRepos(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := b.actionConfig(x.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	client := action.NewUpgrade(cfg)
+	client.Namespace = x.Namespace
+	client.Version = x.HelmVersion
+
+	ch, err := b.loadChart(&client.ChartPathOptions, *x.XappName)
+	if err != nil {
+		return nil, err
+	}
+
+	vals, err := overrideValues(x)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := client.Run(*x.XappName, ch, vals)
+	if err != nil {
+		return nil, err
+	}
+	return b.withPods(toRelease(rel)), nil
+}
+
+func (b *sdkBackend) Uninstall(ctx context.Context, name, namespace string) error {
+	cfg, err := b.actionConfig(namespace)
+	if err != nil {
+		return err
+	}
+
+	_, err = action.NewUninstall(cfg).Run(name)
+	return err
+}
+
+func (b *sdkBackend) Rollback(ctx context.Context, name, namespace string, revision int) error {
+	cfg, err := b.actionConfig(namespace)
+	if err != nil {
+		return err
+	}
+
+	client := action.NewRollback(cfg)
+	client.Version = revision
+	return client.Run(name)
+}
+
+func (b *sdkBackend) History(name, namespace string) (releases []*appmgr.Release, err error) {
+	cfg, err := b.actionConfig(namespace)
+	if err != nil {
+		return
+	}
+
+	client := action.NewHistory(cfg)
+	client.Max = 256
+
+	rels, err := client.Run(name)
+	if err != nil {
+		return
+	}
+
+	for _, rel := range rels {
+		releases = append(releases, toRelease(rel))
+	}
+	return
+}
+
+func (b *sdkBackend) Status(name, namespace string) (*appmgr.Release, error) {
+	cfg, err := b.actionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := action.NewStatus(cfg).Run(name)
+	if err != nil {
+		return nil, err
+	}
+	return b.withPods(toRelease(rel)), nil
+}
+
+func (b *sdkBackend) List(namespace string) (releases []*appmgr.Release, err error) {
+	cfg, err := b.actionConfig(namespace)
+	if err != nil {
+		return
+	}
+
+	client := action.NewList(cfg)
+	client.Deployed = true
+	client.SetStateMask()
+
+	rels, err := client.Run()
+	if err != nil {
+		return
+	}
+
+	for _, rel := range rels {
+		releases = append(releases, toRelease(rel))
+	}
+	return
+}
+
+func (b *sdkBackend) Search() (names []string, err error) {
+	indexFile := filepath.Join(b.settings.RepositoryCache, helmpath.CacheIndexFile(getRepoName()))
+	index, err := repo.LoadIndexFile(indexFile)
+	if err != nil {
+		return
+	}
+
+	for name := range index.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func (b *sdkBackend) Fetch(name, version, dir string) error {
+	cfg, err := b.actionConfig(b.settings.Namespace())
+	if err != nil {
+		return err
+	}
+
+	client := action.NewPullWithOpts(action.WithConfig(cfg))
+	client.Settings = b.settings
+	client.Version = version
+	client.DestDir = dir
+	client.Untar = true
+	client.UntarDir = dir
+
+	_, err = client.Run(getRepoName() + "/" + name)
+	return err
+}
+
+func (b *sdkBackend) loadChart(opts *action.ChartPathOptions, name string) (*chart.Chart, error) {
+	chartPath, err := opts.LocateChart(getRepoName()+"/"+name, b.settings)
+	if err != nil {
+		return nil, err
+	}
+	return loader.Load(chartPath)
+}
+
+// withPods adds the pods of the release, which helm itself doesn't report
+func (b *sdkBackend) withPods(rel *appmgr.Release) *appmgr.Release {
+	opts := metav1.ListOptions{LabelSelector: "release=" + rel.Name}
+	pods, err := b.clientset.CoreV1().Pods(rel.Namespace).List(context.Background(), opts)
+	if err != nil {
+		appmgr.Logger.Info("Listing pods of release '%s' failed: %v", rel.Name, err)
+		return rel
+	}
+
+	for _, p := range pods.Items {
+		rel.Pods = append(rel.Pods, appmgr.Pod{Name: p.Name, Status: string(p.Status.Phase)})
+	}
+	return rel
+}
+
+func toRelease(r *release.Release) *appmgr.Release {
+	rel := &appmgr.Release{Name: r.Name, Namespace: r.Namespace, Revision: r.Version}
+	if r.Chart != nil && r.Chart.Metadata != nil {
+		rel.Chart = r.Chart.Metadata.Name
+		rel.Version = r.Chart.Metadata.Version
+		rel.AppVersion = r.Chart.Metadata.AppVersion
+	}
+	if r.Info != nil {
+		rel.Status = r.Info.Status.String()
+		rel.Updated = r.Info.LastDeployed.Time
+		rel.Description = r.Info.Description
+	}
+	return rel
+}
+
+// overrideValues converts the override file of the descriptor into chart values
+func overrideValues(x models.XappDescriptor) (vals map[string]interface{}, err error) {
+	vals = map[string]interface{}{}
+
+	switch v := x.OverrideFile.(type) {
+	case nil:
+	case map[string]interface{}:
+		vals = v
+	case string:
+		err = yaml.Unmarshal([]byte(v), &vals)
+	default:
+		var data []byte
+		if data, err = json.Marshal(v); err == nil {
+			err = json.Unmarshal(data, &vals)
+		}
+	}
+	return
+}
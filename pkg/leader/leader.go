@@ -0,0 +1,253 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package leader elects one of the appmgr replicas with a Kubernetes Lease.
+// The leader handles the writes and runs the background tasks, the other
+// replicas serve the reads and forward the writes to it.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+)
+
+const (
+	defaultLeaseName     = "appmgr"
+	defaultNamespace     = "ricplt"
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+
+	namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// ErrLeadershipLost is returned by Run when the lease could not be renewed
+var ErrLeadershipLost = errors.New("leadership lost")
+
+// Elector takes part in the election of the Lease. The identity of a replica
+// is the address the other replicas forward the writes to. The leader is
+// tracked from the election callbacks, since the record of the client-go
+// elector is not safe for concurrent reads.
+type Elector struct {
+	identity string
+	config   leaderelection.LeaderElectionConfig
+
+	mutex   sync.Mutex
+	lead    func(ctx context.Context)
+	leader  string
+	leading bool
+	elected bool
+}
+
+// Durations of the Lease, see the client-go leaderelection package
+type Durations struct {
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// NewFromConfig returns an Elector for the 'leaderElection' section, or nil if
+// leader election is disabled and this replica handles everything
+func NewFromConfig() (*Elector, error) {
+	if !viper.GetBool("leaderElection.enabled") {
+		return nil, nil
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := viper.GetString("leaderElection.namespace")
+	if namespace == "" {
+		namespace = podNamespace()
+	}
+	name := viper.GetString("leaderElection.leaseName")
+	if name == "" {
+		name = defaultLeaseName
+	}
+	identity := viper.GetString("leaderElection.identity")
+	if identity == "" {
+		identity = defaultIdentity()
+	}
+	durations := Durations{
+		LeaseDuration: time.Duration(viper.GetInt("leaderElection.leaseDuration")) * time.Second,
+		RenewDeadline: time.Duration(viper.GetInt("leaderElection.renewDeadline")) * time.Second,
+		RetryPeriod:   time.Duration(viper.GetInt("leaderElection.retryPeriod")) * time.Second,
+	}
+	return New(clientset.CoordinationV1(), namespace, name, identity, durations)
+}
+
+// New returns an Elector for the Lease namespace/name, e.g. with a fake client in tests
+func New(client coordinationv1.LeasesGetter, namespace, name, identity string, durations Durations) (*Elector, error) {
+	if durations.LeaseDuration <= 0 {
+		durations.LeaseDuration = defaultLeaseDuration
+	}
+	if durations.RenewDeadline <= 0 {
+		durations.RenewDeadline = defaultRenewDeadline
+	}
+	if durations.RetryPeriod <= 0 {
+		durations.RetryPeriod = defaultRetryPeriod
+	}
+
+	e := &Elector{identity: identity}
+	e.config = leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Client:     client,
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration:   durations.LeaseDuration,
+		RenewDeadline:   durations.RenewDeadline,
+		RetryPeriod:     durations.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: e.startedLeading,
+			OnStoppedLeading: e.stoppedLeading,
+			OnNewLeader:      e.newLeader,
+		},
+	}
+
+	// Validates the config
+	if _, err := leaderelection.NewLeaderElector(e.config); err != nil {
+		return nil, fmt.Errorf("invalid leader election config: %v", err)
+	}
+	return e, nil
+}
+
+// Identity returns the identity of this replica
+func (e *Elector) Identity() string {
+	return e.identity
+}
+
+// IsLeader tells whether this replica holds the Lease
+func (e *Elector) IsLeader() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.leading
+}
+
+// Leader returns the identity of the leader last seen, empty if there is none
+func (e *Elector) Leader() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.leader
+}
+
+// Run campaigns for the Lease until ctx is done, calling lead with a context
+// that ends with the leadership once this replica is elected. Cancelling ctx
+// releases the Lease, so that another replica takes over at once. A leader that
+// fails to renew the Lease is not elected again: Run then returns ErrLeadershipLost.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) error {
+	elector, err := leaderelection.NewLeaderElector(e.config)
+	if err != nil {
+		return err
+	}
+	e.mutex.Lock()
+	e.lead = lead
+	e.mutex.Unlock()
+
+	elector.Run(ctx)
+	if e.wasElected() && ctx.Err() == nil {
+		return ErrLeadershipLost
+	}
+	return nil
+}
+
+func (e *Elector) startedLeading(ctx context.Context) {
+	appmgr.Logger.Info("Elected as the leader: %s", e.identity)
+	e.mutex.Lock()
+	e.leader, e.leading, e.elected = e.identity, true, true
+	lead := e.lead
+	e.mutex.Unlock()
+
+	lead(ctx)
+}
+
+// stoppedLeading is also called when Run ends without having been elected
+func (e *Elector) stoppedLeading() {
+	e.mutex.Lock()
+	leading := e.leading
+	e.leading = false
+	// Released or lost, the next leader is not known yet
+	if e.leader == e.identity {
+		e.leader = ""
+	}
+	e.mutex.Unlock()
+
+	if leading {
+		appmgr.Logger.Info("No longer the leader: %s", e.identity)
+	}
+}
+
+func (e *Elector) newLeader(identity string) {
+	e.mutex.Lock()
+	e.leader = identity
+	e.mutex.Unlock()
+
+	if identity != e.identity {
+		appmgr.Logger.Info("New leader: %s", identity)
+	}
+}
+
+func (e *Elector) wasElected() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.elected
+}
+
+// defaultIdentity is the address of the REST API of this pod: POD_IP, set
+// from the downward API, or else the host name
+func defaultIdentity() string {
+	host := os.Getenv("POD_IP")
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return host + ":8080"
+}
+
+func podNamespace() string {
+	if data, err := ioutil.ReadFile(namespaceFile); err == nil {
+		if ns := strings.TrimSpace(string(data)); ns != "" {
+			return ns
+		}
+	}
+	return defaultNamespace
+}
@@ -0,0 +1,138 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package leader
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+)
+
+var testDurations = Durations{
+	LeaseDuration: time.Second,
+	RenewDeadline: 500 * time.Millisecond,
+	RetryPeriod:   100 * time.Millisecond,
+}
+
+func TestMain(m *testing.M) {
+	appmgr.Init()
+	appmgr.Logger.SetLevel(0)
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestOneReplicaIsElected(t *testing.T) {
+	client := fake.NewSimpleClientset().CoordinationV1()
+	first, firstElected, stopFirst, firstDone := startTestElector(t, client, "10.0.0.1:8080")
+	defer stopFirst()
+	waitElected(t, firstElected)
+
+	second, secondElected, stopSecond, _ := startTestElector(t, client, "10.0.0.2:8080")
+	defer stopSecond()
+
+	assert.Eventually(t, func() bool { return second.Leader() == first.Identity() }, 5*time.Second, 50*time.Millisecond)
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+	assert.Equal(t, first.Identity(), first.Leader())
+
+	select {
+	case <-secondElected:
+		t.Fatal("second replica elected while the first one holds the lease")
+	case <-time.After(2 * testDurations.LeaseDuration):
+	}
+
+	stopFirst()
+	assert.Nil(t, <-firstDone)
+}
+
+func TestCancelReleasesLease(t *testing.T) {
+	client := fake.NewSimpleClientset().CoordinationV1()
+	first, firstElected, stopFirst, firstDone := startTestElector(t, client, "10.0.0.1:8080")
+	waitElected(t, firstElected)
+
+	second, secondElected, stopSecond, _ := startTestElector(t, client, "10.0.0.2:8080")
+	defer stopSecond()
+	assert.Eventually(t, func() bool { return second.Leader() == first.Identity() }, 5*time.Second, 50*time.Millisecond)
+
+	stopFirst()
+	assert.Nil(t, <-firstDone)
+	assert.False(t, first.IsLeader())
+
+	// Taken over at once, not after the lease duration
+	select {
+	case <-secondElected:
+	case <-time.After(testDurations.LeaseDuration / 2):
+		t.Fatal("lease not taken over by the second replica")
+	}
+	assert.True(t, second.IsLeader())
+}
+
+func TestNewRejectsInvalidDurations(t *testing.T) {
+	client := fake.NewSimpleClientset().CoordinationV1()
+	_, err := New(client, "ricplt", "appmgr", "10.0.0.1:8080", Durations{LeaseDuration: time.Second, RenewDeadline: 2 * time.Second})
+	assert.NotNil(t, err)
+
+	e, err := New(client, "ricplt", "appmgr", "10.0.0.1:8080", Durations{})
+	assert.Nil(t, err)
+	assert.False(t, e.IsLeader())
+	assert.Equal(t, "", e.Leader())
+}
+
+func TestDefaultIdentityIsPodAddress(t *testing.T) {
+	defer func(ip string) { os.Setenv("POD_IP", ip) }(os.Getenv("POD_IP"))
+	os.Setenv("POD_IP", "10.244.0.17")
+
+	assert.Equal(t, "10.244.0.17:8080", defaultIdentity())
+}
+
+// startTestElector runs an Elector for the Lease ricplt/appmgr. The elected
+// channel is closed when it starts leading, done gets the result of Run.
+func startTestElector(t *testing.T, client coordinationv1.LeasesGetter, identity string) (e *Elector, elected chan struct{}, stop func(), done chan error) {
+	e, err := New(client, "ricplt", "appmgr", identity, testDurations)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	elected, done = make(chan struct{}), make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		done <- e.Run(ctx, func(ctx context.Context) {
+			close(elected)
+			<-ctx.Done()
+		})
+	}()
+	return e, elected, cancel, done
+}
+
+func waitElected(t *testing.T, elected chan struct{}) {
+	select {
+	case <-elected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not elected")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Default is the registry served at /ric/v1/metrics
+var Default = NewRegistry()
+
+var (
+	Xapps = Default.NewGaugeVec("appmgr_xapps",
+		"Number of xApps, deployed by helm or registered through the API, per namespace and status",
+		"namespace", "status", "source")
+
+	RestRequestDuration = Default.NewHistogramVec("appmgr_rest_request_duration_seconds",
+		"Latency of the REST requests by operation and HTTP status code", DefaultBuckets, "operation", "code")
+
+	CommandDuration = Default.NewHistogramVec("appmgr_command_duration_seconds",
+		"Duration of helm and kubectl commands, including retries", DefaultBuckets, "command")
+	CommandFailures = Default.NewCounterVec("appmgr_command_failures_total",
+		"Number of helm and kubectl commands that failed after all retries", "command")
+
+	SdlDuration = Default.NewHistogramVec("appmgr_sdl_operation_duration_seconds",
+		"Latency of the SDL operations", DefaultBuckets, "operation")
+	SdlFailures = Default.NewCounterVec("appmgr_sdl_operation_failures_total",
+		"Number of failed SDL operations", "operation")
+
+	WebhookAttempts = Default.NewCounterVec("appmgr_webhook_delivery_attempts_total",
+		"Number of attempts to post a notification to a subscriber")
+	WebhookFailures = Default.NewCounterVec("appmgr_webhook_delivery_failures_total",
+		"Number of failed attempts to post a notification to a subscriber")
+	WebhookDeadLetters = Default.NewCounterVec("appmgr_webhook_dead_letters_total",
+		"Number of notifications moved to the dead-letter store")
+	WebhookQueueDepth = Default.NewGaugeVec("appmgr_webhook_queue_depth",
+		"Number of notifications waiting in the delivery queue of each subscription", "subscription")
+)
+
+// Since returns the seconds elapsed since start, for observing durations
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// Storage is the part of the SDL SyncStorage API used by appmgr
+type Storage interface {
+	Set(ns string, pairs ...interface{}) error
+	Get(ns string, keys []string) (map[string]interface{}, error)
+	GetAll(ns string) ([]string, error)
+	Remove(ns string, keys []string) error
+	RemoveAll(ns string) error
+}
+
+// InstrumentSdl returns a Storage that records the latency and failures of every operation on db
+func InstrumentSdl(db Storage) Storage {
+	return &timedStorage{db}
+}
+
+type timedStorage struct {
+	db Storage
+}
+
+func (s *timedStorage) Set(ns string, pairs ...interface{}) error {
+	defer observeSdl("set", time.Now())
+	return countSdlFailure("set", s.db.Set(ns, pairs...))
+}
+
+func (s *timedStorage) Get(ns string, keys []string) (map[string]interface{}, error) {
+	defer observeSdl("get", time.Now())
+	values, err := s.db.Get(ns, keys)
+	return values, countSdlFailure("get", err)
+}
+
+func (s *timedStorage) GetAll(ns string) ([]string, error) {
+	defer observeSdl("getall", time.Now())
+	keys, err := s.db.GetAll(ns)
+	return keys, countSdlFailure("getall", err)
+}
+
+func (s *timedStorage) Remove(ns string, keys []string) error {
+	defer observeSdl("remove", time.Now())
+	return countSdlFailure("remove", s.db.Remove(ns, keys))
+}
+
+func (s *timedStorage) RemoveAll(ns string) error {
+	defer observeSdl("removeall", time.Now())
+	return countSdlFailure("removeall", s.db.RemoveAll(ns))
+}
+
+// WithContext returns the storage for the operations done within ctx
+func (s *timedStorage) WithContext(ctx context.Context) Storage {
+	return &timedStorage{WithContext(ctx, s.db)}
+}
+
+// Close closes the underlying SDL client
+func (s *timedStorage) Close() error {
+	return CloseStorage(s.db)
+}
+
+// WithContext binds db to ctx, so that its operations are traced within the request
+// of ctx, if db or the storage it wraps makes use of the context
+func WithContext(ctx context.Context, db Storage) Storage {
+	if c, ok := db.(interface{ WithContext(context.Context) Storage }); ok {
+		return c.WithContext(ctx)
+	}
+	return db
+}
+
+// CloseStorage closes db if it, or the SDL client it wraps, can be closed
+func CloseStorage(db interface{}) error {
+	if c, ok := db.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func observeSdl(operation string, start time.Time) {
+	SdlDuration.Observe(Since(start), operation)
+}
+
+func countSdlFailure(operation string, err error) error {
+	if err != nil {
+		SdlFailures.Inc(operation)
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_failures_total", "Failures", "command")
+	g := r.NewGaugeVec("test_queue_depth", "Queued \"items\"", "queue")
+	h := r.NewHistogramVec("test_duration_seconds", "Duration", []float64{0.1, 1})
+
+	c.Inc("helm")
+	c.Add(2, "helm")
+	c.Inc("kubectl")
+	g.Set(3, `a"b`)
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var b bytes.Buffer
+	assert.Nil(t, r.WriteText(&b))
+	assert.Equal(t, `# HELP test_failures_total Failures
+# TYPE test_failures_total counter
+test_failures_total{command="helm"} 3
+test_failures_total{command="kubectl"} 1
+# HELP test_queue_depth Queued "items"
+# TYPE test_queue_depth gauge
+test_queue_depth{queue="a\"b"} 3
+# HELP test_duration_seconds Duration
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 5.55
+test_duration_seconds_count 3
+`, b.String())
+}
+
+func TestCollectorsRunBeforeWrite(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("test_xapps", "xApps", "namespace")
+	g.Set(1, "gone")
+
+	r.OnCollect(func() {
+		g.Reset()
+		g.Set(2, "ricxapp")
+	})
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/ric/v1/metrics", nil))
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `test_xapps{namespace="ricxapp"} 2`)
+	assert.NotContains(t, w.Body.String(), "gone")
+}
+
+func TestWrongNumberOfLabelValuesPanics(t *testing.T) {
+	c := NewRegistry().NewCounterVec("test_total", "Test", "a", "b")
+	assert.Panics(t, func() { c.Inc("a") })
+}
+
+type failingStorage struct {
+	Storage
+}
+
+func (s failingStorage) Set(ns string, pairs ...interface{}) error {
+	return errors.New("no connection")
+}
+
+func TestInstrumentSdl(t *testing.T) {
+	count, failures := SdlDuration.Count("set"), SdlFailures.Value("set")
+
+	assert.NotNil(t, InstrumentSdl(failingStorage{}).Set("appmgr", "key", "value"))
+	assert.Equal(t, count+1, SdlDuration.Count("set"))
+	assert.Equal(t, failures+1, SdlFailures.Value("set"))
+}
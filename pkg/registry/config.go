@@ -0,0 +1,179 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/certs"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
+)
+
+const (
+	defaultConfigPushTimeout = 5 * time.Second
+	maxConfigPushErrorLength = 256
+)
+
+// ConfigPusher writes configuration to registered xApps that serve their own
+// config, and records the acknowledgement of every instance in the registry
+type ConfigPusher struct {
+	registry *Registry
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewConfigPusher returns a ConfigPusher configured from the 'xapp.configPush' section
+func NewConfigPusher(r *Registry) *ConfigPusher {
+	timeout := time.Duration(viper.GetInt("xapp.configPush.timeout")) * time.Second
+	if timeout <= 0 {
+		timeout = defaultConfigPushTimeout
+	}
+	return &ConfigPusher{registry: r, client: &http.Client{Timeout: timeout, Transport: tracing.Transport(certs.Transport())}, now: time.Now}
+}
+
+// ConfigurableInstances returns the registered instances of an xApp that serve
+// their own config, i.e. did not pass it in the registration request
+func (r *Registry) ConfigurableInstances(namespace, appName string) []Instance {
+	return r.filter(func(i *Instance) bool {
+		return i.Namespace == namespace && i.AppName == appName && !i.DynamicConfig && i.HTTPEndpoint != ""
+	})
+}
+
+// Push PUTs the config to the config path of every configurable instance of the
+// xApp in parallel. The returned list holds the outcome of each instance.
+func (p *ConfigPusher) Push(namespace, appName string, config interface{}) (models.ConfigPushStatusList, error) {
+	return p.PushContext(context.Background(), namespace, appName, config)
+}
+
+// PushContext is Push within the trace of ctx, which is passed on to the xApps
+func (p *ConfigPusher) PushContext(ctx context.Context, namespace, appName string, config interface{}) (models.ConfigPushStatusList, error) {
+	name, ns := appName, namespace
+	body, err := json.Marshal(models.XAppConfig{
+		Metadata: &models.ConfigMetadata{XappName: &name, Namespace: &ns},
+		Config:   config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	instances := p.registry.ConfigurableInstances(namespace, appName)
+	result := make(models.ConfigPushStatusList, len(instances))
+
+	var wg sync.WaitGroup
+	for n, i := range instances {
+		wg.Add(1)
+		go func(n int, i Instance) {
+			defer wg.Done()
+			result[n] = p.push(ctx, i, body)
+		}(n, i)
+	}
+	wg.Wait()
+
+	return result, nil
+}
+
+// Statuses returns the outcome of the latest config push to each configurable instance of the xApp
+func (p *ConfigPusher) Statuses(namespace, appName string) models.ConfigPushStatusList {
+	result := models.ConfigPushStatusList{}
+	for _, i := range p.registry.ConfigurableInstances(namespace, appName) {
+		result = append(result, toPushStatus(i))
+	}
+	return result
+}
+
+func (p *ConfigPusher) push(ctx context.Context, i Instance, body []byte) *models.ConfigPushStatus {
+	status, code, message := p.put(ctx, i, body)
+	if status == models.ConfigPushStatusStatusAccepted {
+		appmgr.Logger.Info("Config of %s/%s accepted by %s", i.Namespace, i.AppName, i.InstanceName)
+	} else {
+		appmgr.Logger.Error("Config of %s/%s %s by %s: %s", i.Namespace, i.AppName, status, i.InstanceName, message)
+	}
+
+	now := p.now()
+	updated, err := p.registry.Update(i.Namespace, i.AppName, i.InstanceName, func(i *Instance) error {
+		i.ConfigStatus = status
+		i.ConfigStatusCode = code
+		i.ConfigError = message
+		i.ConfigUpdated = now
+		return nil
+	})
+	if err != nil {
+		// Deregistered meanwhile, or the DB is down: report the outcome anyway
+		appmgr.Logger.Error("Recording config status of %s/%s failed: %v", i.AppName, i.InstanceName, err)
+		i.ConfigStatus, i.ConfigStatusCode, i.ConfigError, i.ConfigUpdated = status, code, message, now
+		return toPushStatus(i)
+	}
+	return toPushStatus(updated)
+}
+
+func (p *ConfigPusher) put(ctx context.Context, i Instance, body []byte) (status string, code int, message string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, certs.XappURL(i.HTTPEndpoint, i.ConfigPath), bytes.NewReader(body))
+	if err != nil {
+		return models.ConfigPushStatusStatusUnreachable, 0, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return models.ConfigPushStatusStatusUnreachable, 0, err.Error()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return models.ConfigPushStatusStatusAccepted, resp.StatusCode, ""
+	}
+
+	text, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxConfigPushErrorLength))
+	message = fmt.Sprintf("HTTP status %d", resp.StatusCode)
+	if len(text) != 0 {
+		message = fmt.Sprintf("%s: %s", message, bytes.TrimSpace(text))
+	}
+	return models.ConfigPushStatusStatusRejected, resp.StatusCode, message
+}
+
+func toPushStatus(i Instance) *models.ConfigPushStatus {
+	name, status := i.InstanceName, i.ConfigStatus
+	if status == "" {
+		status = models.ConfigPushStatusStatusUnknown
+	}
+	s := &models.ConfigPushStatus{
+		InstanceName: &name,
+		Status:       &status,
+		StatusCode:   int64(i.ConfigStatusCode),
+		Error:        i.ConfigError,
+	}
+	if !i.ConfigUpdated.IsZero() {
+		s.Timestamp = strfmt.DateTime(i.ConfigUpdated.UTC())
+	}
+	return s
+}
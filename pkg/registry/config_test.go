@@ -0,0 +1,109 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+func TestConfigurableInstancesSkipsDynamicConfig(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1"))
+	dynamic := generateInstance("dummy-xapp", "dummy-xapp-2")
+	dynamic.DynamicConfig = true
+	reg.Add(dynamic)
+	reg.Add(generateInstance("other-xapp", "other-xapp-1"))
+
+	instances := reg.ConfigurableInstances("ricxapp", "dummy-xapp")
+	assert.Equal(t, 1, len(instances))
+	assert.Equal(t, "dummy-xapp-1", instances[0].InstanceName)
+}
+
+func TestPushRecordsAcknowledgementPerInstance(t *testing.T) {
+	var received models.XAppConfig
+	accepting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPut, req.Method)
+		assert.Equal(t, "/ric/v1/config", req.URL.Path)
+		json.NewDecoder(req.Body).Decode(&received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer accepting.Close()
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "threshold out of range", http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	p := newTestConfigPusher(t, accepting.URL, rejecting.URL, "127.0.0.1:1")
+
+	result, err := p.Push("ricxapp", "dummy-xapp", map[string]interface{}{"threshold": 5.0})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(result))
+	assert.Equal(t, models.ConfigPushStatusStatusAccepted, *result[0].Status)
+	assert.Equal(t, models.ConfigPushStatusStatusRejected, *result[1].Status)
+	assert.Equal(t, int64(http.StatusBadRequest), result[1].StatusCode)
+	assert.Equal(t, "HTTP status 400: threshold out of range", result[1].Error)
+	assert.Equal(t, models.ConfigPushStatusStatusUnreachable, *result[2].Status)
+
+	assert.Equal(t, "dummy-xapp", *received.Metadata.XappName)
+	assert.Equal(t, map[string]interface{}{"threshold": 5.0}, received.Config)
+
+	i, _ := p.registry.Get("ricxapp", "dummy-xapp", "dummy-xapp-2")
+	assert.Equal(t, models.ConfigPushStatusStatusRejected, i.ConfigStatus)
+	assert.Equal(t, result, p.Statuses("ricxapp", "dummy-xapp"))
+}
+
+func TestStatusesBeforeFirstPush(t *testing.T) {
+	p := newTestConfigPusher(t, "http://127.0.0.1:1")
+
+	result := p.Statuses("ricxapp", "dummy-xapp")
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, models.ConfigPushStatusStatusUnknown, *result[0].Status)
+
+	assert.Equal(t, models.ConfigPushStatusList{}, p.Statuses("ricxapp", "no-xapp"))
+}
+
+// newTestConfigPusher registers an instance of dummy-xapp for each endpoint, named dummy-xapp-1, -2, ...
+func newTestConfigPusher(t *testing.T, endpoints ...string) *ConfigPusher {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	for n, e := range endpoints {
+		i := generateInstance("dummy-xapp", "dummy-xapp-"+string(rune('1'+n)))
+		i.HTTPEndpoint = strings.TrimPrefix(e, "http://")
+		i.ConfigPath = "/ric/v1/config"
+		assert.Nil(t, reg.Add(i))
+	}
+
+	return &ConfigPusher{registry: reg, client: &http.Client{Timeout: time.Second}, now: time.Now}
+}
@@ -0,0 +1,219 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/certs"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+// Liveness states of a registered instance. A registered instance starts as
+// 'deployed' and is 'gone' once it has been deregistered by the Monitor.
+const (
+	StatusDeployed  = "deployed"
+	StatusRunning   = "running"
+	StatusUnhealthy = "unhealthy"
+	StatusGone      = "gone"
+)
+
+const (
+	healthAlivePath           = "/ric/v1/health/alive"
+	defaultUnhealthyThreshold = 3
+	defaultGracePeriod        = 60 * time.Second
+)
+
+// errUnchanged makes Registry.Update skip the write when a check changes nothing
+var errUnchanged = errors.New("unchanged")
+
+// NewMonitor returns a Monitor configured from the 'xapp.healthCheck' section.
+// Each state transition is passed to notify, e.g. Resthook.PublishSubscription.
+func NewMonitor(r *Registry, notify func(models.Xapp, models.EventType)) *Monitor {
+	m := &Monitor{
+		registry:    r,
+		interval:    time.Duration(viper.GetInt("xapp.healthCheck.interval")) * time.Second,
+		threshold:   viper.GetInt("xapp.healthCheck.unhealthyThreshold"),
+		gracePeriod: time.Duration(viper.GetInt("xapp.healthCheck.gracePeriod")) * time.Second,
+		notify:      notify,
+		now:         time.Now,
+	}
+
+	timeout := time.Duration(viper.GetInt("xapp.healthCheck.timeout")) * time.Second
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	m.client = &http.Client{Timeout: timeout, Transport: certs.Transport()}
+
+	if m.threshold <= 0 {
+		m.threshold = defaultUnhealthyThreshold
+	}
+	if m.gracePeriod <= 0 {
+		m.gracePeriod = defaultGracePeriod
+	}
+	return m
+}
+
+// Run checks all instances every interval until stop is closed. A zero interval disables probing.
+func (m *Monitor) Run(stop <-chan struct{}) {
+	if m.interval <= 0 {
+		appmgr.Logger.Info("xApp health check disabled")
+		return
+	}
+
+	appmgr.Logger.Info("xApp health check every %v, unhealthy after %d failures, grace period %v", m.interval, m.threshold, m.gracePeriod)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.CheckAll()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// CheckAll probes every registered instance once
+func (m *Monitor) CheckAll() {
+	for _, i := range m.registry.List() {
+		m.check(i.Namespace, i.AppName, i.InstanceName, m.probe(i))
+	}
+}
+
+// Heartbeat records an explicit sign of life from an instance
+func (m *Monitor) Heartbeat(namespace, appName, instanceName string) error {
+	changed := false
+	now := m.now()
+	i, err := m.registry.Update(namespace, appName, instanceName, func(i *Instance) error {
+		i.LastHeartbeat = now
+		changed = m.markAlive(i)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		appmgr.Logger.Info("xApp instance %s/%s is now %s", appName, instanceName, i.Status)
+		m.publish(i, models.EventTypeModified)
+	}
+	return nil
+}
+
+func (m *Monitor) check(namespace, appName, instanceName string, alive bool) {
+	var event models.EventType
+	now := m.now()
+
+	i, err := m.registry.Update(namespace, appName, instanceName, func(i *Instance) error {
+		if alive || (!i.LastHeartbeat.IsZero() && now.Sub(i.LastHeartbeat) <= m.interval) {
+			if !m.markAlive(i) {
+				return errUnchanged
+			}
+			event = models.EventTypeModified
+			return nil
+		}
+
+		i.FailedProbes++
+		switch {
+		case i.Status == StatusGone:
+			// An earlier deregistration failed, try again
+			event = models.EventTypeUndeployed
+		case i.Status != StatusUnhealthy && i.FailedProbes >= m.threshold:
+			setStatus(i, StatusUnhealthy)
+			i.UnhealthySince = now
+			event = models.EventTypeModified
+		case i.Status == StatusUnhealthy && now.Sub(i.UnhealthySince) >= m.gracePeriod:
+			setStatus(i, StatusGone)
+			event = models.EventTypeUndeployed
+		}
+		return nil
+	})
+	if err != nil {
+		if err != errUnchanged && err != ErrNotFound {
+			appmgr.Logger.Error("Updating liveness of %s/%s failed: %v", appName, instanceName, err)
+		}
+		return
+	}
+
+	if i.Status == StatusGone {
+		appmgr.Logger.Info("xApp instance %s/%s unhealthy for %v, deregistering", appName, instanceName, m.gracePeriod)
+		if i, err = m.registry.Remove(namespace, appName, instanceName); err != nil {
+			appmgr.Logger.Error("Deregistering %s/%s failed: %v", appName, instanceName, err)
+			return
+		}
+		setStatus(&i, StatusGone)
+	}
+
+	if event != "" {
+		appmgr.Logger.Info("xApp instance %s/%s is now %s", appName, instanceName, i.Status)
+		m.publish(i, event)
+	}
+}
+
+// markAlive resets the failure bookkeeping and returns true if the status changed
+func (m *Monitor) markAlive(i *Instance) bool {
+	changed := i.Status != StatusRunning
+	if !changed && i.FailedProbes == 0 {
+		return false
+	}
+
+	i.FailedProbes = 0
+	i.UnhealthySince = time.Time{}
+	setStatus(i, StatusRunning)
+	return changed
+}
+
+func (m *Monitor) probe(i Instance) bool {
+	resp, err := m.client.Get(certs.XappURL(i.HTTPEndpoint, healthAlivePath))
+	if err != nil {
+		appmgr.Logger.Debug("Health probe of %s/%s failed: %v", i.AppName, i.InstanceName, err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+func (m *Monitor) publish(i Instance, event models.EventType) {
+	if m.notify == nil {
+		return
+	}
+
+	name := i.AppName
+	x := models.Xapp{Name: &name, Namespace: i.Namespace, Status: i.Status, Version: i.AppVersion}
+	if i.Instance != nil {
+		x.Instances = append(x.Instances, i.Instance)
+	}
+	go m.notify(x, event)
+}
+
+func setStatus(i *Instance, status string) {
+	i.Status = status
+	if i.Instance != nil {
+		i.Instance.Status = status
+	}
+}
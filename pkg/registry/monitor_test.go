@@ -0,0 +1,159 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+type event struct {
+	xapp models.Xapp
+	et   models.EventType
+}
+
+func TestCheckAllMarksInstanceRunning(t *testing.T) {
+	m, events, status := newTestMonitor(t, http.StatusOK)
+	defer status.Close()
+
+	m.CheckAll()
+
+	i, _ := m.registry.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Equal(t, StatusRunning, i.Status)
+	assert.Equal(t, StatusRunning, i.Instance.Status)
+	e := <-events
+	assert.Equal(t, models.EventTypeModified, e.et)
+	assert.Equal(t, StatusRunning, e.xapp.Instances[0].Status)
+
+	// No change, no event
+	m.CheckAll()
+	assert.Equal(t, 0, len(events))
+}
+
+func TestCheckAllDeregistersDeadInstanceAfterGracePeriod(t *testing.T) {
+	m, events, status := newTestMonitor(t, http.StatusServiceUnavailable)
+	defer status.Close()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	m.CheckAll()
+	i, _ := m.registry.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Equal(t, StatusDeployed, i.Status)
+	assert.Equal(t, 1, i.FailedProbes)
+
+	m.CheckAll()
+	i, _ = m.registry.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Equal(t, StatusUnhealthy, i.Status)
+	assert.Equal(t, models.EventTypeModified, (<-events).et)
+
+	m.CheckAll()
+	_, found := m.registry.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.True(t, found)
+
+	now = now.Add(m.gracePeriod)
+	m.CheckAll()
+	_, found = m.registry.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.False(t, found)
+	e := <-events
+	assert.Equal(t, models.EventTypeUndeployed, e.et)
+	assert.Equal(t, StatusGone, e.xapp.Status)
+}
+
+func TestCheckAllRecoversUnhealthyInstance(t *testing.T) {
+	m, events, status := newTestMonitor(t, http.StatusServiceUnavailable)
+	defer status.Close()
+
+	m.CheckAll()
+	m.CheckAll()
+	assert.Equal(t, models.EventTypeModified, (<-events).et)
+
+	status.code = http.StatusOK
+	m.CheckAll()
+	i, _ := m.registry.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Equal(t, StatusRunning, i.Status)
+	assert.Equal(t, 0, i.FailedProbes)
+	assert.True(t, i.UnhealthySince.IsZero())
+	assert.Equal(t, models.EventTypeModified, (<-events).et)
+}
+
+func TestHeartbeatKeepsInstanceAlive(t *testing.T) {
+	m, events, status := newTestMonitor(t, http.StatusNotFound)
+	defer status.Close()
+
+	assert.Nil(t, m.Heartbeat("ricxapp", "dummy-xapp", "dummy-xapp-1"))
+	assert.Equal(t, models.EventTypeModified, (<-events).et)
+
+	m.CheckAll()
+	m.CheckAll()
+	i, _ := m.registry.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Equal(t, StatusRunning, i.Status)
+	assert.Equal(t, 0, len(events))
+}
+
+func TestHeartbeatReturnsErrorIfInstanceIsMissing(t *testing.T) {
+	m, _, status := newTestMonitor(t, http.StatusOK)
+	defer status.Close()
+
+	assert.Equal(t, ErrNotFound, m.Heartbeat("ricxapp", "dummy-xapp", "dummy-xapp-2"))
+}
+
+type statusServer struct {
+	*httptest.Server
+	code int
+}
+
+func newTestMonitor(t *testing.T, code int) (*Monitor, chan event, *statusServer) {
+	status := &statusServer{code: code}
+	status.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, healthAlivePath, req.URL.Path)
+		w.WriteHeader(status.code)
+	}))
+
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	mSdl.On("Remove", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	i := generateInstance("dummy-xapp", "dummy-xapp-1")
+	i.HTTPEndpoint = strings.TrimPrefix(status.URL, "http://")
+	reg.Add(i)
+
+	events := make(chan event, 10)
+	m := &Monitor{
+		registry:    reg,
+		client:      &http.Client{Timeout: time.Second},
+		interval:    time.Second,
+		threshold:   2,
+		gracePeriod: time.Minute,
+		notify: func(x models.Xapp, et models.EventType) {
+			events <- event{x, et}
+		},
+		now: time.Now,
+	}
+	return m, events, status
+}
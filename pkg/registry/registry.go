@@ -0,0 +1,311 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sdl "gerrit.o-ran-sc.org/r/ric-plt/sdlgo"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/metrics"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
+)
+
+//To encapsulate the registered xApp instances under their own namespace in a DB
+const (
+	appDbSdlNs        = "appdb"
+	instanceKeyPrefix = "instance:"
+	legacyKey         = "endpoints"
+)
+
+var ErrNotFound = errors.New("xApp instance not found")
+
+func NewRegistry(restoreData bool) *Registry {
+	return createRegistry(restoreData, metrics.InstrumentSdl(tracing.InstrumentSdl(sdl.NewSyncStorage())))
+}
+
+func createRegistry(restoreData bool, sdlInst iSdl) *Registry {
+	r := &Registry{
+		instances: make(map[string]*Instance),
+		db:        sdlInst,
+	}
+
+	if restoreData {
+		r.VerifyDBConnection()
+		if err := r.Restore(); err != nil {
+			appmgr.Logger.Error("Restoring xApp registry failed: %v", err)
+		}
+	}
+	return r
+}
+
+func instanceKey(namespace, appName, instanceName string) string {
+	return fmt.Sprintf("%s%s:%s:%s", instanceKeyPrefix, namespace, appName, instanceName)
+}
+
+// Add stores a new instance, or replaces an existing one with the same namespace, app and instance name
+func (r *Registry) Add(i Instance) error {
+	if i.Namespace == "" || i.AppName == "" || i.InstanceName == "" {
+		return errors.New("namespace, xApp and instance name are required")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.store(copyInstance(&i))
+}
+
+// Get returns a copy of the given instance
+func (r *Registry) Get(namespace, appName, instanceName string) (Instance, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if i, found := r.instances[instanceKey(namespace, appName, instanceName)]; found {
+		return *copyInstance(i), true
+	}
+	return Instance{}, false
+}
+
+// GetApp returns copies of all instances of the given xApp, sorted by instance name
+func (r *Registry) GetApp(namespace, appName string) []Instance {
+	return r.filter(func(i *Instance) bool {
+		return i.Namespace == namespace && i.AppName == appName
+	})
+}
+
+// List returns copies of all registered instances, sorted by namespace, xApp and instance name
+func (r *Registry) List() []Instance {
+	return r.filter(func(i *Instance) bool { return true })
+}
+
+// Update applies fn to the given instance and persists the result. The in-memory
+// view and SDL are left untouched if fn returns an error or the SDL write fails.
+func (r *Registry) Update(namespace, appName, instanceName string, fn func(*Instance) error) (Instance, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	i, found := r.instances[instanceKey(namespace, appName, instanceName)]
+	if !found {
+		return Instance{}, ErrNotFound
+	}
+
+	updated := copyInstance(i)
+	if err := fn(updated); err != nil {
+		return Instance{}, err
+	}
+	updated.Namespace, updated.AppName, updated.InstanceName = namespace, appName, instanceName
+
+	if err := r.store(updated); err != nil {
+		return Instance{}, err
+	}
+	return *copyInstance(updated), nil
+}
+
+// Remove deletes the given instance and returns what was stored
+func (r *Registry) Remove(namespace, appName, instanceName string) (Instance, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := instanceKey(namespace, appName, instanceName)
+	i, found := r.instances[key]
+	if !found {
+		return Instance{}, ErrNotFound
+	}
+
+	if err := r.db.Remove(appDbSdlNs, []string{key}); err != nil {
+		appmgr.Logger.Error("DB.session.Remove failed: %v ", err.Error())
+		return Instance{}, err
+	}
+
+	delete(r.instances, key)
+	return *i, nil
+}
+
+// Restore rebuilds the in-memory view from SDL, replacing whatever it held
+func (r *Registry) Restore() error {
+	keys, err := r.db.GetAll(appDbSdlNs)
+	if err != nil {
+		appmgr.Logger.Error("DB.session.GetAll failed: %v ", err.Error())
+		return err
+	}
+
+	var instanceKeys []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, instanceKeyPrefix) {
+			instanceKeys = append(instanceKeys, key)
+		}
+	}
+	sort.Strings(instanceKeys)
+
+	instances := make(map[string]*Instance)
+	if len(instanceKeys) != 0 {
+		values, err := r.db.Get(appDbSdlNs, instanceKeys)
+		if err != nil {
+			appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
+			return err
+		}
+
+		for _, key := range instanceKeys {
+			var i Instance
+			if err := json.Unmarshal(toBytes(values[key]), &i); err != nil || key != instanceKey(i.Namespace, i.AppName, i.InstanceName) {
+				appmgr.Logger.Error("Skipping invalid registry entry '%s': %v", key, err)
+				continue
+			}
+			instances[key] = &i
+		}
+	}
+
+	r.mutex.Lock()
+	r.instances = instances
+	r.mutex.Unlock()
+
+	appmgr.Logger.Info("Restored %d xApp instance(s) from DB", len(instances))
+	return nil
+}
+
+// TakeLegacyRequests returns the registrations stored by older releases as a single
+// space-separated string under the 'endpoints' key, and removes that key
+func (r *Registry) TakeLegacyRequests() (requests []models.RegisterRequest) {
+	value, err := r.db.Get(appDbSdlNs, []string{legacyKey})
+	if err != nil {
+		appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
+		return
+	}
+
+	data := strings.TrimSpace(string(toBytes(value[legacyKey])))
+	if data == "" {
+		return
+	}
+
+	for _, item := range strings.Fields(data) {
+		var req models.RegisterRequest
+		if err := json.Unmarshal([]byte(item), &req); err != nil || req.AppName == nil || req.AppInstanceName == nil {
+			appmgr.Logger.Error("Skipping invalid legacy registry entry: %s", item)
+			continue
+		}
+		requests = append(requests, req)
+	}
+
+	if err := r.db.Remove(appDbSdlNs, []string{legacyKey}); err != nil {
+		appmgr.Logger.Error("DB.session.Remove failed: %v ", err.Error())
+	}
+	return
+}
+
+func (r *Registry) VerifyDBConnection() {
+	// Test DB connection, and wait until ready!
+	for {
+		if _, err := r.db.GetAll(appDbSdlNs); err == nil {
+			return
+		}
+		appmgr.Logger.Error("Database connection not ready, waiting ...")
+		time.Sleep(time.Duration(5 * time.Second))
+	}
+}
+
+// Flush writes all the instances to SDL at once, e.g. before appmgr stops
+func (r *Registry) Flush() error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if len(r.instances) == 0 {
+		return nil
+	}
+	pairs := make([]interface{}, 0, 2*len(r.instances))
+	for key, i := range r.instances {
+		data, err := json.Marshal(i)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, key, data)
+	}
+	return r.db.Set(appDbSdlNs, pairs...)
+}
+
+// Close closes the SDL client of the registry
+func (r *Registry) Close() error {
+	return metrics.CloseStorage(r.db)
+}
+
+// store writes i to SDL and then to the in-memory view. Caller holds the write lock.
+func (r *Registry) store(i *Instance) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		appmgr.Logger.Error("json.marshal failed: %v ", err.Error())
+		return err
+	}
+
+	key := instanceKey(i.Namespace, i.AppName, i.InstanceName)
+	if err := r.db.Set(appDbSdlNs, key, data); err != nil {
+		appmgr.Logger.Error("DB.session.Set failed: %v ", err.Error())
+		return err
+	}
+
+	r.instances[key] = i
+	return nil
+}
+
+func (r *Registry) filter(match func(*Instance) bool) (list []Instance) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, i := range r.instances {
+		if match(i) {
+			list = append(list, *copyInstance(i))
+		}
+	}
+
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Namespace != list[b].Namespace {
+			return list[a].Namespace < list[b].Namespace
+		}
+		if list[a].AppName != list[b].AppName {
+			return list[a].AppName < list[b].AppName
+		}
+		return list[a].InstanceName < list[b].InstanceName
+	})
+	return
+}
+
+func copyInstance(i *Instance) *Instance {
+	c := *i
+	if i.Instance != nil {
+		x := *i.Instance
+		c.Instance = &x
+	}
+	return &c
+}
+
+func toBytes(v interface{}) []byte {
+	switch t := v.(type) {
+	case string:
+		return []byte(t)
+	case []byte:
+		return t
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+var mockSdlRetOk error
+
+// Test cases
+func TestMain(m *testing.M) {
+	appmgr.Init()
+	appmgr.Logger.SetLevel(0)
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestAddAndGetSuccess(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	assert.Nil(t, reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1")))
+
+	i, found := reg.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.True(t, found)
+	assert.Equal(t, "10.0.0.1:8080", i.HTTPEndpoint)
+	assert.Equal(t, "dummy-xapp-1", *i.Instance.Name)
+
+	_, found = reg.Get("ricxapp", "dummy-xapp", "dummy-xapp-2")
+	assert.False(t, found)
+	mSdl.AssertNumberOfCalls(t, "Set", 1)
+	pairs := mSdl.Calls[0].Arguments.Get(1).([]interface{})
+	assert.Equal(t, "instance:ricxapp:dummy-xapp:dummy-xapp-1", pairs[0])
+}
+
+func TestAddFailsIfSdlSetFails(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(errors.New("some SDL error"))
+	reg := createRegistry(false, mSdl)
+
+	assert.NotNil(t, reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1")))
+
+	_, found := reg.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.False(t, found)
+}
+
+func TestAddFailsIfNameIsMissing(t *testing.T) {
+	reg := createRegistry(false, new(SdlMock))
+
+	assert.NotNil(t, reg.Add(Instance{AppName: "dummy-xapp", InstanceName: "dummy-xapp-1"}))
+}
+
+func TestFlushWritesAllInstances(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+	assert.Nil(t, reg.Flush())
+	mSdl.AssertNumberOfCalls(t, "Set", 0)
+
+	reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1"))
+	reg.Add(generateInstance("dummy-xapp", "dummy-xapp-2"))
+	assert.Nil(t, reg.Flush())
+
+	pairs := mSdl.Calls[2].Arguments.Get(1).([]interface{})
+	assert.Equal(t, 4, len(pairs))
+	assert.ElementsMatch(t, []interface{}{"instance:ricxapp:dummy-xapp:dummy-xapp-1", "instance:ricxapp:dummy-xapp:dummy-xapp-2"}, []interface{}{pairs[0], pairs[2]})
+}
+
+func TestGetReturnsCopy(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+	reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1"))
+
+	i, _ := reg.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	i.Status = "modified"
+	i.Instance.Status = "modified"
+
+	i, _ = reg.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Equal(t, "deployed", i.Status)
+	assert.Equal(t, "deployed", i.Instance.Status)
+}
+
+func TestListIsSorted(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	reg.Add(generateInstance("xapp-b", "xapp-b-1"))
+	reg.Add(generateInstance("xapp-a", "xapp-a-2"))
+	reg.Add(generateInstance("xapp-a", "xapp-a-1"))
+
+	list := reg.List()
+	assert.Equal(t, 3, len(list))
+	assert.Equal(t, "xapp-a-1", list[0].InstanceName)
+	assert.Equal(t, "xapp-a-2", list[1].InstanceName)
+	assert.Equal(t, "xapp-b-1", list[2].InstanceName)
+	assert.Equal(t, 2, len(reg.GetApp("ricxapp", "xapp-a")))
+	assert.Equal(t, 0, len(reg.GetApp("ricxapp", "xapp-c")))
+}
+
+func TestSameAppInDifferentNamespaces(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	mSdl.On("Remove", appDbSdlNs, []string{"instance:trialxapp:dummy-xapp:dummy-xapp-1"}).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	trial := generateInstance("dummy-xapp", "dummy-xapp-1")
+	trial.Namespace = "trialxapp"
+	trial.HTTPEndpoint = "10.0.0.2:8080"
+	reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1"))
+	reg.Add(trial)
+
+	list := reg.List()
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, "ricxapp", list[0].Namespace)
+	assert.Equal(t, "trialxapp", list[1].Namespace)
+
+	_, err := reg.Remove("trialxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Nil(t, err)
+	i, found := reg.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.True(t, found)
+	assert.Equal(t, "10.0.0.1:8080", i.HTTPEndpoint)
+}
+
+func TestUpdateSuccess(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+	reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1"))
+
+	i, err := reg.Update("ricxapp", "dummy-xapp", "dummy-xapp-1", func(i *Instance) error {
+		i.Status = "failed"
+		i.InstanceName = "renamed"
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "failed", i.Status)
+	assert.Equal(t, "dummy-xapp-1", i.InstanceName)
+
+	i, _ = reg.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Equal(t, "failed", i.Status)
+	mSdl.AssertNumberOfCalls(t, "Set", 2)
+}
+
+func TestUpdateLeavesInstanceUntouchedIfFnFails(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+	reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1"))
+
+	_, err := reg.Update("ricxapp", "dummy-xapp", "dummy-xapp-1", func(i *Instance) error {
+		i.Status = "failed"
+		return errors.New("rejected")
+	})
+	assert.NotNil(t, err)
+
+	i, _ := reg.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Equal(t, "deployed", i.Status)
+	mSdl.AssertNumberOfCalls(t, "Set", 1)
+}
+
+func TestUpdateReturnsErrorIfInstanceIsMissing(t *testing.T) {
+	reg := createRegistry(false, new(SdlMock))
+
+	_, err := reg.Update("ricxapp", "dummy-xapp", "dummy-xapp-1", func(i *Instance) error { return nil })
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRemoveSuccess(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	mSdl.On("Remove", appDbSdlNs, []string{"instance:ricxapp:dummy-xapp:dummy-xapp-1"}).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+	reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1"))
+
+	i, err := reg.Remove("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1:8080", i.HTTPEndpoint)
+	assert.Equal(t, 0, len(reg.List()))
+
+	_, err = reg.Remove("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRemoveKeepsInstanceIfSdlRemoveFails(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	mSdl.On("Remove", appDbSdlNs, mock.Anything).Return(errors.New("some SDL error"))
+	reg := createRegistry(false, mSdl)
+	reg.Add(generateInstance("dummy-xapp", "dummy-xapp-1"))
+
+	_, err := reg.Remove("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.NotNil(t, err)
+
+	_, found := reg.Get("ricxapp", "dummy-xapp", "dummy-xapp-1")
+	assert.True(t, found)
+}
+
+func TestRestoreSuccess(t *testing.T) {
+	i1, _ := json.Marshal(generateInstance("dummy-xapp", "dummy-xapp-1"))
+	i2, _ := json.Marshal(generateInstance("dummy-xapp", "dummy-xapp-2"))
+	keys := []string{"instance:ricxapp:dummy-xapp:dummy-xapp-2", legacyKey, "instance:ricxapp:dummy-xapp:dummy-xapp-1"}
+	values := map[string]interface{}{
+		"instance:ricxapp:dummy-xapp:dummy-xapp-1": string(i1),
+		"instance:ricxapp:dummy-xapp:dummy-xapp-2": string(i2),
+	}
+
+	mSdl := new(SdlMock)
+	mSdl.On("GetAll", appDbSdlNs).Return(keys, mockSdlRetOk)
+	mSdl.On("Get", appDbSdlNs, []string{"instance:ricxapp:dummy-xapp:dummy-xapp-1", "instance:ricxapp:dummy-xapp:dummy-xapp-2"}).Return(values, mockSdlRetOk)
+	reg := createRegistry(true, mSdl)
+
+	list := reg.List()
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, "dummy-xapp-1", list[0].InstanceName)
+	assert.Equal(t, "dummy-xapp-2", list[1].InstanceName)
+}
+
+func TestRestoreSkipsInvalidEntries(t *testing.T) {
+	i1, _ := json.Marshal(generateInstance("dummy-xapp", "dummy-xapp-1"))
+	keys := []string{"instance:ricxapp:dummy-xapp:dummy-xapp-1", "instance:ricxapp:dummy-xapp:dummy-xapp-2"}
+	values := map[string]interface{}{
+		"instance:ricxapp:dummy-xapp:dummy-xapp-1": string(i1),
+		"instance:ricxapp:dummy-xapp:dummy-xapp-2": "{invalid",
+	}
+
+	mSdl := new(SdlMock)
+	mSdl.On("GetAll", appDbSdlNs).Return(keys, mockSdlRetOk)
+	mSdl.On("Get", appDbSdlNs, keys).Return(values, mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	assert.Nil(t, reg.Restore())
+	assert.Equal(t, 1, len(reg.List()))
+}
+
+func TestRestoreFailsIfSdlGetFails(t *testing.T) {
+	keys := []string{"instance:ricxapp:dummy-xapp:dummy-xapp-1"}
+
+	mSdl := new(SdlMock)
+	mSdl.On("GetAll", appDbSdlNs).Return(keys, mockSdlRetOk)
+	mSdl.On("Get", appDbSdlNs, keys).Return(map[string]interface{}{}, errors.New("some SDL error"))
+	reg := createRegistry(false, mSdl)
+
+	assert.NotNil(t, reg.Restore())
+	assert.Equal(t, 0, len(reg.List()))
+}
+
+func TestTakeLegacyRequestsSuccess(t *testing.T) {
+	r1, _ := json.Marshal(generateRegisterRequest("dummy-xapp", "dummy-xapp-1"))
+	r2, _ := json.Marshal(generateRegisterRequest("dummy-xapp", "dummy-xapp-2"))
+	values := map[string]interface{}{legacyKey: fmt.Sprintf("%s  %s invalid", r1, r2)}
+
+	mSdl := new(SdlMock)
+	mSdl.On("Get", appDbSdlNs, []string{legacyKey}).Return(values, mockSdlRetOk)
+	mSdl.On("Remove", appDbSdlNs, []string{legacyKey}).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	requests := reg.TakeLegacyRequests()
+	assert.Equal(t, 2, len(requests))
+	assert.Equal(t, "dummy-xapp-2", *requests[1].AppInstanceName)
+	mSdl.AssertCalled(t, "Remove", appDbSdlNs, []string{legacyKey})
+}
+
+func TestTakeLegacyRequestsReturnsNothingIfKeyIsMissing(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Get", appDbSdlNs, []string{legacyKey}).Return(map[string]interface{}{}, mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	assert.Equal(t, 0, len(reg.TakeLegacyRequests()))
+	mSdl.AssertNotCalled(t, "Remove", appDbSdlNs, mock.Anything)
+}
+
+func TestConcurrentAddAndRemove(t *testing.T) {
+	mSdl := new(SdlMock)
+	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	mSdl.On("Remove", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
+	reg := createRegistry(false, mSdl)
+
+	var wg sync.WaitGroup
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			name := fmt.Sprintf("dummy-xapp-%d", n)
+			reg.Add(generateInstance("dummy-xapp", name))
+			if n%2 == 0 {
+				reg.Remove("ricxapp", "dummy-xapp", name)
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, len(reg.GetApp("ricxapp", "dummy-xapp")))
+}
+
+func generateInstance(appName, instanceName string) Instance {
+	name := instanceName
+	return Instance{
+		Namespace:    "ricxapp",
+		AppName:      appName,
+		InstanceName: instanceName,
+		HTTPEndpoint: "10.0.0.1:8080",
+		RmrEndpoint:  "10.0.0.1:4560",
+		Status:       "deployed",
+		Instance:     &models.XappInstance{Name: &name, Status: "deployed", Port: 4560},
+	}
+}
+
+func generateRegisterRequest(appName, instanceName string) models.RegisterRequest {
+	httpEndpoint := "10.0.0.1:8080"
+	rmrEndpoint := "10.0.0.1:4560"
+	return models.RegisterRequest{
+		AppName:         &appName,
+		AppInstanceName: &instanceName,
+		HTTPEndpoint:    &httpEndpoint,
+		RmrEndpoint:     &rmrEndpoint,
+	}
+}
+
+type SdlMock struct {
+	mock.Mock
+}
+
+func (m *SdlMock) Set(ns string, pairs ...interface{}) error {
+	a := m.Called(ns, pairs)
+	return a.Error(0)
+}
+
+func (m *SdlMock) Get(ns string, keys []string) (map[string]interface{}, error) {
+	a := m.Called(ns, keys)
+	return a.Get(0).(map[string]interface{}), a.Error(1)
+}
+
+func (m *SdlMock) GetAll(ns string) ([]string, error) {
+	a := m.Called(ns)
+	return a.Get(0).([]string), a.Error(1)
+}
+
+func (m *SdlMock) Remove(ns string, keys []string) error {
+	a := m.Called(ns, keys)
+	return a.Error(0)
+}
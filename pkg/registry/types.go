@@ -0,0 +1,78 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package registry
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+// Instance is a registered xApp instance as stored in the registry and SDL
+type Instance struct {
+	Namespace     string               `json:"namespace"`
+	AppName       string               `json:"appName"`
+	InstanceName  string               `json:"instanceName"`
+	AppVersion    string               `json:"appVersion,omitempty"`
+	HTTPEndpoint  string               `json:"httpEndpoint"`
+	RmrEndpoint   string               `json:"rmrEndpoint"`
+	RmrServiceEp  string               `json:"rmrServiceEndpoint"`
+	ConfigPath    string               `json:"configPath,omitempty"`
+	DynamicConfig bool                 `json:"dynamicConfig"`
+	Status        string               `json:"status"`
+	Instance      *models.XappInstance `json:"instance"`
+
+	// Liveness bookkeeping, maintained by the Monitor
+	FailedProbes   int       `json:"failedProbes,omitempty"`
+	LastHeartbeat  time.Time `json:"lastHeartbeat,omitempty"`
+	UnhealthySince time.Time `json:"unhealthySince,omitempty"`
+
+	// Outcome of the latest config push, maintained by the ConfigPusher
+	ConfigStatus     string    `json:"configStatus,omitempty"`
+	ConfigStatusCode int       `json:"configStatusCode,omitempty"`
+	ConfigError      string    `json:"configError,omitempty"`
+	ConfigUpdated    time.Time `json:"configUpdated,omitempty"`
+}
+
+type Registry struct {
+	mutex     sync.RWMutex
+	instances map[string]*Instance
+	db        iSdl
+}
+
+type iSdl interface {
+	Set(ns string, pairs ...interface{}) error
+	Get(ns string, keys []string) (map[string]interface{}, error)
+	GetAll(ns string) ([]string, error)
+	Remove(ns string, keys []string) error
+}
+
+// Monitor probes the registered instances and deregisters the ones that stay dead
+type Monitor struct {
+	registry    *Registry
+	client      *http.Client
+	interval    time.Duration
+	threshold   int
+	gracePeriod time.Duration
+	notify      func(models.Xapp, models.EventType)
+	now         func() time.Time
+}
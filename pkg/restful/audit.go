@@ -0,0 +1,142 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restful
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/spf13/viper"
+	"github.com/valyala/fastjson"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+// auditOperations records every mutating request in the audit log, except for
+// the operations listed in 'audit.excludeOperations'
+func (r *Restful) auditOperations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		operation := operationID(req)
+		if !audited(req, operation) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		body := readBody(req)
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(sw, req)
+		r.recordAudit(req, operation, body, sw.code)
+	})
+}
+
+func audited(req *http.Request, operation string) bool {
+	return isMutating(req.Method) && !containsOperation(viper.GetStringSlice("audit.excludeOperations"), operation)
+}
+
+// readBody reads the body of req for the digest, and puts it back for the handler
+func readBody(req *http.Request) []byte {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		appmgr.Logger.Error("Reading request body of %s failed: %v", req.URL.Path, err)
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body
+}
+
+func (r *Restful) recordAudit(req *http.Request, operation string, body []byte, code int) {
+	record := models.AuditRecord{
+		Caller:        requestAuthor(req),
+		Source:        clientAddr(req),
+		Operation:     operation,
+		Method:        req.Method,
+		Path:          req.URL.Path,
+		RequestDigest: requestDigest(body),
+		StatusCode:    int64(code),
+		Outcome:       models.AuditRecordOutcomeSuccess,
+	}
+	if code >= http.StatusBadRequest {
+		record.Outcome = models.AuditRecordOutcomeFailure
+	}
+	record.XappName, record.Namespace = auditTarget(req, body)
+
+	if _, err := r.audit.AddContext(req.Context(), record); err != nil {
+		appmgr.Logger.Error("Recording %s by '%s' in the audit log failed: %v", operation, record.Caller, err)
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func containsOperation(operations []string, operation string) bool {
+	for _, o := range operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func requestDigest(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// auditTarget returns the xApp a request is about, taken from the path
+// parameters, or else from the body of deploy, config and registration requests
+func auditTarget(req *http.Request, body []byte) (name, namespace string) {
+	namespace = req.URL.Query().Get("namespace")
+	if route := middleware.MatchedRouteFrom(req); route != nil {
+		for _, param := range []string{"xAppName", "xappName"} {
+			if name = route.Params.Get(param); name != "" {
+				return
+			}
+		}
+	}
+
+	v, err := fastjson.ParseBytes(body)
+	if err != nil {
+		return
+	}
+	for _, path := range [][]string{{"xappName"}, {"appName"}, {"metadata", "xappName"}} {
+		if name = string(v.GetStringBytes(path...)); name != "" {
+			break
+		}
+	}
+	if namespace == "" {
+		namespace = string(v.GetStringBytes("namespace"))
+	}
+	if namespace == "" {
+		namespace = string(v.GetStringBytes("metadata", "namespace"))
+	}
+	return
+}
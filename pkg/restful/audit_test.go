@@ -0,0 +1,101 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restful
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auditlog"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+func TestAuditTargetFromPath(t *testing.T) {
+	r, audit, _ := newTestRestful(newTestAuthorizer(t), nil)
+
+	w := serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp?namespace=trialxapp", operatorToken, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	records, _ := audit.List(auditlog.Filter{})
+	if assert.Equal(t, 1, len(records)) {
+		assert.Equal(t, "ops-team", records[0].Caller)
+		assert.Equal(t, "192.0.2.1:1234", records[0].Source)
+		assert.Equal(t, "undeployXapp", records[0].Operation)
+		assert.Equal(t, http.MethodDelete, records[0].Method)
+		assert.Equal(t, "/ric/v1/xapps/dummy-xapp", records[0].Path)
+		assert.Equal(t, "dummy-xapp", records[0].XappName)
+		assert.Equal(t, "trialxapp", records[0].Namespace)
+		assert.Equal(t, int64(http.StatusNoContent), records[0].StatusCode)
+		assert.Equal(t, models.AuditRecordOutcomeSuccess, records[0].Outcome)
+		assert.Equal(t, "", records[0].RequestDigest)
+	}
+}
+
+func TestAuditTargetFromBody(t *testing.T) {
+	r, audit, handled := newTestRestful(newTestAuthorizer(t), nil)
+	body := `{"xappName": "dummy-xapp", "namespace": "trialxapp"}`
+
+	w := serveTestRequest(r, http.MethodPost, "/ric/v1/xapps", operatorToken, body)
+	// The body read for the digest is still there for the handler
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, *handled)
+
+	records, _ := audit.List(auditlog.Filter{})
+	if assert.Equal(t, 1, len(records)) {
+		assert.Equal(t, "deployXapp", records[0].Operation)
+		assert.Equal(t, "dummy-xapp", records[0].XappName)
+		assert.Equal(t, "trialxapp", records[0].Namespace)
+		assert.Equal(t, requestDigest([]byte(body)), records[0].RequestDigest)
+	}
+}
+
+func TestAuditTargetPrefersPathAndQuery(t *testing.T) {
+	r, audit, _ := newTestRestful(nil, nil)
+
+	serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp?namespace=ricxapp", "", `{"xappName": "other-xapp", "namespace": "trialxapp"}`)
+
+	records, _ := audit.List(auditlog.Filter{})
+	if assert.Equal(t, 1, len(records)) {
+		assert.Equal(t, "dummy-xapp", records[0].XappName)
+		assert.Equal(t, "ricxapp", records[0].Namespace)
+	}
+}
+
+func TestReadsAndExcludedOperationsAreNotAudited(t *testing.T) {
+	defer viper.Set("audit.excludeOperations", nil)
+	viper.Set("audit.excludeOperations", []string{"undeployXapp"})
+	r, audit, handled := newTestRestful(nil, nil)
+
+	serveTestRequest(r, http.MethodGet, "/ric/v1/health/alive", "", "")
+	serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp", "", "")
+	assert.Equal(t, 1, *handled)
+
+	records, _ := audit.List(auditlog.Filter{})
+	assert.Equal(t, 0, len(records))
+}
+
+func TestRequestDigest(t *testing.T) {
+	assert.Equal(t, "", requestDigest(nil))
+	assert.Equal(t, "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", requestDigest([]byte("{}")))
+	assert.NotEqual(t, requestDigest([]byte(`{"a": 1}`)), requestDigest([]byte(`{"a": 2}`)))
+}
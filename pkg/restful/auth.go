@@ -0,0 +1,69 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restful
+
+import (
+	"net/http"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auth"
+)
+
+// authorizeOperations authenticates the bearer token of every request, except
+// for the anonymous operations, and checks that a role of the caller grants the
+// swagger operation. The caller is then known to the handlers through
+// requestAuthor. Denied mutating requests are audited here, since they don't
+// get to the audit of the handlers.
+func (r *Restful) authorizeOperations(next http.Handler) http.Handler {
+	if r.authorizer == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		operation := operationID(req)
+		if r.authorizer.Anonymous(operation) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		principal, err := r.authorizer.AuthenticateRequest(req)
+		if err != nil {
+			appmgr.Logger.Info("Unauthenticated %s from %s: %v", operation, clientAddr(req), err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="appmgr"`)
+			r.deny(w, req, operation, http.StatusUnauthorized)
+			return
+		}
+
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		if !r.authorizer.Allowed(principal, operation) {
+			appmgr.Logger.Info("'%s' with roles %v may not call %s", principal.Name, principal.Roles, operation)
+			r.deny(w, req, operation, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (r *Restful) deny(w http.ResponseWriter, req *http.Request, operation string, code int) {
+	if audited(req, operation) {
+		r.recordAudit(req, operation, readBody(req), code)
+	}
+	http.Error(w, http.StatusText(code), code)
+}
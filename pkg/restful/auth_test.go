@@ -0,0 +1,102 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restful
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auditlog"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+func TestMissingOrUnknownTokenIsUnauthorized(t *testing.T) {
+	r, _, handled := newTestRestful(newTestAuthorizer(t), nil)
+
+	for _, token := range []string{"", "unknown-token"} {
+		w := serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp", token, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="appmgr"`, w.Header().Get("WWW-Authenticate"))
+	}
+	assert.Equal(t, 0, *handled)
+}
+
+func TestOperationNotGrantedIsForbidden(t *testing.T) {
+	r, _, handled := newTestRestful(newTestAuthorizer(t), nil)
+
+	w := serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp", viewerToken, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "", w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, 0, *handled)
+
+	w = serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp", operatorToken, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 1, *handled)
+}
+
+func TestAnonymousOperationsNeedNoToken(t *testing.T) {
+	r, _, _ := newTestRestful(newTestAuthorizer(t), nil)
+
+	w := serveTestRequest(r, http.MethodGet, "/ric/v1/health/alive", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Even with a token that would be rejected
+	w = serveTestRequest(r, http.MethodGet, "/ric/v1/health/alive", "unknown-token", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestWithoutAuthorizerEverythingIsAllowed(t *testing.T) {
+	r, audit, handled := newTestRestful(nil, nil)
+
+	w := serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp", "", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 1, *handled)
+
+	records, _ := audit.List(auditlog.Filter{})
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, "anonymous@192.0.2.1:1234", records[0].Caller)
+}
+
+func TestDeniedWritesAreAudited(t *testing.T) {
+	r, audit, _ := newTestRestful(newTestAuthorizer(t), nil)
+
+	serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp", "", "")
+	serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp?namespace=trialxapp", viewerToken, "")
+	// Denied reads are not
+	serveTestRequest(r, http.MethodGet, "/ric/v1/xapps", "", "")
+
+	records, _ := audit.List(auditlog.Filter{})
+	if assert.Equal(t, 2, len(records)) {
+		unauthenticated, forbidden := records[0], records[1]
+
+		assert.Equal(t, "anonymous@192.0.2.1:1234", unauthenticated.Caller)
+		assert.Equal(t, "undeployXapp", unauthenticated.Operation)
+		assert.Equal(t, int64(http.StatusUnauthorized), unauthenticated.StatusCode)
+		assert.Equal(t, models.AuditRecordOutcomeFailure, unauthenticated.Outcome)
+		assert.Equal(t, "dummy-xapp", unauthenticated.XappName)
+
+		assert.Equal(t, "dashboard", forbidden.Caller)
+		assert.Equal(t, int64(http.StatusForbidden), forbidden.StatusCode)
+		assert.Equal(t, models.AuditRecordOutcomeFailure, forbidden.Outcome)
+		assert.Equal(t, "trialxapp", forbidden.Namespace)
+	}
+}
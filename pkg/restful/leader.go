@@ -0,0 +1,174 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restful
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/certs"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
+)
+
+const (
+	// headerForwardedBy names the replica that forwarded a write to the leader
+	headerForwardedBy = "X-Appmgr-Forwarded-By"
+	// headerForwardedFor is the address of the client of a forwarded write
+	headerForwardedFor = "X-Appmgr-Forwarded-For"
+
+	defaultSyncInterval = 10 * time.Second
+	noLeaderRetryAfter  = "5"
+)
+
+// forwardWrites passes the mutating requests to the leader when this replica
+// is a follower. The leader authenticates and audits them again. Without a
+// leader, e.g. during a failover, the caller is asked to retry.
+func (r *Restful) forwardWrites(next http.Handler) http.Handler {
+	if r.elector == nil {
+		return next
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = certs.Scheme()
+			req.URL.Host = r.elector.Leader()
+			req.Header.Set(headerForwardedBy, r.elector.Identity())
+			req.Header.Set(headerForwardedFor, req.RemoteAddr)
+		},
+		Transport: tracing.Transport(certs.TransportFor(viper.GetString("leaderElection.serverName"))),
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			appmgr.Logger.Error("Forwarding %s %s to the leader failed: %v", req.Method, req.URL.Path, err)
+			http.Error(w, "Forwarding to the leader failed", http.StatusBadGateway)
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !isMutating(req.Method) || r.elector.IsLeader() {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		// Not forwarded twice, e.g. to a leader that has just stepped down
+		addr := r.elector.Leader()
+		if addr == "" || addr == r.elector.Identity() || req.Header.Get(headerForwardedBy) != "" {
+			appmgr.Logger.Info("No leader to handle %s %s", req.Method, req.URL.Path)
+			w.Header().Set("Retry-After", noLeaderRetryAfter)
+			http.Error(w, "No leader elected", http.StatusServiceUnavailable)
+			return
+		}
+		proxy.ServeHTTP(w, req)
+	})
+}
+
+// isLeader tells whether this replica writes, always true without leader election
+func (r *Restful) isLeader() bool {
+	return r.elector == nil || r.elector.IsLeader()
+}
+
+// campaign takes part in the leader election until Shutdown. Losing the lease
+// ends Run, so that the replica is restarted as a follower.
+func (r *Restful) campaign(ctx context.Context) {
+	defer close(r.electionDone)
+
+	if err := r.elector.Run(ctx, r.lead); err != nil {
+		appmgr.Logger.Error("Leader election of %s ended: %v", r.elector.Identity(), err)
+		r.lost <- err
+	}
+}
+
+// lead runs the background tasks that write, until ctx is done or Shutdown.
+// The state written by the former leader is loaded first.
+func (r *Restful) lead(ctx context.Context) {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-r.stop:
+		}
+		close(stop)
+	}()
+
+	if r.elector != nil {
+		r.syncFromDB()
+	}
+
+	var tasks sync.WaitGroup
+	for _, task := range []func(){
+		r.RetrieveApps,
+		func() { r.monitor.Run(stop) },
+		func() { r.rh.RunRedrive(stop) },
+	} {
+		tasks.Add(1)
+		go func(task func()) {
+			defer tasks.Done()
+			task()
+		}(task)
+	}
+	tasks.Wait()
+}
+
+// followLeader reloads the state written by the leader every
+// 'leaderElection.syncInterval' seconds, for the reads served by this replica
+func (r *Restful) followLeader() {
+	interval := time.Duration(viper.GetInt("leaderElection.syncInterval")) * time.Second
+	if interval <= 0 {
+		interval = defaultSyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !r.elector.IsLeader() {
+				r.syncFromDB()
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *Restful) syncFromDB() {
+	if err := r.registry.Restore(); err != nil {
+		appmgr.Logger.Error("Reloading the registry failed: %v", err)
+	}
+	r.rh.ReloadSubscriptions()
+	r.updateRoutes()
+}
+
+// clientAddr returns the address of the client of req. A write forwarded by
+// another replica names its client in a header, which is trusted only if the
+// replica authenticated with a client certificate.
+func clientAddr(req *http.Request) string {
+	if req.Header.Get(headerForwardedBy) == "" || req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return req.RemoteAddr
+	}
+	if addr := req.Header.Get(headerForwardedFor); addr != "" {
+		return addr
+	}
+	return req.RemoteAddr
+}
@@ -0,0 +1,212 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restful
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auditlog"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/leader"
+)
+
+const followerIdentity = "10.0.0.2:8080"
+
+var testDurations = leader.Durations{
+	LeaseDuration: time.Second,
+	RenewDeadline: 500 * time.Millisecond,
+	RetryPeriod:   100 * time.Millisecond,
+}
+
+// testLeader stands for the REST API of the leader, and keeps the requests
+// forwarded to it
+type testLeader struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+func newTestLeader() *testLeader {
+	l := &testLeader{}
+	l.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		l.mutex.Lock()
+		l.requests = append(l.requests, req)
+		l.mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return l
+}
+
+func (l *testLeader) forwarded() []*http.Request {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]*http.Request{}, l.requests...)
+}
+
+// startTestElector runs an Elector for the Lease ricplt/appmgr until the test ends
+func startTestElector(t *testing.T, client coordinationv1.LeasesGetter, identity string) *leader.Elector {
+	e, err := leader.New(client, "ricplt", "appmgr", identity, testDurations)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, func(ctx context.Context) { <-ctx.Done() })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return e
+}
+
+// newTestFollower returns a follower of l, once it knows that l leads
+func newTestFollower(t *testing.T, l *testLeader) (*Restful, *fakeAuditLog, *int) {
+	client := fake.NewSimpleClientset().CoordinationV1()
+	leading := startTestElector(t, client, l.Listener.Addr().String())
+	if !assert.Eventually(t, leading.IsLeader, 5*time.Second, 50*time.Millisecond) {
+		t.FailNow()
+	}
+
+	follower := startTestElector(t, client, followerIdentity)
+	if !assert.Eventually(t, func() bool { return follower.Leader() == leading.Identity() }, 5*time.Second, 50*time.Millisecond) {
+		t.FailNow()
+	}
+	return newTestRestful(nil, follower)
+}
+
+func TestFollowerForwardsWritesToLeader(t *testing.T) {
+	l := newTestLeader()
+	defer l.Close()
+	r, audit, handled := newTestFollower(t, l)
+
+	w := serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp?namespace=trialxapp", operatorToken, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 0, *handled)
+
+	forwarded := l.forwarded()
+	if assert.Equal(t, 1, len(forwarded)) {
+		req := forwarded[0]
+		assert.Equal(t, http.MethodDelete, req.Method)
+		assert.Equal(t, "/ric/v1/xapps/dummy-xapp", req.URL.Path)
+		assert.Equal(t, "trialxapp", req.URL.Query().Get("namespace"))
+		assert.Equal(t, "Bearer "+operatorToken, req.Header.Get("Authorization"))
+		assert.Equal(t, followerIdentity, req.Header.Get(headerForwardedBy))
+		assert.Equal(t, "192.0.2.1:1234", req.Header.Get(headerForwardedFor))
+	}
+
+	// Audited by the leader only
+	records, _ := audit.List(auditlog.Filter{})
+	assert.Equal(t, 0, len(records))
+}
+
+func TestFollowerServesReads(t *testing.T) {
+	l := newTestLeader()
+	defer l.Close()
+	r, _, _ := newTestFollower(t, l)
+
+	w := serveTestRequest(r, http.MethodGet, "/ric/v1/health/alive", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, len(l.forwarded()))
+}
+
+func TestForwardedWriteIsNotForwardedAgain(t *testing.T) {
+	l := newTestLeader()
+	defer l.Close()
+	r, _, handled := newTestFollower(t, l)
+
+	// E.g. forwarded to this replica while it was stepping down
+	req := httptest.NewRequest(http.MethodDelete, "/ric/v1/xapps/dummy-xapp", nil)
+	req.Header.Set(headerForwardedBy, "10.0.0.3:8080")
+	w := httptest.NewRecorder()
+	r.serveAPI().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, noLeaderRetryAfter, w.Header().Get("Retry-After"))
+	assert.Equal(t, 0, len(l.forwarded()))
+	assert.Equal(t, 0, *handled)
+}
+
+func TestFollowerWithoutLeaderAsksToRetry(t *testing.T) {
+	client := fake.NewSimpleClientset().CoordinationV1()
+	// Not campaigning, so no leader is known
+	e, err := leader.New(client, "ricplt", "appmgr", followerIdentity, testDurations)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	r, _, handled := newTestRestful(nil, e)
+
+	w := serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp", "", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, noLeaderRetryAfter, w.Header().Get("Retry-After"))
+	assert.Equal(t, 0, *handled)
+
+	w = serveTestRequest(r, http.MethodGet, "/ric/v1/health/alive", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLeaderHandlesWrites(t *testing.T) {
+	client := fake.NewSimpleClientset().CoordinationV1()
+	e := startTestElector(t, client, "10.0.0.1:8080")
+	if !assert.Eventually(t, e.IsLeader, 5*time.Second, 50*time.Millisecond) {
+		t.FailNow()
+	}
+	r, audit, handled := newTestRestful(nil, e)
+
+	w := serveTestRequest(r, http.MethodDelete, "/ric/v1/xapps/dummy-xapp", "", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 1, *handled)
+
+	records, _ := audit.List(auditlog.Filter{})
+	assert.Equal(t, 1, len(records))
+}
+
+func TestClientAddrTrustsOnlyVerifiedReplicas(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/ric/v1/xapps/dummy-xapp", nil)
+	req.Header.Set(headerForwardedFor, "10.1.2.3:40000")
+	assert.Equal(t, "192.0.2.1:1234", clientAddr(req))
+
+	// Named by a replica, but without a client certificate
+	req.Header.Set(headerForwardedBy, followerIdentity)
+	assert.Equal(t, "192.0.2.1:1234", clientAddr(req))
+	req.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "192.0.2.1:1234", clientAddr(req))
+
+	req.TLS.VerifiedChains = [][]*x509.Certificate{{{}}}
+	assert.Equal(t, "10.1.2.3:40000", clientAddr(req))
+	assert.Equal(t, "anonymous@10.1.2.3:40000", requestAuthor(req))
+
+	// Forwarded without a client address
+	req.Header.Del(headerForwardedFor)
+	assert.Equal(t, "192.0.2.1:1234", clientAddr(req))
+}
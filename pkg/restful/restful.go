@@ -20,10 +20,12 @@
 package restful
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"strconv"
@@ -33,83 +35,208 @@ import (
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restapi"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restapi/operations"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restapi/operations/audit"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restapi/operations/health"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restapi/operations/messages"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restapi/operations/routes"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restapi/operations/xapp"
 	"github.com/go-openapi/loads"
+	"github.com/go-openapi/runtime"
 	"github.com/go-openapi/runtime/middleware"
 	"github.com/valyala/fastjson"
 
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auditlog"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auth"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/certs"
+	cfgmap "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/cm"
+	helmer "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/helm"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/leader"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/metrics"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/registry"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/resthooks"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/rmr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
+	"github.com/spf13/viper"
 )
 
-type XappData struct {
-	httpendpoint     string
-	rmrendpoint      string
-	rmrserviceep     string
-	status           string
-	xappname         string
-	xappinstname     string
-	xappversion      string
-	xappconfigpath   string
-	xappdynamiconfig bool
-	xappInstance     *models.XappInstance
-}
+const defaultDeployedRefresh = 60 * time.Second
 
-var xappmap = map[string]map[string]*XappData{}
+// Pre-deploy message flow check modes, set in 'flowAnalysis.onDeploy'
+const (
+	flowCheckOff   = "off"
+	flowCheckWarn  = "warn"
+	flowCheckBlock = "block"
+)
 
 func NewRestful() *Restful {
+	h := helmer.NewHelm()
 	r := &Restful{
-		rh:    resthooks.NewResthook(true),
-		ready: false,
+		helm:     h,
+		cm:       h.CM(),
+		rh:       resthooks.NewResthook(true),
+		registry: registry.NewRegistry(true),
+		audit:    auditlog.NewLog(),
+		ready:    false,
+		stop:     make(chan struct{}),
+	}
+	r.cm.SetHistory(cfgmap.NewConfigHistory())
+	r.monitor = registry.NewMonitor(r.registry, r.notifyInstanceEvent)
+	r.pusher = registry.NewConfigPusher(r.registry)
+	if c, err := rmr.LoadConfiguredCatalogue(); err == nil {
+		r.catalogue = c
+	} else {
+		appmgr.Logger.Error("Loading RMR message catalogue failed, messages are not validated: %v", err)
+	}
+	policyMessages := viper.GetStringSlice("routes.policyMessages")
+	if len(policyMessages) == 0 {
+		policyMessages = rmr.DefaultPolicyMessages
 	}
+	r.router = rmr.NewRouter(r.catalogue, policyMessages)
+	r.analyzer = &rmr.Analyzer{
+		PolicyMessages: policyMessages,
+		External:       viper.GetStringSlice("flowAnalysis.externalMessages"),
+	}
+	authorizer, err := auth.NewFromConfig()
+	if err != nil {
+		appmgr.Logger.Error("Configuring REST API authentication failed: %v", err)
+		os.Exit(1)
+	}
+	r.authorizer = authorizer
+	elector, err := leader.NewFromConfig()
+	if err != nil {
+		appmgr.Logger.Error("Configuring leader election failed: %v", err)
+		os.Exit(1)
+	}
+	r.elector = elector
 	r.api = r.SetupHandler()
+	r.server = &http.Server{Addr: "0.0.0.0:8080", Handler: r.serveAPI()}
+	r.symptomdata = &http.Server{Addr: ":8081", Handler: r.symptomdataHandler()}
+	metrics.Default.OnCollect(r.collectMetrics)
 	return r
 }
 
-func (r *Restful) Run() {
-	server := restapi.NewServer(r.api)
-	defer server.Shutdown()
-	server.Port = 8080
-	server.Host = "0.0.0.0"
-
-	appmgr.Logger.Info("Xapp manager started ... serving on %s:%d\n", server.Host, server.Port)
+// Run serves the REST API and starts the background tasks, on the leader only
+// if leader election is enabled. It returns when the server is stopped by
+// Shutdown, or fails, or when this replica loses the leadership.
+func (r *Restful) Run() error {
+	appmgr.Logger.Info("Xapp manager started ... serving on %s\n", r.server.Addr)
 
+	go r.helm.Initialize()
 	go r.symptomdataServer()
-	go r.RetrieveApps()
-	if err := server.Serve(); err != nil {
-		log.Fatal(err.Error())
+	if r.elector == nil {
+		r.startTask(func() { r.lead(context.Background()) })
+	} else {
+		appmgr.Logger.Info("Leader election enabled, identity %s", r.elector.Identity())
+		var ctx context.Context
+		ctx, r.stopElection = context.WithCancel(context.Background())
+		r.electionDone, r.lost = make(chan struct{}), make(chan error, 1)
+		go r.campaign(ctx)
+		r.startTask(r.followLeader)
 	}
 
+	served := make(chan error, 1)
+	go func() { served <- certs.ListenAndServe(r.server) }()
+	select {
+	case err := <-served:
+		if err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	case err := <-r.lost:
+		return err
+	}
 }
 
-func (r *Restful) RetrieveApps() {
-	time.Sleep(5 * time.Second)
-	var xlist models.RegisterRequest
-	applist := r.rh.GetAppsInSDL()
-	if applist != nil {
-		appmgr.Logger.Info("List obtained from GetAppsInSDL is %s", *applist)
-		newstring := strings.Split(*applist, " ")
-		for i, _ := range newstring {
-			appmgr.Logger.Debug("Checking for xapp %s", newstring[i])
-			if newstring[i] != "" {
-				err := json.Unmarshal([]byte(newstring[i]), &xlist)
-				if err != nil {
-					appmgr.Logger.Error("Error while unmarshalling")
-					continue
-				}
-			} else {
-				continue //SDL may have empty item,so need to skip
-			}
+// Shutdown stops the servers and waits, until ctx is done, for the requests in
+// progress, e.g. helm operations, and the background tasks to complete. The
+// queued notifications are then delivered, or stored for the next appmgr when
+// ctx is done, the registry is flushed by the leader, the lease is released and
+// the SDL clients are closed.
+func (r *Restful) Shutdown(ctx context.Context) (err error) {
+	close(r.stop)
+	for _, s := range []*http.Server{r.server, r.symptomdata} {
+		if e := s.Shutdown(ctx); e != nil {
+			appmgr.Logger.Error("Stopping the server on %s failed: %v", s.Addr, e)
+			err = e
+		}
+	}
 
-			xmodel, _ := r.PrepareConfig(xlist, false)
-			if xmodel == nil {
-				appmgr.Logger.Error("Xapp not found, deleting it from DB")
-				r.rh.UpdateAppData(xlist, true)
-			}
+	tasksDone := make(chan struct{})
+	go func() {
+		r.tasks.Wait()
+		close(tasksDone)
+	}()
+	select {
+	case <-tasksDone:
+	case <-ctx.Done():
+		appmgr.Logger.Error("Background tasks still running at shutdown")
+	}
+
+	r.rh.Drain(ctx)
+	if r.isLeader() {
+		if e := r.registry.Flush(); e != nil {
+			appmgr.Logger.Error("Flushing the registry failed: %v", e)
+			err = e
+		}
+	}
+	if r.stopElection != nil {
+		r.stopElection()
+		select {
+		case <-r.electionDone:
+		case <-ctx.Done():
+			appmgr.Logger.Error("Releasing the leader lease timed out")
+		}
+	}
+	for _, c := range []io.Closer{r.rh, r.registry, r.cm.History(), r.audit} {
+		if e := c.Close(); e != nil {
+			appmgr.Logger.Error("Closing SDL failed: %v", e)
+			err = e
+		}
+	}
+	return
+}
+
+func (r *Restful) startTask(task func()) {
+	r.tasks.Add(1)
+	go func() {
+		defer r.tasks.Done()
+		task()
+	}()
+}
+
+// RetrieveApps re-registers the xApps stored by older releases under the single
+// 'endpoints' key. The registry itself is restored when it is created.
+func (r *Restful) RetrieveApps() {
+	for _, params := range r.registry.TakeLegacyRequests() {
+		appmgr.Logger.Info("Migrating legacy registration of %s/%s", *params.AppName, *params.AppInstanceName)
+		if _, err := r.PrepareConfig(params); err != nil {
+			appmgr.Logger.Error("Xapp %s not found, dropping it from DB", *params.AppInstanceName)
 		}
 	}
+	r.updateRoutes()
+}
+
+// notifyInstanceEvent publishes the liveness events of the Monitor, and drops the
+// routes of the instances it deregisters
+func (r *Restful) notifyInstanceEvent(x models.Xapp, event models.EventType) {
+	if event == models.EventTypeUndeployed {
+		r.updateRoutes()
+	}
+	r.rh.PublishSubscription(x, event)
+}
+
+func (r *Restful) updateRoutes() {
+	xapps, _ := r.GetApps("")
+	r.router.Update(xapps)
+}
 
+// serveAPI routes the requests to the operations of r.api, through the
+// middlewares that need the matched swagger operation
+func (r *Restful) serveAPI() http.Handler {
+	return r.api.Serve(func(h http.Handler) http.Handler {
+		return instrumentOperations(tracing.Handler(operationID, r.authorizeOperations(r.forwardWrites(r.auditOperations(h)))))
+	})
 }
 
 func (r *Restful) SetupHandler() *operations.AppManagerAPI {
@@ -119,6 +246,8 @@ func (r *Restful) SetupHandler() *operations.AppManagerAPI {
 		os.Exit(1)
 	}
 	api := operations.NewAppManagerAPI(swaggerSpec)
+	api.RegisterConsumer(cfgmap.MergePatchType, runtime.JSONConsumer())
+	api.RegisterConsumer(cfgmap.JSONPatchType, runtime.JSONConsumer())
 
 	// URL: /ric/v1/health
 	api.HealthGetHealthAliveHandler = health.GetHealthAliveHandlerFunc(
@@ -126,6 +255,15 @@ func (r *Restful) SetupHandler() *operations.AppManagerAPI {
 			return health.NewGetHealthAliveOK()
 		})
 
+	api.HealthGetMetricsHandler = health.GetMetricsHandlerFunc(
+		func(params health.GetMetricsParams) middleware.Responder {
+			return middleware.ResponderFunc(func(w http.ResponseWriter, _ runtime.Producer) {
+				w.Header().Set("Content-Type", metrics.ContentType)
+				w.WriteHeader(http.StatusOK)
+				metrics.Default.WriteText(w)
+			})
+		})
+
 	api.HealthGetHealthReadyHandler = health.GetHealthReadyHandlerFunc(
 		func(params health.GetHealthReadyParams) middleware.Responder {
 			return health.NewGetHealthReadyOK()
@@ -147,11 +285,23 @@ func (r *Restful) SetupHandler() *operations.AppManagerAPI {
 
 	api.AddSubscriptionHandler = operations.AddSubscriptionHandlerFunc(
 		func(params operations.AddSubscriptionParams) middleware.Responder {
-			return operations.NewAddSubscriptionCreated().WithPayload(r.rh.AddSubscription(*params.SubscriptionRequest))
+			if err := resthooks.ValidateSubscription(*params.SubscriptionRequest); err != nil {
+				appmgr.Logger.Error("Invalid subscription request: %v", err)
+				return operations.NewAddSubscriptionBadRequest()
+			}
+			result, err := r.rh.AddSubscription(*params.SubscriptionRequest)
+			if err != nil {
+				return operations.NewAddSubscriptionInternalServerError()
+			}
+			return operations.NewAddSubscriptionCreated().WithPayload(result)
 		})
 
 	api.ModifySubscriptionHandler = operations.ModifySubscriptionHandlerFunc(
 		func(params operations.ModifySubscriptionParams) middleware.Responder {
+			if err := resthooks.ValidateSubscription(*params.SubscriptionRequest); err != nil {
+				appmgr.Logger.Error("Invalid subscription request: %v", err)
+				return operations.NewModifySubscriptionBadRequest()
+			}
 			if _, ok := r.rh.ModifySubscription(params.SubscriptionID, *params.SubscriptionRequest); ok {
 				return operations.NewModifySubscriptionOK()
 			}
@@ -166,19 +316,379 @@ func (r *Restful) SetupHandler() *operations.AppManagerAPI {
 			return operations.NewDeleteSubscriptionBadRequest()
 		})
 
-	// URL: /ric/v1/xapp
+	// URL: /ric/v1/deadletters
+	api.GetDeadLettersHandler = operations.GetDeadLettersHandlerFunc(
+		func(params operations.GetDeadLettersParams) middleware.Responder {
+			if result, err := r.rh.GetDeadLetters(); err == nil {
+				return operations.NewGetDeadLettersOK().WithPayload(result)
+			}
+			return operations.NewGetDeadLettersInternalServerError()
+		})
+
+	api.ReplayDeadLetterHandler = operations.ReplayDeadLetterHandlerFunc(
+		func(params operations.ReplayDeadLetterParams) middleware.Responder {
+			switch err := r.rh.ReplayDeadLetter(params.DeadLetterID); err {
+			case nil:
+				return operations.NewReplayDeadLetterAccepted()
+			case resthooks.ErrDeadLetterNotFound, resthooks.ErrSubscriptionNotFound:
+				return operations.NewReplayDeadLetterNotFound()
+			}
+			return operations.NewReplayDeadLetterInternalServerError()
+		})
+
+	api.DeleteDeadLetterHandler = operations.DeleteDeadLetterHandlerFunc(
+		func(params operations.DeleteDeadLetterParams) middleware.Responder {
+			switch err := r.rh.DeleteDeadLetter(params.DeadLetterID); err {
+			case nil:
+				return operations.NewDeleteDeadLetterNoContent()
+			case resthooks.ErrDeadLetterNotFound:
+				return operations.NewDeleteDeadLetterNotFound()
+			}
+			return operations.NewDeleteDeadLetterInternalServerError()
+		})
+
+	// URL: /ric/v1/xapps
 	api.XappGetAllXappsHandler = xapp.GetAllXappsHandlerFunc(
 		func(params xapp.GetAllXappsParams) middleware.Responder {
-			if result, err := r.GetApps(); err == nil {
+			if result, err := r.GetApps(namespaceOf(params.Namespace)); err == nil {
 				return xapp.NewGetAllXappsOK().WithPayload(result)
 			}
 			return xapp.NewGetAllXappsInternalServerError()
 		})
 
+	api.XappListAllXappsHandler = xapp.ListAllXappsHandlerFunc(
+		func(params xapp.ListAllXappsParams) middleware.Responder {
+			return xapp.NewListAllXappsOK().WithPayload(r.helm.SearchAll())
+		})
+
+	api.XappGetXappByNameHandler = xapp.GetXappByNameHandlerFunc(
+		func(params xapp.GetXappByNameParams) middleware.Responder {
+			if result, err := r.helm.Status(params.XAppName, namespaceOf(params.Namespace)); err == nil {
+				return xapp.NewGetXappByNameOK().WithPayload(&result)
+			}
+			return xapp.NewGetXappByNameNotFound()
+		})
+
+	api.XappGetXappInstanceByNameHandler = xapp.GetXappInstanceByNameHandlerFunc(
+		func(params xapp.GetXappInstanceByNameParams) middleware.Responder {
+			if result, err := r.helm.Status(params.XAppName, namespaceOf(params.Namespace)); err == nil {
+				for _, v := range result.Instances {
+					if *v.Name == params.XAppInstanceName {
+						return xapp.NewGetXappInstanceByNameOK().WithPayload(v)
+					}
+				}
+			}
+			return xapp.NewGetXappInstanceByNameNotFound()
+		})
+
+	api.XappDeployXappHandler = xapp.DeployXappHandlerFunc(
+		func(params xapp.DeployXappParams) middleware.Responder {
+			if params.XappDescriptor == nil || params.XappDescriptor.XappName == nil {
+				return xapp.NewDeployXappBadRequest()
+			}
+			// Validated before the chart is fetched, as the name and version go to helm
+			ns, err := r.cm.ValidateNamespace(params.XappDescriptor.Namespace)
+			if err != nil {
+				return xapp.NewDeployXappBadRequest()
+			}
+			params.XappDescriptor.Namespace = ns
+			if err := helmer.ValidateDescriptor(*params.XappDescriptor); err != nil {
+				return xapp.NewDeployXappBadRequest()
+			}
+			appmgr.Logger.Info("Deploying xApp %s", *params.XappDescriptor.XappName)
+			ctx := params.HTTPRequest.Context()
+			issues, warnings, err := r.checkChart(ctx, *params.XappDescriptor)
+			if err != nil {
+				if len(issues) != 0 {
+					return xapp.NewDeployXappBadRequest().WithPayload(&models.FlowAnalysis{Issues: issues})
+				}
+				return xapp.NewDeployXappBadRequest()
+			}
+			if result, err := r.helm.InstallContext(ctx, *params.XappDescriptor); err == nil {
+				go r.rh.PublishSubscriptionContext(ctx, result, models.EventTypeDeployed)
+				resp := xapp.NewDeployXappCreated().WithPayload(&result)
+				for _, issue := range issues {
+					warnings = append(warnings, issue.Description)
+				}
+				if len(warnings) != 0 {
+					resp.WithWarning(warningHeader(warnings))
+				}
+				return resp
+			}
+			return xapp.NewDeployXappInternalServerError()
+		})
+
+	api.XappUndeployXappHandler = xapp.UndeployXappHandlerFunc(
+		func(params xapp.UndeployXappParams) middleware.Responder {
+			appmgr.Logger.Info("Undeploying xApp %s", params.XAppName)
+			ctx := params.HTTPRequest.Context()
+			if result, err := r.helm.DeleteContext(ctx, params.XAppName, namespaceOf(params.Namespace)); err == nil {
+				go r.rh.PublishSubscriptionContext(ctx, result, models.EventTypeUndeployed)
+				return xapp.NewUndeployXappNoContent()
+			}
+			return xapp.NewUndeployXappInternalServerError()
+		})
+
+	api.XappUpgradeXappHandler = xapp.UpgradeXappHandlerFunc(
+		func(params xapp.UpgradeXappParams) middleware.Responder {
+			if params.XappDescriptor == nil {
+				return xapp.NewUpgradeXappBadRequest()
+			}
+			if params.XappDescriptor.XappName == nil {
+				params.XappDescriptor.XappName = &params.XAppName
+			}
+			if *params.XappDescriptor.XappName != params.XAppName {
+				return xapp.NewUpgradeXappBadRequest()
+			}
+			appmgr.Logger.Info("Upgrading xApp %s", params.XAppName)
+			ctx := params.HTTPRequest.Context()
+			if result, err := r.helm.UpgradeContext(ctx, *params.XappDescriptor); err == nil {
+				go r.rh.PublishSubscriptionContext(ctx, result, models.EventTypeModified)
+				return xapp.NewUpgradeXappOK().WithPayload(&result)
+			}
+			return xapp.NewUpgradeXappInternalServerError()
+		})
+
+	api.XappRollbackXappHandler = xapp.RollbackXappHandlerFunc(
+		func(params xapp.RollbackXappParams) middleware.Responder {
+			if params.RollbackRequest == nil || helmer.ValidateName(params.XAppName) != nil {
+				return xapp.NewRollbackXappBadRequest()
+			}
+			appmgr.Logger.Info("Rolling back xApp %s to revision %d", params.XAppName, params.RollbackRequest.Revision)
+			ctx := params.HTTPRequest.Context()
+			if result, err := r.helm.RollbackContext(ctx, params.XAppName, namespaceOf(params.Namespace), int(params.RollbackRequest.Revision)); err == nil {
+				go r.rh.PublishSubscriptionContext(ctx, result, models.EventTypeModified)
+				return xapp.NewRollbackXappOK().WithPayload(&result)
+			}
+			return xapp.NewRollbackXappInternalServerError()
+		})
+
+	api.XappGetXappHistoryHandler = xapp.GetXappHistoryHandlerFunc(
+		func(params xapp.GetXappHistoryParams) middleware.Responder {
+			if helmer.ValidateName(params.XAppName) != nil {
+				return xapp.NewGetXappHistoryBadRequest()
+			}
+			if result, err := r.helm.History(params.XAppName, namespaceOf(params.Namespace)); err == nil {
+				return xapp.NewGetXappHistoryOK().WithPayload(result)
+			}
+			return xapp.NewGetXappHistoryNotFound()
+		})
+
 	// URL: /ric/v1/config
 	api.XappGetAllXappConfigHandler = xapp.GetAllXappConfigHandlerFunc(
 		func(params xapp.GetAllXappConfigParams) middleware.Responder {
-			return xapp.NewGetAllXappConfigOK().WithPayload(r.getAppConfig())
+			return xapp.NewGetAllXappConfigOK().WithPayload(r.getAppConfig(namespaceOf(params.Namespace)))
+		})
+
+	api.XappModifyXappConfigHandler = xapp.ModifyXappConfigHandlerFunc(
+		func(params xapp.ModifyXappConfigParams) middleware.Responder {
+			c := params.XAppConfig
+			if c == nil || c.Metadata == nil || c.Metadata.XappName == nil || c.Metadata.Namespace == nil {
+				return xapp.NewModifyXappConfigBadRequest()
+			}
+			if helmer.ValidateName(*c.Metadata.XappName) != nil {
+				return xapp.NewModifyXappConfigBadRequest()
+			}
+			ns, err := r.cm.ValidateNamespace(*c.Metadata.Namespace)
+			if err != nil {
+				return xapp.NewModifyXappConfigBadRequest()
+			}
+			ifMatch, err := parseIfMatch(params.IfMatch)
+			if err != nil {
+				return xapp.NewModifyXappConfigPreconditionFailed()
+			}
+			if len(r.registry.ConfigurableInstances(ns, *c.Metadata.XappName)) != 0 {
+				return r.pushXappConfig(params.HTTPRequest, ns, *c, ifMatch)
+			}
+
+			_, validationErrors, err := r.cm.UpdateConfigIfMatch(*c, ifMatch, requestAuthor(params.HTTPRequest), "")
+			if validationErrors != nil {
+				return xapp.NewModifyXappConfigUnprocessableEntity().WithPayload(validationErrors)
+			}
+			if errors.Is(err, cfgmap.ErrRevisionMismatch) {
+				return xapp.NewModifyXappConfigPreconditionFailed()
+			}
+			if err != nil {
+				return xapp.NewModifyXappConfigInternalServerError()
+			}
+			return xapp.NewModifyXappConfigOK().WithPayload(models.ConfigValidationErrors{})
+		})
+
+	api.XappGetConfigPushStatusHandler = xapp.GetConfigPushStatusHandlerFunc(
+		func(params xapp.GetConfigPushStatusParams) middleware.Responder {
+			ns, err := r.cm.ValidateNamespace(namespaceOf(params.Namespace))
+			if err != nil {
+				return xapp.NewGetConfigPushStatusBadRequest()
+			}
+			if result := r.pusher.Statuses(ns, params.XappName); len(result) != 0 {
+				return xapp.NewGetConfigPushStatusOK().WithPayload(result)
+			}
+			return xapp.NewGetConfigPushStatusNotFound()
+		})
+
+	api.XappPatchXappConfigHandler = xapp.PatchXappConfigHandlerFunc(
+		func(params xapp.PatchXappConfigParams) middleware.Responder {
+			if helmer.ValidateName(params.XappName) != nil {
+				return xapp.NewPatchXappConfigBadRequest()
+			}
+			ns, err := r.cm.ValidateNamespace(namespaceOf(params.Namespace))
+			if err != nil {
+				return xapp.NewPatchXappConfigBadRequest()
+			}
+			ifMatch, err := parseIfMatch(params.IfMatch)
+			if err != nil {
+				return xapp.NewPatchXappConfigPreconditionFailed()
+			}
+			patchType, _, err := mime.ParseMediaType(params.HTTPRequest.Header.Get("Content-Type"))
+			if err != nil {
+				return xapp.NewPatchXappConfigBadRequest()
+			}
+
+			result, validationErrors, err := r.cm.PatchConfig(params.XappName, ns, patchType, params.Patch, ifMatch, requestAuthor(params.HTTPRequest))
+			switch {
+			case validationErrors != nil:
+				return xapp.NewPatchXappConfigUnprocessableEntity().WithPayload(validationErrors)
+			case errors.Is(err, cfgmap.ErrInvalidPatch):
+				appmgr.Logger.Info("Patching config of '%s' failed: %v", params.XappName, err)
+				return xapp.NewPatchXappConfigBadRequest()
+			case errors.Is(err, cfgmap.ErrRevisionMismatch):
+				return xapp.NewPatchXappConfigPreconditionFailed()
+			case err != nil || result == nil:
+				return xapp.NewPatchXappConfigInternalServerError()
+			case result.Revision == 0:
+				// Applied, but not stored as a revision
+				return xapp.NewPatchXappConfigOK().WithPayload(result)
+			}
+			return xapp.NewPatchXappConfigOK().WithETag(strconv.Quote(strconv.FormatInt(result.Revision, 10))).WithPayload(result)
+		})
+
+	api.XappValidateXappConfigHandler = xapp.ValidateXappConfigHandlerFunc(
+		func(params xapp.ValidateXappConfigParams) middleware.Responder {
+			c := params.XAppConfig
+			if c == nil || c.Metadata == nil || c.Metadata.XappName == nil || c.Metadata.Namespace == nil {
+				return xapp.NewValidateXappConfigBadRequest()
+			}
+			if helmer.ValidateName(*c.Metadata.XappName) != nil {
+				return xapp.NewValidateXappConfigBadRequest()
+			}
+			if _, err := r.cm.ValidateNamespace(*c.Metadata.Namespace); err != nil {
+				return xapp.NewValidateXappConfigBadRequest()
+			}
+
+			if result, err := r.cm.DryRun(*c); err == nil {
+				return xapp.NewValidateXappConfigOK().WithPayload(result)
+			}
+			return xapp.NewValidateXappConfigInternalServerError()
+		})
+
+	// URL: /ric/v1/config/history
+	api.XappGetConfigRevisionsHandler = xapp.GetConfigRevisionsHandlerFunc(
+		func(params xapp.GetConfigRevisionsParams) middleware.Responder {
+			ns, err := r.cm.ValidateNamespace(namespaceOf(params.Namespace))
+			if err != nil {
+				return xapp.NewGetConfigRevisionsBadRequest()
+			}
+			if result, err := r.cm.History().List(ns, params.XappName); err == nil {
+				return xapp.NewGetConfigRevisionsOK().WithPayload(result)
+			}
+			return xapp.NewGetConfigRevisionsInternalServerError()
+		})
+
+	api.XappGetConfigRevisionHandler = xapp.GetConfigRevisionHandlerFunc(
+		func(params xapp.GetConfigRevisionParams) middleware.Responder {
+			ns, err := r.cm.ValidateNamespace(namespaceOf(params.Namespace))
+			if err != nil {
+				return xapp.NewGetConfigRevisionBadRequest()
+			}
+			switch result, err := r.cm.History().Get(ns, params.XappName, params.Revision); err {
+			case nil:
+				return xapp.NewGetConfigRevisionOK().WithPayload(result)
+			case cfgmap.ErrRevisionNotFound:
+				return xapp.NewGetConfigRevisionNotFound()
+			}
+			return xapp.NewGetConfigRevisionInternalServerError()
+		})
+
+	api.XappRollbackXappConfigHandler = xapp.RollbackXappConfigHandlerFunc(
+		func(params xapp.RollbackXappConfigParams) middleware.Responder {
+			if helmer.ValidateName(params.XappName) != nil {
+				return xapp.NewRollbackXappConfigBadRequest()
+			}
+			ns, err := r.cm.ValidateNamespace(namespaceOf(params.Namespace))
+			if err != nil {
+				return xapp.NewRollbackXappConfigBadRequest()
+			}
+
+			result, validationErrors, err := r.cm.RollbackConfig(params.XappName, ns, params.Revision, requestAuthor(params.HTTPRequest))
+			switch {
+			case err == cfgmap.ErrRevisionNotFound:
+				return xapp.NewRollbackXappConfigNotFound()
+			case validationErrors != nil:
+				return xapp.NewRollbackXappConfigUnprocessableEntity().WithPayload(validationErrors)
+			case err != nil || result == nil:
+				return xapp.NewRollbackXappConfigInternalServerError()
+			}
+			return xapp.NewRollbackXappConfigOK().WithPayload(result)
+		})
+
+	api.XappDiffConfigRevisionsHandler = xapp.DiffConfigRevisionsHandlerFunc(
+		func(params xapp.DiffConfigRevisionsParams) middleware.Responder {
+			ns, err := r.cm.ValidateNamespace(namespaceOf(params.Namespace))
+			if err != nil {
+				return xapp.NewDiffConfigRevisionsBadRequest()
+			}
+			switch result, err := r.cm.History().Diff(ns, params.XappName, params.From, params.To); err {
+			case nil:
+				return xapp.NewDiffConfigRevisionsOK().WithPayload(result)
+			case cfgmap.ErrRevisionNotFound:
+				return xapp.NewDiffConfigRevisionsNotFound()
+			}
+			return xapp.NewDiffConfigRevisionsInternalServerError()
+		})
+
+	// URL: /ric/v1/messages
+	api.MessagesGetMessagesHandler = messages.GetMessagesHandlerFunc(
+		func(params messages.GetMessagesParams) middleware.Responder {
+			if r.catalogue == nil {
+				return messages.NewGetMessagesOK().WithPayload(models.AllMessageTypes{})
+			}
+			return messages.NewGetMessagesOK().WithPayload(r.catalogue.Messages())
+		})
+
+	api.MessagesGetMessageFlowAnalysisHandler = messages.GetMessageFlowAnalysisHandlerFunc(
+		func(params messages.GetMessageFlowAnalysisParams) middleware.Responder {
+			analysis := r.analyzer.Analyze(r.messagingApps())
+			return messages.NewGetMessageFlowAnalysisOK().WithPayload(&analysis)
+		})
+
+	api.MessagesGetMessageProducersHandler = messages.GetMessageProducersHandlerFunc(
+		func(params messages.GetMessageProducersParams) middleware.Responder {
+			if !r.knownMessage(params.MessageName) {
+				return messages.NewGetMessageProducersNotFound()
+			}
+			return messages.NewGetMessageProducersOK().WithPayload(rmr.Producers(r.messagingApps(), params.MessageName))
+		})
+
+	api.MessagesGetMessageConsumersHandler = messages.GetMessageConsumersHandlerFunc(
+		func(params messages.GetMessageConsumersParams) middleware.Responder {
+			if !r.knownMessage(params.MessageName) {
+				return messages.NewGetMessageConsumersNotFound()
+			}
+			return messages.NewGetMessageConsumersOK().WithPayload(rmr.Consumers(r.messagingApps(), params.MessageName))
+		})
+
+	// URL: /ric/v1/routes
+	api.RoutesGetRoutesHandler = routes.GetRoutesHandlerFunc(
+		func(params routes.GetRoutesParams) middleware.Responder {
+			if acceptsText(params.HTTPRequest) {
+				return middleware.ResponderFunc(func(w http.ResponseWriter, _ runtime.Producer) {
+					w.Header().Set("Content-Type", "text/plain")
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte(r.router.Text()))
+				})
+			}
+			table := r.router.Table()
+			return routes.NewGetRoutesOK().WithPayload(&table)
 		})
 
 	api.RegisterXappHandler = operations.RegisterXappHandlerFunc(
@@ -187,8 +697,14 @@ func (r *Restful) SetupHandler() *operations.AppManagerAPI {
 			appmgr.Logger.Info("endpoint is %s", (*params.RegisterRequest.HTTPEndpoint))
 			appmgr.Logger.Info("rmrendpoint is %s", (*params.RegisterRequest.RmrEndpoint))
 			if result, err := r.RegisterXapp(*params.RegisterRequest); err == nil {
-				go r.rh.PublishSubscription(*result, models.EventTypeDeployed)
-				return operations.NewRegisterXappCreated()
+				go r.rh.PublishSubscriptionContext(params.HTTPRequest.Context(), *result, models.EventTypeDeployed)
+				resp := operations.NewRegisterXappCreated()
+				for _, i := range result.Instances {
+					if unknown := r.unknownMessages(appmgr.RtmData{TxMessages: i.TxMessages, RxMessages: i.RxMessages}); unknown != "" {
+						resp.WithWarning(warningHeader([]string{unknown}))
+					}
+				}
+				return resp
 			}
 			return operations.NewRegisterXappBadRequest()
 		})
@@ -197,18 +713,234 @@ func (r *Restful) SetupHandler() *operations.AppManagerAPI {
 		func(params operations.DeregisterXappParams) middleware.Responder {
 			appmgr.Logger.Info("appname is %s", (*params.DeregisterRequest.AppName))
 			if result, err := r.DeregisterXapp(*params.DeregisterRequest); err == nil {
-				go r.rh.PublishSubscription(*result, models.EventTypeUndeployed)
+				go r.rh.PublishSubscriptionContext(params.HTTPRequest.Context(), *result, models.EventTypeUndeployed)
 				return operations.NewDeregisterXappNoContent()
 			}
 			return operations.NewDeregisterXappBadRequest()
 		})
 
+	api.HeartbeatXappHandler = operations.HeartbeatXappHandlerFunc(
+		func(params operations.HeartbeatXappParams) middleware.Responder {
+			req := params.HeartbeatRequest
+			if req == nil || req.AppName == nil || req.AppInstanceName == nil {
+				return operations.NewHeartbeatXappBadRequest()
+			}
+			ns, err := r.cm.ValidateNamespace(req.Namespace)
+			if err != nil {
+				appmgr.Logger.Error("Heartbeat of %s rejected: %v", *req.AppName, err)
+				return operations.NewHeartbeatXappBadRequest()
+			}
+			if err := r.monitor.Heartbeat(ns, *req.AppName, *req.AppInstanceName); err != nil {
+				return operations.NewHeartbeatXappNotFound()
+			}
+			return operations.NewHeartbeatXappNoContent()
+		})
+
+	// URL: /ric/v1/audit
+	api.AuditGetAuditRecordsHandler = audit.GetAuditRecordsHandlerFunc(
+		func(params audit.GetAuditRecordsParams) middleware.Responder {
+			f := auditlog.Filter{Namespace: namespaceOf(params.Namespace)}
+			if params.Since != nil {
+				f.Since = time.Time(*params.Since)
+			}
+			if params.Until != nil {
+				f.Until = time.Time(*params.Until)
+			}
+			if !f.Since.IsZero() && !f.Until.IsZero() && !f.Since.Before(f.Until) {
+				return audit.NewGetAuditRecordsBadRequest()
+			}
+			if params.XappName != nil {
+				f.XappName = *params.XappName
+			}
+			if params.Limit != nil {
+				f.Limit = int(*params.Limit)
+			}
+
+			records, err := r.audit.List(f)
+			if err != nil {
+				return audit.NewGetAuditRecordsInternalServerError()
+			}
+			return audit.NewGetAuditRecordsOK().WithPayload(records)
+		})
+
 	return api
 }
 
+// pushXappConfig validates the config of a registered xApp against the schema of
+// its chart, if there is one, stores it as a new revision and pushes it to the
+// instances of the xApp
+func (r *Restful) pushXappConfig(req *http.Request, ns string, c models.XAppConfig, ifMatch *int64) middleware.Responder {
+	validationErrors, err := r.cm.Validate(c)
+	if validationErrors != nil {
+		return xapp.NewModifyXappConfigUnprocessableEntity().WithPayload(validationErrors)
+	}
+	if errors.Is(err, cfgmap.ErrNoSchema) {
+		appmgr.Logger.Info("No schema for registered xApp '%s', pushing the config unvalidated", *c.Metadata.XappName)
+	} else if err != nil {
+		appmgr.Logger.Error("Validating config of '%s' failed: %v", *c.Metadata.XappName, err)
+		return xapp.NewModifyXappConfigInternalServerError()
+	}
+
+	if err := r.cm.CheckRevision(*c.Metadata.XappName, ns, ifMatch); errors.Is(err, cfgmap.ErrRevisionMismatch) {
+		return xapp.NewModifyXappConfigPreconditionFailed()
+	} else if err != nil {
+		return xapp.NewModifyXappConfigInternalServerError()
+	}
+
+	result, err := r.pusher.PushContext(req.Context(), ns, *c.Metadata.XappName, c.Config)
+	if err != nil {
+		return xapp.NewModifyXappConfigInternalServerError()
+	}
+	var rejected []string
+	for _, s := range result {
+		if *s.Status != models.ConfigPushStatusStatusAccepted {
+			rejected = append(rejected, *s.InstanceName)
+		}
+	}
+	// A config that no instance took is not a revision of the xApp
+	if len(rejected) == len(result) {
+		return xapp.NewModifyXappConfigBadGateway().WithPayload(result)
+	}
+
+	description := "push"
+	if len(rejected) != 0 {
+		description = fmt.Sprintf("push, rejected by %s", strings.Join(rejected, ", "))
+	}
+	_, err = r.cm.RecordConfig(c, ifMatch, requestAuthor(req), description)
+	if errors.Is(err, cfgmap.ErrRevisionMismatch) {
+		return xapp.NewModifyXappConfigPreconditionFailed()
+	}
+	if err != nil {
+		return xapp.NewModifyXappConfigInternalServerError()
+	}
+
+	if len(rejected) != 0 {
+		return xapp.NewModifyXappConfigBadGateway().WithPayload(result)
+	}
+	return xapp.NewModifyXappConfigOK().WithPayload(models.ConfigValidationErrors{})
+}
+
+// requestAuthor names the originator of a change, as recorded in the config
+// history and the audit log: the authenticated caller, or else the client
+// address. Nothing the client sends is trusted to name it, only the replica
+// forwarding the request, see clientAddr.
+func requestAuthor(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	if p := auth.PrincipalFrom(req.Context()); p != nil {
+		return p.Name
+	}
+	return "anonymous@" + clientAddr(req)
+}
+
+// parseIfMatch reads the config revision from an If-Match header, which may be
+// quoted like an ETag. "*" matches any revision.
+func parseIfMatch(ifMatch *string) (*int64, error) {
+	if ifMatch == nil || *ifMatch == "" || *ifMatch == "*" {
+		return nil, nil
+	}
+
+	revision, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(*ifMatch, "W/"), `"`), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// instrumentOperations records the latency of every request by swagger operation
+func instrumentOperations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(sw, req)
+
+		metrics.RestRequestDuration.Observe(metrics.Since(start), operationID(req), strconv.Itoa(sw.code))
+	})
+}
+
+// operationID returns the swagger operation ID of the matched route of req
+func operationID(req *http.Request) string {
+	if route := middleware.MatchedRouteFrom(req); route != nil && route.Operation != nil {
+		return route.Operation.ID
+	}
+	return "unknown"
+}
+
+// statusWriter remembers the status code written to the response
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// collectMetrics counts the registered and deployed xApps, and the queued
+// notifications, before every scrape
+func (r *Restful) collectMetrics() {
+	metrics.Xapps.Reset()
+	for _, i := range r.registry.List() {
+		metrics.Xapps.Add(1, i.Namespace, i.Status, "registered")
+	}
+	for _, x := range r.deployedXapps() {
+		metrics.Xapps.Add(1, x.Namespace, x.Status, "deployed")
+	}
+
+	metrics.WebhookQueueDepth.Reset()
+	for id, depth := range r.rh.QueueDepths() {
+		metrics.WebhookQueueDepth.Set(float64(depth), id)
+	}
+}
+
+// deployedXapps returns the xApps deployed by helm. Listing them takes several
+// helm and kubectl commands, so the list is refreshed at most every
+// 'metrics.deployedRefresh' seconds.
+func (r *Restful) deployedXapps() models.AllDeployedXapps {
+	r.deployedMutex.Lock()
+	defer r.deployedMutex.Unlock()
+
+	refresh := time.Duration(viper.GetInt("metrics.deployedRefresh")) * time.Second
+	if refresh <= 0 {
+		refresh = defaultDeployedRefresh
+	}
+	if r.deployed == nil || time.Since(r.deployedAt) >= refresh {
+		if xapps, err := r.helm.StatusAll(""); err == nil {
+			r.deployed = xapps
+		} else {
+			appmgr.Logger.Info("Listing deployed xApps failed: %v", err)
+		}
+		r.deployedAt = time.Now()
+	}
+	return r.deployed
+}
+
+// acceptsText returns true if the request prefers text/plain over JSON
+func acceptsText(req *http.Request) bool {
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		switch mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(accept)); mediaType {
+		case "text/plain":
+			return true
+		case "application/json", "*/*":
+			return false
+		}
+	}
+	return false
+}
+
+func namespaceOf(ns *string) string {
+	if ns == nil {
+		return ""
+	}
+	return *ns
+}
+
 func httpGetXAppsconfig(url string) *string {
 	appmgr.Logger.Info("Invoked httprestful.httpGetXApps: " + url)
-	resp, err := http.Get(url)
+	client := http.Client{Transport: tracing.Transport(certs.Transport())}
+	resp, err := client.Get(url)
 	if err != nil {
 		appmgr.Logger.Error("Error while querying config to Xapp: ", err.Error())
 		return nil
@@ -280,33 +1012,36 @@ func parseConfig(config *string) *appmgr.RtmData {
 }
 
 func (r *Restful) RegisterXapp(params models.RegisterRequest) (xapp *models.Xapp, err error) {
-	return r.PrepareConfig(params, true)
+	if xapp, err = r.PrepareConfig(params); err == nil {
+		r.updateRoutes()
+	}
+	return
 }
 
 func (r *Restful) DeregisterXapp(params models.DeregisterRequest) (xapp *models.Xapp, err error) {
-	var registeredlist models.RegisterRequest
-	registeredlist.AppName = params.AppName
-	registeredlist.AppInstanceName = params.AppInstanceName
-	if _, found := xappmap[*params.AppName]; found {
-		var x models.Xapp
-		x.Instances = append(x.Instances, xappmap[*params.AppName][*params.AppInstanceName].xappInstance)
-		registeredlist.HTTPEndpoint = &xappmap[*params.AppName][*params.AppInstanceName].httpendpoint
-		delete(xappmap[*params.AppName], *params.AppInstanceName)
-		if len(xappmap[*params.AppName]) == 0 {
-			delete(xappmap, *params.AppName)
-		}
-		r.rh.UpdateAppData(registeredlist, true)
-		return &x, nil
-	} else {
-		appmgr.Logger.Error("XApp Instance %v Not Found", *params.AppName)
-		return nil, errors.New("XApp Instance Not Found")
+	ns := r.cm.GetNamespace(params.Namespace)
+	i, err := r.registry.Remove(ns, *params.AppName, *params.AppInstanceName)
+	if err != nil {
+		appmgr.Logger.Error("XApp Instance %v/%v/%v: %v", ns, *params.AppName, *params.AppInstanceName, err)
+		return nil, err
 	}
+	r.updateRoutes()
+
+	var x models.Xapp
+	x.Name = &i.AppName
+	x.Namespace = i.Namespace
+	x.Version = i.AppVersion
+	x.Instances = append(x.Instances, i.Instance)
+	return &x, nil
 }
 
-func (r *Restful) PrepareConfig(params models.RegisterRequest, updateflag bool) (xapp *models.Xapp, err error) {
+func (r *Restful) PrepareConfig(params models.RegisterRequest) (xapp *models.Xapp, err error) {
 	maxRetries := 5
 	configPresent := false
 	var xappconfig *string
+	if params.Namespace, err = r.cm.ValidateNamespace(params.Namespace); err != nil {
+		return nil, err
+	}
 	appmgr.Logger.Info("http endpoint is %s", *params.HTTPEndpoint)
 	for i := 1; i <= maxRetries; i++ {
 		if params.Config != "" {
@@ -315,21 +1050,23 @@ func (r *Restful) PrepareConfig(params models.RegisterRequest, updateflag bool)
 			configPresent = true
 		} else {
 			appmgr.Logger.Info("Getting config from xapp:")
-			xappconfig = httpGetXAppsconfig(fmt.Sprintf("http://%s%s", *params.HTTPEndpoint, params.ConfigPath))
+			xappconfig = httpGetXAppsconfig(certs.XappURL(*params.HTTPEndpoint, params.ConfigPath))
 		}
 
 		if xappconfig != nil {
 			data := parseConfig(xappconfig)
 			if data != nil {
+				if err := r.validateMessages(*params.AppName, *data); err != nil {
+					return nil, err
+				}
 				var xapp models.Xapp
 
 				xapp.Name = params.AppName
+				xapp.Namespace = params.Namespace
 				xapp.Version = params.AppVersion
 				//xapp.Status = params.Status
 
-				r.rh.UpdateAppData(params, updateflag)
 				return r.FillInstanceData(params, &xapp, *data, configPresent)
-				break
 			} else {
 				appmgr.Logger.Error("No Data from xapp")
 			}
@@ -343,102 +1080,252 @@ func (r *Restful) PrepareConfig(params models.RegisterRequest, updateflag bool)
 	return nil, errors.New("Unable to get configmap after 5 retries")
 }
 
+// validateMessages checks the messages of an xApp against the message catalogue.
+// Unknown messages are rejected if 'messages.rejectUnknown' is set, otherwise
+// they are logged and reported with unknownMessages in the response.
+func (r *Restful) validateMessages(name string, rtData appmgr.RtmData) error {
+	if r.catalogue == nil {
+		return nil
+	}
+
+	err := r.catalogue.Validate(rtData)
+	if err == nil {
+		return nil
+	}
+	if viper.GetBool("messages.rejectUnknown") {
+		appmgr.Logger.Error("Rejecting xApp %s: %v", name, err)
+		return err
+	}
+	appmgr.Logger.Info("xApp %s uses %v", name, err)
+	return nil
+}
+
+// unknownMessages describes the messages of an xApp that are not in the message
+// catalogue, empty if there are none
+func (r *Restful) unknownMessages(rtData appmgr.RtmData) string {
+	if r.catalogue == nil {
+		return ""
+	}
+	if err := r.catalogue.Validate(rtData); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// checkChart checks the messages in the config file of the chart to be deployed
+// against the catalogue, and the message flows of the new xApp against the running
+// ones. The flow issues are only returned as warnings unless
+// 'flowAnalysis.onDeploy' is 'block', as are the unknown messages unless
+// 'messages.rejectUnknown' is set.
+func (r *Restful) checkChart(ctx context.Context, x models.XappDescriptor) (issues []*models.FlowIssue, warnings []string, err error) {
+	mode := viper.GetString("flowAnalysis.onDeploy")
+	if r.catalogue == nil && mode == flowCheckOff {
+		return
+	}
+
+	rtData, err := r.cm.GetChartRtmData(ctx, *x.XappName, x.HelmVersion)
+	if err != nil {
+		// Let the install itself report a missing chart
+		appmgr.Logger.Info("Messages of chart '%s' not checked: %v", *x.XappName, err)
+		return nil, nil, nil
+	}
+	if err = r.validateMessages(*x.XappName, rtData); err != nil {
+		return
+	}
+	if unknown := r.unknownMessages(rtData); unknown != "" {
+		warnings = append(warnings, unknown)
+	}
+	if mode == flowCheckOff {
+		return
+	}
+
+	name := *x.XappName
+	if x.ReleaseName != "" {
+		name = x.ReleaseName
+	}
+	ns := r.cm.GetNamespace(x.Namespace)
+
+	// The new xApp replaces an earlier release of the same name
+	xapps := models.AllDeployedXapps{}
+	for _, app := range r.messagingApps() {
+		if app.Name != nil && (*app.Name != name || app.Namespace != ns) {
+			xapps = append(xapps, app)
+		}
+	}
+	instance := name
+	xapps = append(xapps, &models.Xapp{
+		Name:      &name,
+		Namespace: ns,
+		Instances: []*models.XappInstance{{
+			Name:       &instance,
+			TxMessages: rtData.TxMessages,
+			RxMessages: rtData.RxMessages,
+			Policies:   rtData.Policies,
+		}},
+	})
+
+	analysis := r.analyzer.Analyze(xapps)
+	issues = rmr.Involving(analysis.Issues, rmr.AppID(ns, name))
+	for _, issue := range issues {
+		appmgr.Logger.Info("Deploying xApp %s: %s", name, issue.Description)
+	}
+	if len(issues) != 0 && mode == flowCheckBlock {
+		return issues, warnings, errors.New("message flow issues")
+	}
+	return issues, warnings, nil
+}
+
+// warningHeader formats the descriptions as the value of an HTTP Warning header
+func warningHeader(descriptions []string) string {
+	return fmt.Sprintf(`299 appmgr "%s"`, strings.Join(descriptions, "; "))
+}
+
+func (r *Restful) knownMessage(name string) bool {
+	if r.catalogue == nil {
+		return false
+	}
+	_, found := r.catalogue.ID(name)
+	return found
+}
+
+// messagingApps returns the registered xApps together with the deployed ones
+// that have not registered, each instance once
+func (r *Restful) messagingApps() models.AllDeployedXapps {
+	xapps, _ := r.GetApps("")
+	deployed := r.deployedXapps()
+
+	seen := make(map[string]bool)
+	for _, x := range xapps {
+		for _, i := range x.Instances {
+			seen[x.Namespace+"/"+*x.Name+"/"+*i.Name] = true
+		}
+	}
+	for _, x := range deployed {
+		if x == nil || x.Name == nil {
+			continue
+		}
+		var instances []*models.XappInstance
+		for _, i := range x.Instances {
+			if i != nil && i.Name != nil && !seen[x.Namespace+"/"+*x.Name+"/"+*i.Name] {
+				instances = append(instances, i)
+			}
+		}
+		if len(instances) != 0 {
+			// The deployed xApps are cached, so they are copied instead of modified
+			unregistered := *x
+			unregistered.Instances = instances
+			xapps = append(xapps, &unregistered)
+		}
+	}
+	return xapps
+}
+
 func (r *Restful) FillInstanceData(params models.RegisterRequest, xapp *models.Xapp, rtData appmgr.RtmData, configFlag bool) (xapps *models.Xapp, err error) {
 
 	endPointStr := strings.Split(*params.RmrEndpoint, ":")
+	if len(endPointStr) != 2 {
+		return nil, fmt.Errorf("invalid rmr endpoint '%s'", *params.RmrEndpoint)
+	}
+
 	var x models.XappInstance
 	x.Name = params.AppInstanceName
 	//x.Status = strings.ToLower(params.Status)
-	x.Status = "deployed"
+	x.Status = registry.StatusDeployed
 	//x.IP = endPointStr[0]
-	x.IP = fmt.Sprintf("service-ricxapp-%s-rmr.ricxapp", *params.AppInstanceName)
+	x.IP = fmt.Sprintf("service-%s-%s-rmr.%s", params.Namespace, *params.AppInstanceName, params.Namespace)
 	x.Port, _ = strconv.ParseInt(endPointStr[1], 10, 64)
 	x.TxMessages = rtData.TxMessages
 	x.RxMessages = rtData.RxMessages
 	x.Policies = rtData.Policies
 	xapp.Instances = append(xapp.Instances, &x)
-	rmrsrvname := fmt.Sprintf("service-ricxapp-%s-rmr.ricxapp:%s", *params.AppInstanceName, x.Port)
-
-	a := &XappData{httpendpoint: *params.HTTPEndpoint,
-		rmrendpoint:      *params.RmrEndpoint,
-		rmrserviceep:     rmrsrvname,
-		status:           "deployed",
-		xappname:         *params.AppName,
-		xappversion:      params.AppVersion,
-		xappinstname:     *params.AppInstanceName,
-		xappconfigpath:   params.ConfigPath,
-		xappdynamiconfig: configFlag,
-		xappInstance:     &x}
-
-	if _, ok := xappmap[*params.AppName]; ok {
-		xappmap[*params.AppName][*params.AppInstanceName] = a
-		appmgr.Logger.Info("appname already present, %v", xappmap[*params.AppName])
-	} else {
-		xappmap[*params.AppName] = make(map[string]*XappData)
-		xappmap[*params.AppName][*params.AppInstanceName] = a
-		appmgr.Logger.Info("Creating app instance, %v", xappmap[*params.AppName])
+	rmrsrvname := fmt.Sprintf("%s:%d", x.IP, x.Port)
+
+	err = r.registry.Add(registry.Instance{
+		Namespace:     params.Namespace,
+		AppName:       *params.AppName,
+		InstanceName:  *params.AppInstanceName,
+		AppVersion:    params.AppVersion,
+		HTTPEndpoint:  *params.HTTPEndpoint,
+		RmrEndpoint:   *params.RmrEndpoint,
+		RmrServiceEp:  rmrsrvname,
+		ConfigPath:    params.ConfigPath,
+		DynamicConfig: configFlag,
+		Status:        registry.StatusDeployed,
+		Instance:      &x,
+	})
+	if err != nil {
+		return nil, err
 	}
+	appmgr.Logger.Info("Registered app instance %s/%s/%s", params.Namespace, *params.AppName, *params.AppInstanceName)
 
 	return xapp, nil
 
 }
 
-func (r *Restful) GetApps() (xapps models.AllDeployedXapps, err error) {
+// GetApps returns the registered xApps in the given namespace, or in all namespaces if it is empty
+func (r *Restful) GetApps(namespace string) (xapps models.AllDeployedXapps, err error) {
 	xapps = models.AllDeployedXapps{}
-	for _, v := range xappmap {
-		var x models.Xapp
-		for i, j := range v {
-			x.Status = j.status
-			x.Name = &j.xappname
-			x.Version = j.xappversion
-			appmgr.Logger.Info("Xapps details currently in map Appname = %v,rmrendpoint = %v,Status = %v", i, j.rmrendpoint, j.status)
-			x.Instances = append(x.Instances, j.xappInstance)
+	var x *models.Xapp
+	for _, i := range r.registry.List() {
+		if namespace != "" && i.Namespace != namespace {
+			continue
+		}
+		if x == nil || *x.Name != i.AppName || x.Namespace != i.Namespace {
+			name := i.AppName
+			x = &models.Xapp{Name: &name, Namespace: i.Namespace}
+			xapps = append(xapps, x)
 		}
-		xapps = append(xapps, &x)
+		x.Status = i.Status
+		x.Version = i.AppVersion
+		appmgr.Logger.Info("Xapps details currently in registry Appname = %v,rmrendpoint = %v,Status = %v", i.InstanceName, i.RmrEndpoint, i.Status)
+		x.Instances = append(x.Instances, i.Instance)
 	}
 
 	return xapps, nil
 
 }
 
-func (r *Restful) getAppConfig() (configList models.AllXappConfig) {
-	for _, v := range xappmap {
-		namespace := "ricxapp" //Namespace hardcoded, to be removed later
-		for _, j := range v {
-			var activeConfig interface{}
-			if j.xappdynamiconfig {
-				continue
-			}
-			xappconfig := httpGetXAppsconfig(fmt.Sprintf("http://%s%s", j.httpendpoint, j.xappconfigpath))
-
-			if xappconfig == nil {
-				appmgr.Logger.Info("config not found for %s", &j.xappname)
-				continue
-			}
-			json.Unmarshal([]byte(*xappconfig), &activeConfig)
-
-			c := models.XAppConfig{
-				Metadata: &models.ConfigMetadata{XappName: &j.xappname, Namespace: &namespace},
-				Config:   activeConfig,
-			}
-			configList = append(configList, &c)
+func (r *Restful) getAppConfig(namespace string) (configList models.AllXappConfig) {
+	for _, i := range r.registry.List() {
+		var activeConfig interface{}
+		if i.DynamicConfig || (namespace != "" && i.Namespace != namespace) {
+			continue
+		}
+		xappconfig := httpGetXAppsconfig(certs.XappURL(i.HTTPEndpoint, i.ConfigPath))
 
+		if xappconfig == nil {
+			appmgr.Logger.Info("config not found for %s", i.AppName)
+			continue
 		}
+		json.Unmarshal([]byte(*xappconfig), &activeConfig)
 
+		appName, ns := i.AppName, i.Namespace
+		c := models.XAppConfig{
+			Metadata: &models.ConfigMetadata{XappName: &appName, Namespace: &ns},
+			Config:   activeConfig,
+		}
+		configList = append(configList, &c)
 	}
 	return
 }
 
 func (r *Restful) symptomdataServer() {
-	http.HandleFunc("/ric/v1/symptomdata", func(w http.ResponseWriter, req *http.Request) {
-		d, _ := r.GetApps()
+	if err := certs.ListenAndServe(r.symptomdata); err != http.ErrServerClosed {
+		appmgr.Logger.Error("Symptomdata server stopped: %v", err)
+	}
+}
+
+func (r *Restful) symptomdataHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ric/v1/symptomdata", func(w http.ResponseWriter, req *http.Request) {
+		d, _ := r.GetApps("")
 		xappData := struct {
 			XappList         models.AllDeployedXapps `json:"xappList"`
 			ConfigList       models.AllXappConfig    `json:"configList"`
 			SubscriptionList models.AllSubscriptions `json:"subscriptionList"`
 		}{
 			d,
-			r.getAppConfig(),
+			r.getAppConfig(""),
 			r.rh.GetAllSubscriptions(),
 		}
 
@@ -448,6 +1335,5 @@ func (r *Restful) symptomdataServer() {
 		resp, _ := json.MarshalIndent(xappData, "", "    ")
 		w.Write(resp)
 	})
-
-	http.ListenAndServe(":8081", nil)
+	return mux
 }
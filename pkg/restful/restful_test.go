@@ -0,0 +1,141 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restful
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auditlog"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auth"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/leader"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restapi/operations/xapp"
+)
+
+const (
+	operatorToken = "operator-token"
+	viewerToken   = "viewer-token"
+)
+
+func TestMain(m *testing.M) {
+	appmgr.Init()
+	appmgr.Logger.SetLevel(0)
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+// fakeAuditLog keeps the audit records in memory
+type fakeAuditLog struct {
+	mutex   sync.Mutex
+	records models.AuditRecordList
+}
+
+func (l *fakeAuditLog) AddContext(ctx context.Context, r models.AuditRecord) (models.AuditRecord, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.records = append(l.records, &r)
+	return r, nil
+}
+
+func (l *fakeAuditLog) List(f auditlog.Filter) (models.AuditRecordList, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append(models.AuditRecordList{}, l.records...), nil
+}
+
+func (l *fakeAuditLog) Close() error {
+	return nil
+}
+
+// newTestAuthorizer lets the operator deploy and undeploy, and the viewer
+// only read. The health checks are anonymous.
+func newTestAuthorizer(t *testing.T) *auth.Authorizer {
+	policy, err := auth.NewPolicy([]string{"getHealthAlive"}, map[string]auth.Role{
+		"viewer":   {Operations: []string{"getAllXapps", "getXappByName"}},
+		"operator": {Operations: []string{"deployXapp", "undeployXapp"}, Inherits: []string{"viewer"}},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy failed: %v", err)
+	}
+
+	tokens := &auth.StaticAuthenticator{}
+	tokens.Add("ops-team", operatorToken, "operator")
+	tokens.Add("dashboard", viewerToken, "viewer")
+	return &auth.Authorizer{Authenticator: tokens, Policy: policy}
+}
+
+// newTestRestful returns a Restful whose deploy and undeploy operations only
+// answer, so that the middlewares can be tested without helm. The handler
+// counts the requests that got to the operations.
+func newTestRestful(authorizer *auth.Authorizer, elector *leader.Elector) (*Restful, *fakeAuditLog, *int) {
+	audit := &fakeAuditLog{}
+	handled := new(int)
+	r := &Restful{audit: audit, authorizer: authorizer, elector: elector, stop: make(chan struct{})}
+	r.api = r.SetupHandler()
+	r.api.XappDeployXappHandler = xapp.DeployXappHandlerFunc(
+		func(params xapp.DeployXappParams) middleware.Responder {
+			*handled++
+			return xapp.NewDeployXappCreated()
+		})
+	r.api.XappUndeployXappHandler = xapp.UndeployXappHandlerFunc(
+		func(params xapp.UndeployXappParams) middleware.Responder {
+			*handled++
+			return xapp.NewUndeployXappNoContent()
+		})
+	return r, audit, handled
+}
+
+func serveTestRequest(r *Restful, method, target, token, body string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	r.serveAPI().ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestAuthor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/ric/v1/xapps", nil)
+	assert.Equal(t, "anonymous@192.0.2.1:1234", requestAuthor(req))
+
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Name: "ops-team"}))
+	assert.Equal(t, "ops-team", requestAuthor(req))
+}
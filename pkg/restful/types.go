@@ -20,12 +20,21 @@
 package restful
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"time"
 
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auditlog"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/auth"
 	cfgmap "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/cm"
 	helmer "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/helm"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/leader"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/registry"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/restapi/operations"
 	resthook "gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/resthooks"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/rmr"
 )
 
 type CmdOptions struct {
@@ -41,11 +50,45 @@ type Resource struct {
 }
 
 type Restful struct {
-	api   *operations.AppManagerAPI
-	helm  *helmer.Helm
-	cm    *cfgmap.CM
-	rh    *resthook.Resthook
-	ready bool
+	api       *operations.AppManagerAPI
+	helm      *helmer.Helm
+	cm        *cfgmap.CM
+	rh        *resthook.Resthook
+	registry  *registry.Registry
+	monitor   *registry.Monitor
+	pusher    *registry.ConfigPusher
+	catalogue *rmr.Catalogue
+	router    *rmr.Router
+	analyzer  *rmr.Analyzer
+	audit     auditLog
+	ready     bool
+
+	// Authenticates the REST requests, nil if authentication is disabled
+	authorizer *auth.Authorizer
+
+	// Servers and background tasks, ended by Shutdown
+	server      *http.Server
+	symptomdata *http.Server
+	stop        chan struct{}
+	tasks       sync.WaitGroup
+
+	// Leader election among the replicas, elector is nil if it is disabled
+	elector      *leader.Elector
+	stopElection context.CancelFunc
+	electionDone chan struct{}
+	lost         chan error
+
+	// xApps deployed by helm, as last listed for the metrics
+	deployedMutex sync.Mutex
+	deployed      models.AllDeployedXapps
+	deployedAt    time.Time
+}
+
+// auditLog is the part of auditlog.Log used by the REST API
+type auditLog interface {
+	AddContext(ctx context.Context, r models.AuditRecord) (models.AuditRecord, error)
+	List(f auditlog.Filter) (models.AuditRecordList, error)
+	Close() error
 }
 
 //Taken from xapp-frame models
@@ -0,0 +1,516 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package resthooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/segmentio/ksuid"
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/metrics"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/webhook"
+)
+
+// To keep exhausted and pending notifications apart from the subscriptions stored under appmgrSdlNs
+const (
+	deadLetterSdlNs        = "appmgr-deadletter"
+	pendingSdlNs           = "appmgr-pending"
+	defaultQueueSize       = 100
+	defaultMaxBackoff      = 300 * time.Second
+	defaultTimeout         = 5 * time.Second
+	defaultRedriveInterval = 30 * time.Second
+	defaultMaxDeadLetters  = 1000
+	defaultDeadLetterAge   = 7 * 24 * time.Hour
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrDeadLetterNotFound   = errors.New("dead letter not found")
+	errQueueFull            = errors.New("delivery queue full")
+	errStopped              = errors.New("delivery stopped")
+)
+
+// enqueue hands d to the worker of its subscriber, starting one if needed.
+// If the queue is full d goes straight to the dead-letter store, and while
+// draining d is kept for the next appmgr.
+func (rh *Resthook) enqueue(d *delivery) error {
+	rh.queuesMutex.Lock()
+	if rh.draining {
+		rh.queuesMutex.Unlock()
+		rh.storePending(d)
+		return nil
+	}
+	q, found := rh.queues[d.SubscriptionID]
+	if !found {
+		q = &subscriberQueue{
+			deliveries: make(chan *delivery, rh.queueSize),
+			stop:       make(chan struct{}),
+			drain:      make(chan struct{}),
+			done:       make(chan struct{}),
+		}
+		rh.queues[d.SubscriptionID] = q
+		go rh.deliver(q)
+	}
+
+	// Sent under the lock, so that Drain does not miss a delivery queued after it started
+	select {
+	case q.deliveries <- d:
+		rh.queuesMutex.Unlock()
+		return nil
+	default:
+	}
+	rh.queuesMutex.Unlock()
+
+	appmgr.Logger.Error("Delivery queue of subscription %s is full", d.SubscriptionID)
+	d.LastError = errQueueFull.Error()
+	rh.storeDeadLetter(d)
+	return errQueueFull
+}
+
+// stopQueue terminates the worker of the given subscriber, dropping whatever is still queued
+func (rh *Resthook) stopQueue(id string) {
+	rh.queuesMutex.Lock()
+	defer rh.queuesMutex.Unlock()
+
+	if q, found := rh.queues[id]; found {
+		close(q.stop)
+		delete(rh.queues, id)
+	}
+}
+
+// QueueDepths returns the number of queued notifications of each subscriber
+func (rh *Resthook) QueueDepths() map[string]int {
+	rh.queuesMutex.Lock()
+	defer rh.queuesMutex.Unlock()
+
+	depths := make(map[string]int, len(rh.queues))
+	for id, q := range rh.queues {
+		depths[id] = len(q.deliveries)
+	}
+	return depths
+}
+
+func (rh *Resthook) stopAllQueues() {
+	rh.queuesMutex.Lock()
+	defer rh.queuesMutex.Unlock()
+
+	for id, q := range rh.queues {
+		close(q.stop)
+		delete(rh.queues, id)
+	}
+}
+
+// Drain stops taking notifications and delivers the queued ones until ctx is
+// done. The notifications that are left then, or come later, are stored as
+// pending for RunRedrive of the next appmgr.
+func (rh *Resthook) Drain(ctx context.Context) {
+	rh.queuesMutex.Lock()
+	rh.draining = true
+	queues := rh.queues
+	rh.queues = make(map[string]*subscriberQueue)
+	rh.queuesMutex.Unlock()
+
+	appmgr.Logger.Info("Draining the notification queues of %d subscriber(s)", len(queues))
+	for _, q := range queues {
+		close(q.drain)
+	}
+	for id, q := range queues {
+		select {
+		case <-q.done:
+		case <-ctx.Done():
+			appmgr.Logger.Error("Queue of subscription %s not drained in time, keeping the rest", id)
+			close(q.stop)
+			<-q.done
+		}
+	}
+}
+
+func (rh *Resthook) isDraining() bool {
+	rh.queuesMutex.Lock()
+	defer rh.queuesMutex.Unlock()
+	return rh.draining
+}
+
+func (rh *Resthook) deliver(q *subscriberQueue) {
+	defer close(q.done)
+	for {
+		select {
+		case d := <-q.deliveries:
+			rh.sendQueued(d, q.stop)
+		case <-q.drain:
+			rh.flushQueue(q)
+			return
+		case <-q.stop:
+			if rh.isDraining() {
+				rh.flushQueue(q)
+			}
+			return
+		}
+	}
+}
+
+// flushQueue sends what is left in q, or stores it as pending once q is stopped
+func (rh *Resthook) flushQueue(q *subscriberQueue) {
+	for {
+		select {
+		case d := <-q.deliveries:
+			select {
+			case <-q.stop:
+				rh.storePending(d)
+			default:
+				rh.sendQueued(d, q.stop)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// sendQueued sends d, keeping it as pending if it was stopped by a drain
+func (rh *Resthook) sendQueued(d *delivery, stop <-chan struct{}) {
+	if err := rh.send(d, stop); err == errStopped && rh.isDraining() {
+		rh.storePending(d)
+	}
+}
+
+// send posts d until the subscriber accepts it or the retry policy of the subscription
+// is exhausted, in which case d is moved to the dead-letter store
+func (rh *Resthook) send(d *delivery, stop <-chan struct{}) error {
+	for {
+		v, found := rh.subscriptions.Get(d.SubscriptionID)
+		if !found {
+			appmgr.Logger.Info("Subscription %s removed, dropping notification %s", d.SubscriptionID, d.ID)
+			return ErrSubscriptionNotFound
+		}
+		s := v.(SubscriptionInfo)
+		d.TargetURL = *s.req.Data.TargetURL
+
+		d.Attempts++
+		metrics.WebhookAttempts.Inc()
+		err := rh.post(d, s.req.Secret)
+		if err == nil {
+			return nil
+		}
+		metrics.WebhookFailures.Inc()
+		d.LastError = err.Error()
+
+		maxRetries, retryTimer := retryPolicy(s)
+		if d.Attempts >= maxRetries {
+			appmgr.Logger.Error("Notification %s to '%s' failed %d time(s), moving it to dead letters", d.ID, d.TargetURL, d.Attempts)
+			rh.storeDeadLetter(d)
+			return err
+		}
+
+		select {
+		case <-time.After(rh.backoff(retryTimer, d.Attempts)):
+		case <-stop:
+			return errStopped
+		}
+	}
+}
+
+// post sends d once, signed with the secret of the subscription. Retries of the
+// same delivery carry the same delivery ID, but a fresh timestamp and signature.
+func (rh *Resthook) post(d *delivery, secret string) error {
+	req, err := http.NewRequestWithContext(tracing.FromCarrier(d.Trace), http.MethodPost, d.TargetURL, bytes.NewBufferString(d.Payload))
+	if err != nil {
+		appmgr.Logger.Error("Creating request to '%s' failed: %v", d.TargetURL, err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	webhook.SetHeaders(req.Header, secret, d.ID, []byte(d.Payload))
+
+	appmgr.Logger.Info("Posting notification %s to TargetURL=%s", d.ID, d.TargetURL)
+	resp, err := rh.client.Do(req)
+	if err != nil {
+		appmgr.Logger.Info("Posting to subscription failed: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		appmgr.Logger.Info("Client returned error code: %d", resp.StatusCode)
+		return fmt.Errorf("client returned error code %d", resp.StatusCode)
+	}
+
+	appmgr.Logger.Info("subscription to '%s' dispatched, response code: %d", d.TargetURL, resp.StatusCode)
+	return nil
+}
+
+// backoff doubles the retry timer of the subscription on every failed attempt, up to
+// maxBackoff, and picks a random delay from the upper half to spread out the retries
+func (rh *Resthook) backoff(retryTimer time.Duration, attempt int64) time.Duration {
+	delay := retryTimer
+	for i := int64(1); i < attempt && delay < rh.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > rh.maxBackoff {
+		delay = rh.maxBackoff
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// retryPolicy returns the number of delivery attempts and the initial retry delay of s
+func retryPolicy(s SubscriptionInfo) (maxRetries int64, retryTimer time.Duration) {
+	maxRetries, retryTimer = 1, time.Second
+	if s.req.Data.MaxRetries != nil && *s.req.Data.MaxRetries > 0 {
+		maxRetries = *s.req.Data.MaxRetries
+	}
+	if s.req.Data.RetryTimer != nil && *s.req.Data.RetryTimer > 0 {
+		retryTimer = time.Duration(*s.req.Data.RetryTimer) * time.Second
+	}
+	return
+}
+
+func (rh *Resthook) storeDeadLetter(d *delivery) {
+	metrics.WebhookDeadLetters.Inc()
+	d.FailedAt = time.Now()
+	data, err := json.Marshal(d)
+	if err != nil {
+		appmgr.Logger.Error("json.marshal failed: %v ", err.Error())
+		return
+	}
+
+	if err := metrics.WithContext(tracing.FromCarrier(d.Trace), rh.db).Set(deadLetterSdlNs, d.ID, data); err != nil {
+		appmgr.Logger.Error("DB.session.Set failed: %v ", err.Error())
+	}
+}
+
+// storePending keeps d, with its attempts so far, for RunRedrive
+func (rh *Resthook) storePending(d *delivery) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		appmgr.Logger.Error("json.marshal failed: %v ", err.Error())
+		return
+	}
+
+	if err := metrics.WithContext(tracing.FromCarrier(d.Trace), rh.db).Set(pendingSdlNs, d.ID, data); err != nil {
+		appmgr.Logger.Error("DB.session.Set failed: %v ", err.Error())
+		return
+	}
+	appmgr.Logger.Info("Notification %s to subscription %s kept as pending", d.ID, d.SubscriptionID)
+}
+
+// RunRedrive queues the pending notifications left by a stopped appmgr, e.g. the
+// previous one during a rolling upgrade, and prunes the dead letters every
+// 'resthooks.redriveInterval' seconds until stop is closed
+func (rh *Resthook) RunRedrive(stop <-chan struct{}) {
+	interval := time.Duration(viper.GetInt("resthooks.redriveInterval")) * time.Second
+	if interval <= 0 {
+		interval = defaultRedriveInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		rh.RedrivePending()
+		rh.PruneDeadLetters()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// RedrivePending queues the pending notifications, oldest first
+func (rh *Resthook) RedrivePending() {
+	if rh.isDraining() {
+		return
+	}
+	keys, err := rh.db.GetAll(pendingSdlNs)
+	if err != nil || len(keys) == 0 {
+		return
+	}
+
+	// Delivery IDs are KSUIDs, so sorting them sorts by creation time
+	sort.Strings(keys)
+	values, err := rh.db.Get(pendingSdlNs, keys)
+	if err != nil {
+		appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
+		return
+	}
+	// Removed before queueing, a delivery that is stopped again is stored anew
+	if err := rh.db.Remove(pendingSdlNs, keys); err != nil {
+		appmgr.Logger.Error("DB.session.Remove failed: %v ", err.Error())
+		return
+	}
+
+	for _, key := range keys {
+		var d delivery
+		if data, ok := values[key].(string); !ok || json.Unmarshal([]byte(data), &d) != nil {
+			appmgr.Logger.Error("Skipping invalid pending notification '%s'", key)
+			continue
+		}
+		appmgr.Logger.Info("Redriving pending notification %s to subscription %s", d.ID, d.SubscriptionID)
+		rh.enqueue(&d)
+	}
+}
+
+// PruneDeadLetters drops the dead letters of notifications older than
+// 'resthooks.deadLetters.maxAgeDays', and then the oldest ones beyond
+// 'resthooks.deadLetters.maxRecords', so that a subscriber that stays down
+// doesn't fill the DB
+func (rh *Resthook) PruneDeadLetters() {
+	keys, err := rh.db.GetAll(deadLetterSdlNs)
+	if err != nil {
+		appmgr.Logger.Error("DB.session.GetAll failed: %v ", err.Error())
+		return
+	}
+
+	// Delivery IDs are KSUIDs, so sorting them sorts by creation time
+	sort.Strings(keys)
+	now := rh.now()
+	expired := 0
+	for expired < len(keys) {
+		id, err := ksuid.Parse(keys[expired])
+		if err != nil || now.Sub(id.Time()) <= rh.maxDeadLetterAge {
+			break
+		}
+		expired++
+	}
+	if excess := len(keys) - rh.maxDeadLetters; excess > expired {
+		expired = excess
+	}
+	if expired == 0 {
+		return
+	}
+
+	if err := rh.db.Remove(deadLetterSdlNs, keys[:expired]); err != nil {
+		appmgr.Logger.Error("DB.session.Remove failed: %v ", err.Error())
+		return
+	}
+	appmgr.Logger.Info("Dropped %d dead letters", expired)
+}
+
+// GetDeadLetters returns the notifications that could not be delivered, oldest first
+func (rh *Resthook) GetDeadLetters() (models.AllDeadLetters, error) {
+	letters := models.AllDeadLetters{}
+
+	keys, err := rh.db.GetAll(deadLetterSdlNs)
+	if err != nil {
+		appmgr.Logger.Error("DB.session.GetAll failed: %v ", err.Error())
+		return letters, err
+	}
+	if len(keys) == 0 {
+		return letters, nil
+	}
+
+	// Delivery IDs are KSUIDs, so sorting them sorts by creation time
+	sort.Strings(keys)
+	values, err := rh.db.Get(deadLetterSdlNs, keys)
+	if err != nil {
+		appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
+		return letters, err
+	}
+
+	for _, key := range keys {
+		var d delivery
+		if data, ok := values[key].(string); !ok || json.Unmarshal([]byte(data), &d) != nil {
+			appmgr.Logger.Error("Skipping invalid dead letter '%s'", key)
+			continue
+		}
+		letters = append(letters, d.toModel())
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetter queues the given dead letter again with a fresh retry budget
+func (rh *Resthook) ReplayDeadLetter(id string) error {
+	d, err := rh.getDeadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	if _, found := rh.subscriptions.Get(d.SubscriptionID); !found {
+		return ErrSubscriptionNotFound
+	}
+
+	// Remove first, a delivery that fails again is stored anew under the same ID
+	if err := rh.db.Remove(deadLetterSdlNs, []string{id}); err != nil {
+		appmgr.Logger.Error("DB.session.Remove failed: %v ", err.Error())
+		return err
+	}
+
+	appmgr.Logger.Info("Replaying notification %s to subscription %s", d.ID, d.SubscriptionID)
+	d.Attempts, d.LastError, d.FailedAt = 0, "", time.Time{}
+	return rh.enqueue(d)
+}
+
+// DeleteDeadLetter discards the given dead letter
+func (rh *Resthook) DeleteDeadLetter(id string) error {
+	if _, err := rh.getDeadLetter(id); err != nil {
+		return err
+	}
+
+	if err := rh.db.Remove(deadLetterSdlNs, []string{id}); err != nil {
+		appmgr.Logger.Error("DB.session.Remove failed: %v ", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (rh *Resthook) getDeadLetter(id string) (*delivery, error) {
+	values, err := rh.db.Get(deadLetterSdlNs, []string{id})
+	if err != nil {
+		appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
+		return nil, err
+	}
+
+	data, ok := values[id].(string)
+	if !ok {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	var d delivery
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		appmgr.Logger.Error("json.Unmarshal failed: %v ", err.Error())
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (d *delivery) toModel() *models.DeadLetter {
+	return &models.DeadLetter{
+		ID:             d.ID,
+		SubscriptionID: d.SubscriptionID,
+		TargetURL:      d.TargetURL,
+		EventType:      models.EventType(d.Event),
+		Attempts:       d.Attempts,
+		LastError:      d.LastError,
+		FailedAt:       strfmt.DateTime(d.FailedAt),
+		Notification:   d.Payload,
+	}
+}
@@ -0,0 +1,394 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package resthooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/webhook"
+)
+
+func TestNotifyDeliversInOrder(t *testing.T) {
+	h, _ := newTestResthook()
+	received := make(chan int64, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n SubscriptionNotification
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(t, json.Unmarshal(body, &n))
+		received <- n.Version
+	}))
+	defer ts.Close()
+
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeAll, int64(1), int64(1), ts.URL))
+	v, _ := h.subscriptions.Get(resp.ID)
+
+	xapp := getDummyXapp()
+	for seq := int64(1); seq <= 5; seq++ {
+		assert.Nil(t, h.notify(context.Background(), models.AllDeployedXapps{&xapp}, models.EventTypeDeployed, v.(SubscriptionInfo), seq))
+	}
+
+	for seq := int64(1); seq <= 5; seq++ {
+		select {
+		case got := <-received:
+			assert.Equal(t, seq, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("notification %d not delivered", seq)
+		}
+	}
+}
+
+func TestNotifyStoresDeadLetterIfQueueIsFull(t *testing.T) {
+	h, mSdl := newTestResthook()
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeAll, int64(1), int64(1), "http://localhost:8087/xapps_hook"))
+	v, _ := h.subscriptions.Get(resp.ID)
+
+	// A queue without a worker, so nothing is taken out of it
+	h.queues[resp.ID] = &subscriberQueue{deliveries: make(chan *delivery, 1), stop: make(chan struct{})}
+	mSdl.On("Set", deadLetterSdlNs, mock.Anything).Return(nil).Once()
+
+	xapp := getDummyXapp()
+	assert.Nil(t, h.notify(context.Background(), models.AllDeployedXapps{&xapp}, models.EventTypeDeployed, v.(SubscriptionInfo), 1))
+	assert.Equal(t, errQueueFull, h.notify(context.Background(), models.AllDeployedXapps{&xapp}, models.EventTypeDeployed, v.(SubscriptionInfo), 2))
+	mSdl.AssertExpectations(t)
+}
+
+func TestQueueDepths(t *testing.T) {
+	h, _ := newTestResthook()
+	h.queues["sub-1"] = &subscriberQueue{deliveries: make(chan *delivery, 2), stop: make(chan struct{})}
+	h.queues["sub-1"].deliveries <- &delivery{ID: "1"}
+
+	assert.Equal(t, map[string]int{"sub-1": 1}, h.QueueDepths())
+}
+
+func TestDeleteSubscriptionStopsDelivery(t *testing.T) {
+	h, _ := newTestResthook()
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeAll, int64(1), int64(1), "http://localhost:8087/xapps_hook"))
+	h.queues[resp.ID] = &subscriberQueue{deliveries: make(chan *delivery, 1), stop: make(chan struct{})}
+	q := h.queues[resp.ID]
+
+	h.DeleteSubscription(resp.ID)
+	assert.Equal(t, 0, len(h.queues))
+	_, open := <-q.stop
+	assert.False(t, open)
+}
+
+func TestDrainDeliversQueuedNotifications(t *testing.T) {
+	h, mSdl := newTestResthook()
+	received := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(webhook.HeaderDeliveryID)
+	}))
+	defer ts.Close()
+
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeAll, int64(1), int64(1), ts.URL))
+	v, _ := h.subscriptions.Get(resp.ID)
+	xapp := getDummyXapp()
+	for seq := int64(1); seq <= 3; seq++ {
+		h.notify(context.Background(), models.AllDeployedXapps{&xapp}, models.EventTypeDeployed, v.(SubscriptionInfo), seq)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h.Drain(ctx)
+	assert.Equal(t, 3, len(received))
+	assert.Equal(t, 0, len(h.queues))
+
+	// Once draining, notifications are kept for the next appmgr
+	stored := mSdl.expectPendingSet(t)
+	assert.Nil(t, h.notify(context.Background(), models.AllDeployedXapps{&xapp}, models.EventTypeDeployed, v.(SubscriptionInfo), 4))
+	assert.Equal(t, resp.ID, stored.SubscriptionID)
+	mSdl.AssertExpectations(t)
+}
+
+func TestDrainKeepsUndeliveredNotifications(t *testing.T) {
+	h, mSdl := newTestResthook()
+	h.maxBackoff = time.Hour
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeAll, int64(5), int64(60), ts.URL))
+	v, _ := h.subscriptions.Get(resp.ID)
+	xapp := getDummyXapp()
+	h.notify(context.Background(), models.AllDeployedXapps{&xapp}, models.EventTypeDeployed, v.(SubscriptionInfo), 1)
+
+	stored := mSdl.expectPendingSet(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	h.Drain(ctx)
+
+	mSdl.AssertExpectations(t)
+	assert.Equal(t, int64(1), stored.Attempts)
+	assert.Equal(t, "client returned error code 503", stored.LastError)
+}
+
+func TestRedrivePendingQueuesStoredNotifications(t *testing.T) {
+	h, mSdl := newTestResthook()
+	received := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(webhook.HeaderDeliveryID)
+	}))
+	defer ts.Close()
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeAll, int64(1), int64(1), ts.URL))
+
+	mSdl.On("GetAll", pendingSdlNs).Return([]string{"dl-2", "dl-1"}, nil)
+	mSdl.On("Get", pendingSdlNs, []string{"dl-1", "dl-2"}).Return(map[string]interface{}{
+		"dl-1": serializeDeadLetter(t, "dl-1", resp.ID),
+		"dl-2": serializeDeadLetter(t, "dl-2", resp.ID),
+	}, nil)
+	mSdl.On("Remove", pendingSdlNs, mock.Anything).Return(nil).Once()
+
+	h.RedrivePending()
+	for _, id := range []string{"dl-1", "dl-2"} {
+		select {
+		case got := <-received:
+			assert.Equal(t, id, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("notification %s not redriven", id)
+		}
+	}
+	mSdl.AssertExpectations(t)
+}
+
+func TestSendSignsNotification(t *testing.T) {
+	h, _ := newTestResthook()
+	var secret string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := webhook.VerifyRequest(secret, r, webhook.DefaultTolerance)
+		assert.Nil(t, err)
+		assert.Equal(t, `{"id":"dl-1"}`, string(body))
+		assert.Equal(t, "dl-1", r.Header.Get(webhook.HeaderDeliveryID))
+	}))
+	defer ts.Close()
+
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeAll, int64(1), int64(1), ts.URL))
+	secret = resp.Secret
+
+	assert.Nil(t, h.send(&delivery{ID: "dl-1", SubscriptionID: resp.ID, Payload: `{"id":"dl-1"}`}, nil))
+}
+
+func TestSendDropsNotificationIfSubscriptionIsDeleted(t *testing.T) {
+	h, _ := newTestResthook()
+	err := h.send(&delivery{ID: "dl-1", SubscriptionID: "Non-existent-ID"}, nil)
+	assert.Equal(t, ErrSubscriptionNotFound, err)
+}
+
+func TestBackoffGrowsExponentiallyUpToMax(t *testing.T) {
+	h, _ := newTestResthook()
+	h.maxBackoff = 8 * time.Second
+
+	for _, tc := range []struct {
+		attempt  int64
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{10, 4 * time.Second, 8 * time.Second},
+	} {
+		d := h.backoff(time.Second, tc.attempt)
+		assert.True(t, d >= tc.min && d <= tc.max, "attempt %d: %v", tc.attempt, d)
+	}
+}
+
+func TestGetDeadLettersSortedByID(t *testing.T) {
+	h, mSdl := newTestResthook()
+	mSdl.On("GetAll", deadLetterSdlNs).Return([]string{"dl-2", "dl-1"}, nil)
+	mSdl.On("Get", deadLetterSdlNs, []string{"dl-1", "dl-2"}).Return(map[string]interface{}{
+		"dl-1": serializeDeadLetter(t, "dl-1", "sub-1"),
+		"dl-2": serializeDeadLetter(t, "dl-2", "sub-2"),
+	}, nil)
+
+	letters, err := h.GetDeadLetters()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(letters))
+	assert.Equal(t, "dl-1", letters[0].ID)
+	assert.Equal(t, "sub-1", letters[0].SubscriptionID)
+	assert.Equal(t, models.EventTypeDeployed, letters[0].EventType)
+	assert.Equal(t, int64(3), letters[0].Attempts)
+	assert.Equal(t, "dl-2", letters[1].ID)
+}
+
+func TestGetDeadLettersReturnsEmptyList(t *testing.T) {
+	h, mSdl := newTestResthook()
+	mSdl.On("GetAll", deadLetterSdlNs).Return([]string{}, nil)
+
+	letters, err := h.GetDeadLetters()
+	assert.Nil(t, err)
+	assert.Equal(t, models.AllDeadLetters{}, letters)
+}
+
+func TestReplayDeadLetterDeliversNotification(t *testing.T) {
+	h, mSdl := newTestResthook()
+	received := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer ts.Close()
+
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeAll, int64(1), int64(1), ts.URL))
+	mSdl.On("Get", deadLetterSdlNs, []string{"dl-1"}).Return(map[string]interface{}{"dl-1": serializeDeadLetter(t, "dl-1", resp.ID)}, nil)
+	mSdl.On("Remove", deadLetterSdlNs, mock.Anything).Return(nil).Once()
+
+	assert.Nil(t, h.ReplayDeadLetter("dl-1"))
+	select {
+	case body := <-received:
+		assert.Equal(t, `{"id":"dl-1"}`, body)
+	case <-time.After(5 * time.Second):
+		t.Fatal("dead letter not replayed")
+	}
+	mSdl.AssertExpectations(t)
+}
+
+func TestReplayDeadLetterReturnsErrorIfSubscriptionIsMissing(t *testing.T) {
+	h, mSdl := newTestResthook()
+	mSdl.On("Get", deadLetterSdlNs, []string{"dl-1"}).Return(map[string]interface{}{"dl-1": serializeDeadLetter(t, "dl-1", "Non-existent-ID")}, nil)
+
+	assert.Equal(t, ErrSubscriptionNotFound, h.ReplayDeadLetter("dl-1"))
+}
+
+func TestReplayDeadLetterReturnsErrorIfNotFound(t *testing.T) {
+	h, mSdl := newTestResthook()
+	mSdl.On("Get", deadLetterSdlNs, []string{"dl-1"}).Return(map[string]interface{}{}, nil)
+
+	assert.Equal(t, ErrDeadLetterNotFound, h.ReplayDeadLetter("dl-1"))
+}
+
+func TestDeleteDeadLetter(t *testing.T) {
+	h, mSdl := newTestResthook()
+	mSdl.On("Get", deadLetterSdlNs, []string{"dl-1"}).Return(map[string]interface{}{"dl-1": serializeDeadLetter(t, "dl-1", "sub-1")}, nil)
+	mSdl.On("Remove", deadLetterSdlNs, mock.Anything).Return(nil).Once()
+
+	assert.Nil(t, h.DeleteDeadLetter("dl-1"))
+	mSdl.AssertExpectations(t)
+}
+
+func TestDeleteDeadLetterReturnsErrorIfNotFound(t *testing.T) {
+	h, mSdl := newTestResthook()
+	mSdl.On("Get", deadLetterSdlNs, []string{"dl-1"}).Return(map[string]interface{}{}, nil)
+
+	assert.Equal(t, ErrDeadLetterNotFound, h.DeleteDeadLetter("dl-1"))
+}
+
+func TestPruneDeadLettersDropsExpired(t *testing.T) {
+	h, mSdl := newTestResthook()
+	now := time.Now()
+	h.now = func() time.Time { return now }
+	h.maxDeadLetters, h.maxDeadLetterAge = 3, 24*time.Hour
+
+	expired, older, kept, newest := testDeliveryID(now, 72*time.Hour), testDeliveryID(now, 48*time.Hour),
+		testDeliveryID(now, 2*time.Hour), testDeliveryID(now, time.Hour)
+	mSdl.On("GetAll", deadLetterSdlNs).Return([]string{newest, expired, kept, older}, nil)
+	mSdl.On("Remove", deadLetterSdlNs, []string{expired, older}).Return(nil).Once()
+
+	h.PruneDeadLetters()
+	mSdl.AssertExpectations(t)
+}
+
+func TestPruneDeadLettersDropsOldestBeyondMaxRecords(t *testing.T) {
+	h, mSdl := newTestResthook()
+	now := time.Now()
+	h.now = func() time.Time { return now }
+	h.maxDeadLetters, h.maxDeadLetterAge = 2, 24*time.Hour
+
+	oldest, older, kept, newest := testDeliveryID(now, 4*time.Hour), testDeliveryID(now, 3*time.Hour),
+		testDeliveryID(now, 2*time.Hour), testDeliveryID(now, time.Hour)
+	mSdl.On("GetAll", deadLetterSdlNs).Return([]string{kept, newest, older, oldest}, nil)
+	mSdl.On("Remove", deadLetterSdlNs, []string{oldest, older}).Return(nil).Once()
+
+	h.PruneDeadLetters()
+	mSdl.AssertExpectations(t)
+}
+
+func TestPruneDeadLettersKeepsLettersWithinLimits(t *testing.T) {
+	h, mSdl := newTestResthook()
+	mSdl.On("GetAll", deadLetterSdlNs).Return([]string{testDeliveryID(time.Now(), time.Hour)}, nil)
+
+	h.PruneDeadLetters()
+	mSdl.AssertNotCalled(t, "Remove", deadLetterSdlNs, mock.Anything)
+}
+
+// testDeliveryID returns a delivery ID created age before now
+func testDeliveryID(now time.Time, age time.Duration) string {
+	id, _ := ksuid.NewRandomWithTime(now.Add(-age))
+	return id.String()
+}
+
+func newTestResthook() (*Resthook, *SdlMock) {
+	mSdl := new(SdlMock)
+	// Not every test adds a subscription
+	mSdl.On("Set", appmgrSdlNs, mock.Anything).Return(nil).Maybe()
+
+	h := createResthook(false, mSdl)
+	h.maxBackoff = 10 * time.Millisecond
+	return h, mSdl
+}
+
+func serializeDeadLetter(t *testing.T, id, subscriptionID string) string {
+	d := delivery{
+		ID:             id,
+		SubscriptionID: subscriptionID,
+		TargetURL:      "http://localhost:8087/xapps_hook",
+		Event:          string(models.EventTypeDeployed),
+		Payload:        `{"id":"` + id + `"}`,
+		Attempts:       3,
+		LastError:      "client returned error code 500",
+		FailedAt:       time.Now(),
+	}
+	data, err := json.Marshal(d)
+	assert.Nil(t, err)
+	//Cast data to string to act like a real SDL/Redis client
+	return string(data)
+}
+
+func (m *SdlMock) expectPendingSet(t *testing.T) *delivery {
+	stored := &delivery{}
+	m.On("Set", pendingSdlNs, mock.Anything).Run(
+		func(args mock.Arguments) {
+			sdlKVs := args.Get(1).([]interface{})
+			assert.Nil(t, json.Unmarshal(sdlKVs[1].([]byte), stored))
+		}).Return(nil).Once()
+	return stored
+}
+
+func (m *SdlMock) expectDeadLetterSet(t *testing.T, id string) *delivery {
+	stored := &delivery{}
+	m.On("Set", deadLetterSdlNs, mock.Anything).Run(
+		func(args mock.Arguments) {
+			sdlKVs := args.Get(1).([]interface{})
+			assert.Equal(t, 2, len(sdlKVs))
+			assert.Equal(t, id, sdlKVs[0])
+			assert.Nil(t, json.Unmarshal(sdlKVs[1].([]byte), stored))
+		}).Return(nil).Once()
+	return stored
+}
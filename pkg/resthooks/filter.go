@@ -0,0 +1,121 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package resthooks
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+// ValidateSubscription checks the patterns in the filter of a subscription request
+func ValidateSubscription(sr models.SubscriptionRequest) error {
+	if sr.Data == nil || sr.Data.Filter == nil {
+		return nil
+	}
+
+	f := sr.Data.Filter
+	for _, patterns := range [][]string{f.XappNames, f.Namespaces, f.InstanceNames} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid filter pattern '%s': %v", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// eventTypeMatches reports whether the subscription wants events of type et. The
+// event types of the filter, if any, take precedence over the single event type.
+func eventTypeMatches(data *models.SubscriptionData, et models.EventType) bool {
+	types := []models.EventType{data.EventType}
+	if data.Filter != nil && len(data.Filter.EventTypes) != 0 {
+		types = data.Filter.EventTypes
+	}
+
+	for _, t := range types {
+		if t == models.EventTypeAll || t == et {
+			return true
+		}
+	}
+	return false
+}
+
+// filterXapps returns the xApps, and the instances of them, selected by f. The
+// xApps are copied if some of their instances are left out.
+func filterXapps(f *models.SubscriptionFilter, xapps models.AllDeployedXapps) models.AllDeployedXapps {
+	if f == nil {
+		return xapps
+	}
+
+	selected := models.AllDeployedXapps{}
+	for _, x := range xapps {
+		if x == nil {
+			continue
+		}
+
+		var name string
+		if x.Name != nil {
+			name = *x.Name
+		}
+		namespace := x.Namespace
+		if namespace == "" {
+			namespace = viper.GetString("xapp.namespace")
+		}
+		if !matchAny(f.XappNames, name) || !matchAny(f.Namespaces, namespace) {
+			continue
+		}
+
+		if len(f.InstanceNames) == 0 {
+			selected = append(selected, x)
+			continue
+		}
+
+		var instances []*models.XappInstance
+		for _, i := range x.Instances {
+			if i != nil && i.Name != nil && matchAny(f.InstanceNames, *i.Name) {
+				instances = append(instances, i)
+			}
+		}
+		if len(instances) != 0 {
+			c := *x
+			c.Instances = instances
+			selected = append(selected, &c)
+		}
+	}
+	return selected
+}
+
+// matchAny reports whether name matches one of the glob patterns, or there are no patterns at all
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
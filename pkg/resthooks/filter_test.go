@@ -0,0 +1,121 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package resthooks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+func TestEventTypeMatches(t *testing.T) {
+	data := &models.SubscriptionData{EventType: models.EventTypeAll}
+	assert.True(t, eventTypeMatches(data, models.EventTypeDeployed))
+
+	data.EventType = models.EventTypeDeployed
+	assert.True(t, eventTypeMatches(data, models.EventTypeDeployed))
+	assert.False(t, eventTypeMatches(data, models.EventTypeUndeployed))
+
+	data.EventType = models.EventTypeAll
+	data.Filter = &models.SubscriptionFilter{EventTypes: []models.EventType{models.EventTypeUndeployed, models.EventTypeModified}}
+	assert.False(t, eventTypeMatches(data, models.EventTypeDeployed))
+	assert.True(t, eventTypeMatches(data, models.EventTypeUndeployed))
+	assert.True(t, eventTypeMatches(data, models.EventTypeModified))
+}
+
+func TestFilterXappsWithoutFilterReturnsAll(t *testing.T) {
+	x1, x2 := getDummyXapp(), generateXapp("anr", "deployed", "1.0", "anr-1", "running", "service-ricxapp-anr-rmr.ricxapp", "4560")
+	xapps := models.AllDeployedXapps{&x1, &x2}
+
+	assert.Equal(t, xapps, filterXapps(nil, xapps))
+	assert.Equal(t, xapps, filterXapps(&models.SubscriptionFilter{}, xapps))
+}
+
+func TestFilterXappsByNameAndNamespace(t *testing.T) {
+	x1, x2 := getDummyXapp(), generateXapp("anr", "deployed", "1.0", "anr-1", "running", "service-trialxapp-anr-rmr.trialxapp", "4560")
+	x2.Namespace = "trialxapp"
+	xapps := models.AllDeployedXapps{&x1, &x2}
+
+	selected := filterXapps(&models.SubscriptionFilter{XappNames: []string{"dummy-*"}}, xapps)
+	assert.Equal(t, models.AllDeployedXapps{&x1}, selected)
+
+	// An xApp without a namespace is in the default namespace
+	viper.Set("xapp.namespace", "ricxapp")
+	selected = filterXapps(&models.SubscriptionFilter{Namespaces: []string{"ricxapp"}}, xapps)
+	assert.Equal(t, models.AllDeployedXapps{&x1}, selected)
+
+	selected = filterXapps(&models.SubscriptionFilter{XappNames: []string{"dummy-*"}, Namespaces: []string{"trialxapp"}}, xapps)
+	assert.Equal(t, 0, len(selected))
+}
+
+func TestFilterXappsByInstanceName(t *testing.T) {
+	x := getDummyXapp()
+	name := "dummy-xapp-8984fc9fd-other"
+	x.Instances = append(x.Instances, &models.XappInstance{Name: &name})
+
+	selected := filterXapps(&models.SubscriptionFilter{InstanceNames: []string{"*-bkcbp"}}, models.AllDeployedXapps{&x})
+	assert.Equal(t, 1, len(selected))
+	assert.Equal(t, 1, len(selected[0].Instances))
+	assert.Equal(t, "dummy-xapp-8984fc9fd-bkcbp", *selected[0].Instances[0].Name)
+
+	// The original xApp is left intact
+	assert.Equal(t, 2, len(x.Instances))
+}
+
+func TestValidateSubscription(t *testing.T) {
+	sub := createSubscription(models.EventTypeAll, int64(1), int64(1), "http://localhost:8087/xapps_hook")
+	assert.Nil(t, ValidateSubscription(sub))
+
+	sub.Data.Filter = &models.SubscriptionFilter{XappNames: []string{"ueec", "anr*"}}
+	assert.Nil(t, ValidateSubscription(sub))
+
+	sub.Data.Filter.InstanceNames = []string{"[ueec"}
+	assert.NotNil(t, ValidateSubscription(sub))
+}
+
+func TestNotifyClientsHonoursFilters(t *testing.T) {
+	h, _ := newTestResthook()
+	matching := createSubscription(models.EventTypeAll, int64(1), int64(1), "http://localhost:8087/xapps_hook")
+	matching.Data.Filter = &models.SubscriptionFilter{XappNames: []string{"dummy-xapp"}}
+	other := createSubscription(models.EventTypeAll, int64(1), int64(1), "http://localhost:8087/xapps_hook")
+	other.Data.Filter = &models.SubscriptionFilter{XappNames: []string{"anr"}}
+	undeployed := createSubscription(models.EventTypeUndeployed, int64(1), int64(1), "http://localhost:8087/xapps_hook")
+
+	var ids []string
+	for _, sub := range []models.SubscriptionRequest{matching, other, undeployed} {
+		resp, err := h.AddSubscription(sub)
+		assert.Nil(t, err)
+		ids = append(ids, resp.ID)
+	}
+	assert.Equal(t, 3, len(h.subscriptions.Items()))
+
+	// Queues without workers, so that the queued notifications can be counted
+	for _, id := range ids {
+		h.queues[id] = &subscriberQueue{deliveries: make(chan *delivery, 1), stop: make(chan struct{})}
+	}
+
+	h.PublishSubscription(getDummyXapp(), models.EventTypeDeployed)
+	assert.Equal(t, 1, len(h.queues[ids[0]].deliveries))
+	assert.Equal(t, 0, len(h.queues[ids[1]].deliveries))
+	assert.Equal(t, 0, len(h.queues[ids[2]].deliveries))
+}
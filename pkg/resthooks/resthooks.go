@@ -20,34 +20,63 @@
 package resthooks
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	sdl "gerrit.o-ran-sc.org/r/ric-plt/sdlgo"
 	cmap "github.com/orcaman/concurrent-map"
 	"github.com/segmentio/ksuid"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
 	"net/http"
-	"strings"
+	"reflect"
 	"time"
 
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/certs"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/metrics"
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/tracing"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/webhook"
 )
 
 //To encapsulate xApp Manager's keys under their own namespace in a DB
 const (
 	appmgrSdlNs = "appmgr"
-	appDbSdlNs  = "appdb"
 )
 
 func NewResthook(restoreData bool) *Resthook {
-	return createResthook(restoreData, sdl.NewSyncStorage())
+	return createResthook(restoreData, metrics.InstrumentSdl(tracing.InstrumentSdl(sdl.NewSyncStorage())))
 }
 
 func createResthook(restoreData bool, sdlInst iSdl) *Resthook {
 	rh := &Resthook{
-		client: &http.Client{},
-		db:     sdlInst,
+		db:         sdlInst,
+		queues:     make(map[string]*subscriberQueue),
+		queueSize:  viper.GetInt("resthooks.queueSize"),
+		maxBackoff: time.Duration(viper.GetInt("resthooks.maxBackoff")) * time.Second,
+
+		maxDeadLetters:   viper.GetInt("resthooks.deadLetters.maxRecords"),
+		maxDeadLetterAge: time.Duration(viper.GetInt("resthooks.deadLetters.maxAgeDays")) * 24 * time.Hour,
+		now:              time.Now,
+	}
+
+	timeout := time.Duration(viper.GetInt("resthooks.timeout")) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	rh.client = &http.Client{Timeout: timeout, Transport: tracing.Transport(certs.Transport())}
+
+	if rh.queueSize <= 0 {
+		rh.queueSize = defaultQueueSize
+	}
+	if rh.maxBackoff <= 0 {
+		rh.maxBackoff = defaultMaxBackoff
+	}
+	if rh.maxDeadLetters <= 0 {
+		rh.maxDeadLetters = defaultMaxDeadLetters
+	}
+	if rh.maxDeadLetterAge <= 0 {
+		rh.maxDeadLetterAge = defaultDeadLetterAge
 	}
 
 	if restoreData {
@@ -58,14 +87,27 @@ func createResthook(restoreData bool, sdlInst iSdl) *Resthook {
 	return rh
 }
 
-func (rh *Resthook) AddSubscription(sr models.SubscriptionRequest) *models.SubscriptionResponse {
+// AddSubscription returns the existing subscription with the same target, event
+// type and filter, if any, without its secret. The secret of a subscription is
+// only changed by ModifySubscription.
+func (rh *Resthook) AddSubscription(sr models.SubscriptionRequest) (*models.SubscriptionResponse, error) {
 	for v := range rh.subscriptions.IterBuffered() {
 		r := v.Val.(SubscriptionInfo).req
-		if *r.Data.TargetURL == *sr.Data.TargetURL && r.Data.EventType == sr.Data.EventType {
+		if *r.Data.TargetURL == *sr.Data.TargetURL && r.Data.EventType == sr.Data.EventType && reflect.DeepEqual(r.Data.Filter, sr.Data.Filter) {
 			appmgr.Logger.Info("Similar subscription already exists!")
 			resp := v.Val.(SubscriptionInfo).resp
-			return &resp
+			return &resp, nil
+		}
+	}
+
+	// Notifications are signed with the secret given by the subscriber, or generated here
+	if sr.Secret == "" {
+		secret, err := webhook.GenerateSecret()
+		if err != nil {
+			appmgr.Logger.Error("Generating subscription secret failed: %v", err)
+			return nil, err
 		}
+		sr.Secret = secret
 	}
 
 	key := ksuid.New().String()
@@ -74,14 +116,19 @@ func (rh *Resthook) AddSubscription(sr models.SubscriptionRequest) *models.Subsc
 	rh.StoreSubscriptions(rh.subscriptions)
 
 	appmgr.Logger.Info("Sub: New subscription added: key=%s targetUl=%s eventType=%s", key, *sr.Data.TargetURL, sr.Data.EventType)
-	return &resp
+
+	// The secret is only ever returned here
+	created := resp
+	created.Secret = sr.Secret
+	return &created, nil
 }
 
 func (rh *Resthook) DeleteSubscription(id string) (*models.SubscriptionResponse, bool) {
 	if v, found := rh.subscriptions.Get(id); found {
-		appmgr.Logger.Info("Subscription id=%s found: %v ... deleting", id, v.(SubscriptionInfo).req)
+		appmgr.Logger.Info("Subscription id=%s found: %v ... deleting", id, v.(SubscriptionInfo).req.Data)
 
 		rh.subscriptions.Remove(id)
+		rh.stopQueue(id)
 		rh.StoreSubscriptions(rh.subscriptions)
 		resp := v.(SubscriptionInfo).resp
 		return &resp, found
@@ -91,8 +138,11 @@ func (rh *Resthook) DeleteSubscription(id string) (*models.SubscriptionResponse,
 
 func (rh *Resthook) ModifySubscription(id string, req models.SubscriptionRequest) (*models.SubscriptionResponse, bool) {
 	if s, found := rh.subscriptions.Get(id); found {
-		appmgr.Logger.Info("Subscription id=%s found: %v ... updating", id, s.(SubscriptionInfo).req)
+		appmgr.Logger.Info("Subscription id=%s found: %v ... updating", id, s.(SubscriptionInfo).req.Data)
 
+		if req.Secret == "" {
+			req.Secret = s.(SubscriptionInfo).req.Secret
+		}
 		resp := models.SubscriptionResponse{ID: id, Version: 0, EventType: req.Data.EventType}
 		rh.subscriptions.Set(id, SubscriptionInfo{id, req, resp})
 		rh.StoreSubscriptions(rh.subscriptions)
@@ -107,7 +157,7 @@ func (rh *Resthook) GetAllSubscriptions() (hooks models.AllSubscriptions) {
 	for v := range rh.subscriptions.IterBuffered() {
 		s := v.Val.(SubscriptionInfo)
 		r := v.Val.(SubscriptionInfo).req
-		hooks = append(hooks, &models.Subscription{&models.SubscriptionData{r.Data.EventType, r.Data.MaxRetries, r.Data.RetryTimer, r.Data.TargetURL}, s.Id})
+		hooks = append(hooks, &models.Subscription{Data: copySubscriptionData(r.Data), ID: s.Id})
 	}
 
 	return hooks
@@ -115,30 +165,58 @@ func (rh *Resthook) GetAllSubscriptions() (hooks models.AllSubscriptions) {
 
 func (rh *Resthook) GetSubscriptionById(id string) (models.Subscription, bool) {
 	if v, found := rh.subscriptions.Get(id); found {
-		appmgr.Logger.Info("Subscription id=%s found: %v", id, v.(SubscriptionInfo).req)
+		appmgr.Logger.Info("Subscription id=%s found: %v", id, v.(SubscriptionInfo).req.Data)
 		r := v.(SubscriptionInfo).req
-		return models.Subscription{&models.SubscriptionData{r.Data.EventType, r.Data.MaxRetries, r.Data.RetryTimer, r.Data.TargetURL}, id}, found
+		return models.Subscription{Data: copySubscriptionData(r.Data), ID: id}, found
 	}
 	return models.Subscription{}, false
 }
 
 func (rh *Resthook) PublishSubscription(x models.Xapp, et models.EventType) {
-	rh.NotifyClients(models.AllDeployedXapps{&x}, et)
+	rh.NotifyClientsContext(context.Background(), models.AllDeployedXapps{&x}, et)
+}
+
+// PublishSubscriptionContext is PublishSubscription within the trace of ctx. The
+// trace is carried on to the notifications, so ctx may be done meanwhile.
+func (rh *Resthook) PublishSubscriptionContext(ctx context.Context, x models.Xapp, et models.EventType) {
+	rh.NotifyClientsContext(ctx, models.AllDeployedXapps{&x}, et)
 }
 
 func (rh *Resthook) NotifyClients(xapps models.AllDeployedXapps, et models.EventType) {
+	rh.NotifyClientsContext(context.Background(), xapps, et)
+}
+
+// NotifyClientsContext is NotifyClients within the trace of ctx
+func (rh *Resthook) NotifyClientsContext(ctx context.Context, xapps models.AllDeployedXapps, et models.EventType) {
 	if len(xapps) == 0 || len(rh.subscriptions) == 0 {
 		appmgr.Logger.Info("Nothing to publish [%d:%d]", len(xapps), len(rh.subscriptions))
 		return
 	}
 
+	// Numbering and queueing under one lock keeps the per-subscriber order in line with Seq
+	rh.seqMutex.Lock()
+	defer rh.seqMutex.Unlock()
+
 	rh.Seq = rh.Seq + 1
 	for v := range rh.subscriptions.Iter() {
-		go rh.notify(xapps, et, v.Val.(SubscriptionInfo), rh.Seq)
+		s := v.Val.(SubscriptionInfo)
+		if !eventTypeMatches(s.req.Data, et) {
+			continue
+		}
+
+		// Each subscriber only gets the xApps and instances its filter selects
+		if selected := filterXapps(s.req.Data.Filter, xapps); len(selected) != 0 {
+			rh.notify(ctx, selected, et, s, rh.Seq)
+		}
 	}
 }
 
-func (rh *Resthook) notify(xapps models.AllDeployedXapps, et models.EventType, s SubscriptionInfo, seq int64) error {
+// notify queues the notification for delivery to the subscriber of s. The
+// delivery keeps the trace context, the posts continue the trace of the event.
+func (rh *Resthook) notify(ctx context.Context, xapps models.AllDeployedXapps, et models.EventType, s SubscriptionInfo, seq int64) (err error) {
+	ctx, span := tracing.Start(ctx, "resthooks.notify", attribute.String("subscription.id", s.Id), attribute.String("event.type", string(et)))
+	defer func() { tracing.End(span, err) }()
+
 	xappData, err := json.Marshal(xapps)
 	if err != nil {
 		appmgr.Logger.Info("json.Marshal failed: %v", err)
@@ -153,38 +231,16 @@ func (rh *Resthook) notify(xapps models.AllDeployedXapps, et models.EventType, s
 		return err
 	}
 
-	// Execute the request with retry policy
-	return rh.retry(s, func() error {
-		appmgr.Logger.Info("Posting notification to TargetURL=%s: %v", *s.req.Data.TargetURL, notif)
-		resp, err := http.Post(*s.req.Data.TargetURL, "application/json", bytes.NewBuffer(jsonData))
-		if err != nil {
-			appmgr.Logger.Info("Posting to subscription failed: %v", err)
-			return err
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			appmgr.Logger.Info("Client returned error code: %d", resp.StatusCode)
-			return err
-		}
-
-		appmgr.Logger.Info("subscription to '%s' dispatched, response code: %d", *s.req.Data.TargetURL, resp.StatusCode)
-		return nil
+	return rh.enqueue(&delivery{
+		ID:             ksuid.New().String(),
+		SubscriptionID: s.Id,
+		TargetURL:      *s.req.Data.TargetURL,
+		Event:          string(et),
+		Payload:        string(jsonData),
+		Trace:          tracing.Carrier(ctx),
 	})
 }
 
-func (rh *Resthook) retry(s SubscriptionInfo, fn func() error) error {
-	if err := fn(); err != nil {
-		// Todo: use exponential backoff, or similar mechanism
-		if *s.req.Data.MaxRetries--; *s.req.Data.MaxRetries > 0 {
-			time.Sleep(time.Duration(*s.req.Data.RetryTimer) * time.Second)
-			return rh.retry(s, fn)
-		}
-		rh.subscriptions.Remove(s.Id)
-		return err
-	}
-	return nil
-}
-
 func (rh *Resthook) StoreSubscriptions(m cmap.ConcurrentMap) {
 	for v := range m.Iter() {
 		s := v.Val.(SubscriptionInfo)
@@ -203,6 +259,31 @@ func (rh *Resthook) StoreSubscriptions(m cmap.ConcurrentMap) {
 func (rh *Resthook) RestoreSubscriptions() (m cmap.ConcurrentMap) {
 	rh.VerifyDBConnection()
 
+	m, _ = rh.loadSubscriptions()
+	return m
+}
+
+// ReloadSubscriptions replaces the subscriptions with the ones stored in SDL,
+// e.g. by the leader. They are kept as they are if SDL can't be read.
+func (rh *Resthook) ReloadSubscriptions() {
+	stored, err := rh.loadSubscriptions()
+	if err != nil {
+		return
+	}
+
+	for _, id := range rh.subscriptions.Keys() {
+		if !stored.Has(id) {
+			rh.subscriptions.Remove(id)
+			rh.stopQueue(id)
+		}
+	}
+	for v := range stored.IterBuffered() {
+		rh.subscriptions.Set(v.Key, v.Val)
+	}
+}
+
+// loadSubscriptions reads the stored subscriptions, up to the first that fails
+func (rh *Resthook) loadSubscriptions() (m cmap.ConcurrentMap, err error) {
 	m = cmap.New()
 	keys, err := rh.db.GetAll(appmgrSdlNs)
 	if err != nil {
@@ -214,20 +295,20 @@ func (rh *Resthook) RestoreSubscriptions() (m cmap.ConcurrentMap) {
 		value, err := rh.db.Get(appmgrSdlNs, []string{key})
 		if err != nil {
 			appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
-			return
+			return m, err
 		}
 
 		var item models.SubscriptionRequest
 		if err = json.Unmarshal([]byte(value[key].(string)), &item); err != nil {
 			appmgr.Logger.Error("json.Unmarshal failed: %v ", err.Error())
-			return
+			return m, err
 		}
 
 		resp := models.SubscriptionResponse{ID: key, Version: 0, EventType: item.Data.EventType}
 		m.Set(key, SubscriptionInfo{key, item, resp})
 	}
 
-	return m
+	return m, nil
 }
 
 func (rh *Resthook) VerifyDBConnection() {
@@ -243,75 +324,19 @@ func (rh *Resthook) VerifyDBConnection() {
 
 func (rh *Resthook) FlushSubscriptions() {
 	rh.db.RemoveAll(appmgrSdlNs)
+	rh.stopAllQueues()
 	rh.subscriptions = cmap.New()
 }
 
-func (rh *Resthook) UpdateAppData(params models.RegisterRequest, updateflag bool) {
-	appmgr.Logger.Info("Endpoint to be added in SDL: %s", *params.HTTPEndpoint)
-	if updateflag == false {
-		return
-	}
-
-	//Ensure config is empty string, as we dont want to store config in DB
-	if params.Config != "" {
-		params.Config = ""
-	}
-
-	value, err := rh.db.Get(appDbSdlNs, []string{"endpoints"})
-	if err != nil {
-		appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
-		return
-	}
-
-	appmgr.Logger.Info("List of Apps in SDL: %v", value["endpoints"])
-	var appsindb []string
-	var data string
-	dbflag := false
-
-	if value["endpoints"] != nil {
-		formstring := fmt.Sprintf("%s", value["endpoints"])
-		newstring := strings.Split(formstring, " ")
-		for i, _ := range newstring {
-			if len(newstring) == 1 && strings.Contains(newstring[i], *params.HTTPEndpoint) {
-				appmgr.Logger.Info("Removing Key %s", *params.HTTPEndpoint)
-				rh.db.Remove(appDbSdlNs, []string{"endpoints"})
-				dbflag = true
-				break
-			}
-			if strings.Contains(newstring[i], *params.HTTPEndpoint) {
-				appmgr.Logger.Info("Removing entry %s", *params.HTTPEndpoint)
-				dbflag = true
-				continue
-			}
-			appsindb = append(appsindb, newstring[i])
-			data = strings.Join(appsindb, " ")
-		}
-		rh.db.Set(appDbSdlNs, "endpoints", strings.TrimSpace(data))
-	}
-
-	if dbflag == false {
-		xappData, err := json.Marshal(params)
-		if err != nil {
-			appmgr.Logger.Info("json.Marshal failed: %v", err)
-			return
-		}
-		appsindb = append(appsindb, string(xappData))
-		data = strings.Join(appsindb, " ")
-		rh.db.Set(appDbSdlNs, "endpoints", strings.TrimSpace(data))
-	}
+// Close closes the SDL client of the resthooks, to be called after Drain
+func (rh *Resthook) Close() error {
+	return metrics.CloseStorage(rh.db)
 }
 
-func (rh *Resthook) GetAppsInSDL() *string {
-	value, err := rh.db.Get(appDbSdlNs, []string{"endpoints"})
-	if err != nil {
-		appmgr.Logger.Error("DB.session.Get failed: %v ", err.Error())
-		return nil
-	}
-	appmgr.Logger.Info("List of Apps in SDL: %v", value["endpoints"])
-	if value["endpoints"] == nil || value["endpoints"] == "" {
+func copySubscriptionData(d *models.SubscriptionData) *models.SubscriptionData {
+	if d == nil {
 		return nil
-	} else {
-		apps := fmt.Sprintf("%s", value["endpoints"])
-		return &apps
 	}
+	c := *d
+	return &c
 }
@@ -20,6 +20,7 @@
 package resthooks
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -47,6 +48,7 @@ func TestMain(m *testing.M) {
 	appmgr.Logger.SetLevel(0)
 
 	mockedSdl = new(SdlMock)
+	mockedSdl.On("Set", deadLetterSdlNs, mock.Anything).Return(nil)
 	NewResthook(false)
 	rh = createResthook(false, mockedSdl)
 	code := m.Run()
@@ -56,24 +58,53 @@ func TestMain(m *testing.M) {
 func TestAddSubscriptionSuccess(t *testing.T) {
 	var mockSdlRetOk error
 	subsReq := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
+	subsReq.Secret = "s3cr3t"
 
 	mockedSdl.expectDbSet(t, subsReq, mockSdlRetOk)
-	resp := rh.AddSubscription(subsReq)
+	resp, _ := rh.AddSubscription(subsReq)
 	assert.Equal(t, resp.Version, int64(0))
 	assert.Equal(t, resp.EventType, models.EventTypeCreated)
+	assert.Equal(t, "s3cr3t", resp.Secret)
+}
+
+func TestAddSubscriptionGeneratesSecret(t *testing.T) {
+	h, _ := newTestResthook()
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook"))
+	assert.NotEqual(t, "", resp.Secret)
+
+	v, _ := h.subscriptions.Get(resp.ID)
+	assert.Equal(t, resp.Secret, v.(SubscriptionInfo).req.Secret)
+	assert.Equal(t, "", v.(SubscriptionInfo).resp.Secret)
 }
 
 func TestAddSubscriptionExists(t *testing.T) {
-	resp := rh.AddSubscription(createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook"))
+	resp, _ := rh.AddSubscription(createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook"))
 	assert.Equal(t, resp.Version, int64(0))
 	assert.Equal(t, resp.EventType, models.EventTypeCreated)
+	assert.Equal(t, "", resp.Secret)
+}
+
+func TestAddSubscriptionExistsKeepsSecret(t *testing.T) {
+	h, _ := newTestResthook()
+	sub := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
+	sub.Secret = "old-secret"
+	created, _ := h.AddSubscription(sub)
+
+	sub.Secret = "new-secret"
+	resp, err := h.AddSubscription(sub)
+	assert.Nil(t, err)
+	assert.Equal(t, created.ID, resp.ID)
+	assert.Equal(t, "", resp.Secret)
+
+	v, _ := h.subscriptions.Get(resp.ID)
+	assert.Equal(t, "old-secret", v.(SubscriptionInfo).req.Secret)
 }
 
 func TestDeletesubscriptionSuccess(t *testing.T) {
 	var mockSdlRetOk error
 
 	mockedSdl.On("Set", appmgrSdlNs, mock.Anything).Return(mockSdlRetOk)
-	resp := rh.AddSubscription(createSubscription(models.EventTypeDeleted, int64(5), int64(10), "http://localhost:8087/xapps_hook2"))
+	resp, _ := rh.AddSubscription(createSubscription(models.EventTypeDeleted, int64(5), int64(10), "http://localhost:8087/xapps_hook2"))
 	assert.Equal(t, resp.Version, int64(0))
 	assert.Equal(t, resp.EventType, models.EventTypeDeleted)
 
@@ -91,7 +122,7 @@ func TestDeletesubscriptionInvalid(t *testing.T) {
 }
 
 func TestModifySubscriptionSuccess(t *testing.T) {
-	resp := rh.AddSubscription(createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook2"))
+	resp, _ := rh.AddSubscription(createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook2"))
 	assert.Equal(t, resp.Version, int64(0))
 	assert.Equal(t, resp.EventType, models.EventTypeCreated)
 
@@ -101,6 +132,16 @@ func TestModifySubscriptionSuccess(t *testing.T) {
 	assert.Equal(t, resp.EventType, models.EventTypeModified)
 }
 
+func TestModifySubscriptionKeepsSecret(t *testing.T) {
+	h, _ := newTestResthook()
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook2"))
+
+	_, ok := h.ModifySubscription(resp.ID, createSubscription(models.EventTypeModified, int64(5), int64(10), "http://localhost:8087/xapps_hook2"))
+	assert.True(t, ok)
+	v, _ := h.subscriptions.Get(resp.ID)
+	assert.Equal(t, resp.Secret, v.(SubscriptionInfo).req.Secret)
+}
+
 func TestModifySubscriptionForNonExistingSubscription(t *testing.T) {
 	resp, ok := rh.ModifySubscription("Non-existent-ID", createSubscription(models.EventTypeModified, int64(5), int64(10), "http://localhost:8087/xapps_hook2"))
 	assert.Equal(t, ok, false)
@@ -131,8 +172,8 @@ func TestGetSubscriptionByIdSuccess(t *testing.T) {
 
 	sub1 := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
 	sub2 := createSubscription(models.EventTypeModified, int64(5), int64(10), "http://localhost:8087/xapps_hook2")
-	r1 := rh.AddSubscription(sub1)
-	r2 := rh.AddSubscription(sub2)
+	r1, _ := rh.AddSubscription(sub1)
+	r2, _ := rh.AddSubscription(sub2)
 
 	resp1, ok := rh.GetSubscriptionById(r1.ID)
 	assert.Equal(t, ok, true)
@@ -157,7 +198,7 @@ func TestNotifySuccess(t *testing.T) {
 	flushExistingSubscriptions()
 
 	sub := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
-	resp := rh.AddSubscription(sub)
+	resp, _ := rh.AddSubscription(sub)
 
 	xapp := getDummyXapp()
 	ts := createHTTPServer(t, "POST", "/xapps_hook", 8087, http.StatusOK, nil)
@@ -165,39 +206,39 @@ func TestNotifySuccess(t *testing.T) {
 
 	v, ok := rh.subscriptions.Get(resp.ID)
 	assert.True(t, ok)
-	err := rh.notify(models.AllDeployedXapps{&xapp}, models.EventTypeUndeployed, v.(SubscriptionInfo), 1)
+	err := rh.notify(context.Background(), models.AllDeployedXapps{&xapp}, models.EventTypeUndeployed, v.(SubscriptionInfo), 1)
 	assert.Nil(t, err)
 }
 
-func TestNotifySuccessIfHttpErrorResponse(t *testing.T) {
-	flushExistingSubscriptions()
-
-	sub := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
-	resp := rh.AddSubscription(sub)
-
-	xapp := getDummyXapp()
-	ts := createHTTPServer(t, "POST", "/xapps_hook", 8087, http.StatusInternalServerError, nil)
+func TestSendStoresDeadLetterIfHttpErrorResponse(t *testing.T) {
+	h, mSdl := newTestResthook()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
 	defer ts.Close()
 
-	v, ok := rh.subscriptions.Get(resp.ID)
-	assert.True(t, ok)
-	err := rh.notify(models.AllDeployedXapps{&xapp}, models.EventTypeUndeployed, v.(SubscriptionInfo), 1)
-	assert.Nil(t, err)
-}
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeCreated, int64(2), int64(1), ts.URL))
+	stored := mSdl.expectDeadLetterSet(t, "dl-1")
 
-func TestNotifyReturnsErrorAfterRetriesIfNoHttpServer(t *testing.T) {
-	flushExistingSubscriptions()
+	err := h.send(&delivery{ID: "dl-1", SubscriptionID: resp.ID, Payload: "{}"}, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, int64(2), stored.Attempts)
+	assert.Equal(t, ts.URL, stored.TargetURL)
+	assert.Equal(t, "client returned error code 500", stored.LastError)
+	assert.False(t, stored.FailedAt.IsZero())
+}
 
-	sub := createSubscription(models.EventTypeCreated, int64(2), int64(1), "http://localhost:8087/xapps_hook")
-	resp := rh.AddSubscription(sub)
+func TestSendKeepsSubscriptionAfterRetriesIfNoHttpServer(t *testing.T) {
+	h, mSdl := newTestResthook()
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
 
-	xapp := getDummyXapp()
+	resp, _ := h.AddSubscription(createSubscription(models.EventTypeCreated, int64(2), int64(1), ts.URL))
+	mSdl.expectDeadLetterSet(t, "dl-1")
 
-	v, ok := rh.subscriptions.Get(resp.ID)
-	assert.True(t, ok)
-	err := rh.notify(models.AllDeployedXapps{&xapp}, models.EventTypeUndeployed, v.(SubscriptionInfo), 1)
+	err := h.send(&delivery{ID: "dl-1", SubscriptionID: resp.ID, Payload: "{}"}, nil)
 	assert.NotNil(t, err)
-	assert.Equal(t, 0, len(rh.subscriptions.Items()))
+	assert.Equal(t, 1, len(h.subscriptions.Items()))
 }
 
 func TestRestoreSubscriptionsSuccess(t *testing.T) {
@@ -257,214 +298,48 @@ func TestRestoreSubscriptionsFailsIfSdlGetFails(t *testing.T) {
 	assert.Equal(t, 0, len(restHook.subscriptions.Items()))
 }
 
-func TestTeardown(t *testing.T) {
-	var mockSdlRetOk error
-	mockedSdl.On("RemoveAll", appmgrSdlNs).Return(mockSdlRetOk).Once()
-
-	rh.FlushSubscriptions()
-}
-
-func TestUpdateAppDataFail1(t *testing.T) {
-	var reg models.RegisterRequest
-	var tEndpoint string = "10.104.237.59"
-	reg.HTTPEndpoint = &tEndpoint
-	rh.UpdateAppData(reg, false)
-}
-
-func TestUpdateAppDataFail2(t *testing.T) {
+func TestReloadSubscriptionsReplacesSubscriptions(t *testing.T) {
 	var mockSdlRetOk error
-	var params models.RegisterRequest
-
 	mSdl := new(SdlMock)
-	mockSdlRetNok := errors.New("some SDL error")
-	var tEndpoint1 string = "10.104.237.59:8087"
-	params.HTTPEndpoint = &tEndpoint1
-	serializedSubsReq2, err := json.Marshal(params)
-	if err != nil {
-		t.Logf("error in marshal .. %v", err)
-	}
-
-	subsReq := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
-	serializedSubsReq, err := json.Marshal(subsReq)
-	assert.Nil(t, err)
-
 	key := "key-1"
-	value := "endpoints"
-	mockSdlGetRetVal := make(map[string]interface{})
-	mockSdlGetRetVal[key] = serializedSubsReq
-
-	mockSdlGetRetVal2 := make(map[string]interface{})
-	mockSdlGetRetVal2[value] = serializedSubsReq2
-	mSdl.On("GetAll", appmgrSdlNs).Return([]string{key}, mockSdlRetOk).Twice()
-	mSdl.On("Get", appmgrSdlNs, []string{key}).Return(mockSdlGetRetVal, mockSdlRetNok).Once()
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal2, mockSdlRetOk).Once()
-
-	restHook := createResthook(false, mSdl)
-
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal2, mockSdlRetOk).Once()
-
-	ret := restHook.GetAppsInSDL()
-	if ret == nil {
-		assert.Nil(t, ret)
-	}
-}
-
-func TestGetAppsInSDLFail3(t *testing.T) {
-	var mockSdlRetOk error
-	var params models.RegisterRequest
-
-	mSdl := new(SdlMock)
-	mockSdlRetNok := errors.New("some SDL error")
-
-	serializedSubsReq1, err := json.Marshal(params)
-	if err != nil {
-		t.Logf("error in marshal .. %v", err)
-	}
 
 	subsReq := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
 	serializedSubsReq, err := json.Marshal(subsReq)
 	assert.Nil(t, err)
 
-	key := "key-1"
-	value := "endpoints"
 	mockSdlGetRetVal := make(map[string]interface{})
-	mockSdlGetRetVal[key] = serializedSubsReq
-
-	mockSdlGetRetVal1 := make(map[string]interface{})
-	mockSdlGetRetVal1[key] = serializedSubsReq1
-
-	mSdl.On("GetAll", appmgrSdlNs).Return([]string{key}, mockSdlRetOk).Twice()
-	mSdl.On("Get", appmgrSdlNs, []string{key}).Return(mockSdlGetRetVal, mockSdlRetNok).Once()
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal1, mockSdlRetOk).Once()
-
+	mockSdlGetRetVal[key] = string(serializedSubsReq)
+	mSdl.On("GetAll", appmgrSdlNs).Return([]string{key}, mockSdlRetOk).Once()
+	mSdl.On("Get", appmgrSdlNs, []string{key}).Return(mockSdlGetRetVal, mockSdlRetOk).Once()
 	restHook := createResthook(false, mSdl)
+	restHook.subscriptions.Set("deleted-by-leader", SubscriptionInfo{Id: "deleted-by-leader"})
 
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal1, mockSdlRetOk).Once()
-	ret2 := restHook.GetAppsInSDL()
-	if ret2 != nil {
-		t.Logf("SDL Returning: %s \n", *ret2)
-	} else {
-		assert.Nil(t, ret2)
-	}
+	restHook.ReloadSubscriptions()
+	assert.Equal(t, []string{key}, restHook.subscriptions.Keys())
+	val, _ := restHook.subscriptions.Get(key)
+	assert.Equal(t, subsReq, val.(SubscriptionInfo).req)
+	mSdl.AssertExpectations(t)
 }
 
-func TestUpdateAppDataSucc(t *testing.T) {
-	var mockSdlRetOk error
-	var params models.RegisterRequest
-
+func TestReloadSubscriptionsKeepsSubscriptionsIfSdlFails(t *testing.T) {
 	mSdl := new(SdlMock)
-	mockSdlRetNok := errors.New("some SDL error")
-
-	var tEndpoint1 string = "10.104.237.59:8087"
-	params.HTTPEndpoint = &tEndpoint1
-	serializedSubsReq1, err := json.Marshal(params)
-	if err != nil {
-		t.Logf("error in marshal .. %v", err)
-	}
-	subsReq := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
-	serializedSubsReq, err := json.Marshal(subsReq)
-	assert.Nil(t, err)
-
-	key := "key-1"
-	value := "endpoints"
-	mockSdlGetRetVal := make(map[string]interface{})
-	mockSdlGetRetVal[key] = serializedSubsReq
-
-	mockSdlGetRetVal1 := make(map[string]interface{})
-	mockSdlGetRetVal1[key] = serializedSubsReq1
-
-	mSdl.On("GetAll", appmgrSdlNs).Return([]string{key}, mockSdlRetOk).Twice()
-	mSdl.On("Get", appmgrSdlNs, []string{key}).Return(mockSdlGetRetVal, mockSdlRetNok).Once()
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal1, mockSdlRetOk).Once()
-
+	mSdl.On("GetAll", appmgrSdlNs).Return([]string{}, errors.New("some SDL error")).Once()
 	restHook := createResthook(false, mSdl)
+	restHook.subscriptions.Set("key-1", SubscriptionInfo{Id: "key-1"})
 
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal1, mockSdlRetOk).Once()
-	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
-	restHook.UpdateAppData(params, true)
+	restHook.ReloadSubscriptions()
+	assert.True(t, restHook.subscriptions.Has("key-1"))
 }
 
-func TestUpdateAppDataSucc1(t *testing.T) {
+func TestTeardown(t *testing.T) {
 	var mockSdlRetOk error
-	var params models.RegisterRequest
-
-	mSdl := new(SdlMock)
-	mockSdlRetNok := errors.New("some SDL error")
-
-	var tEndpoint1 string = "10.104.237.59:8087"
-	params.HTTPEndpoint = &tEndpoint1
-	appsindb := []string{"10.104.237.59:8088 ", " ", " ", " 10.104.237.59:8087"}
-	serializedSubsReq1, err := json.Marshal(appsindb)
-	if err != nil {
-		t.Logf("error in marshal .. %v", err)
-	}
-	subsReq := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
-	serializedSubsReq, err := json.Marshal(subsReq)
-	assert.Nil(t, err)
-
-	key := "key-1"
-	value := "endpoints"
-	mockSdlGetRetVal := make(map[string]interface{})
-	mockSdlGetRetVal[key] = serializedSubsReq
-
-	mockSdlGetRetVal1 := make(map[string]interface{})
-	mockSdlGetRetVal1[value] = serializedSubsReq1
-
-	mSdl.On("GetAll", appmgrSdlNs).Return([]string{key}, mockSdlRetOk).Twice()
-	mSdl.On("Get", appmgrSdlNs, []string{key}).Return(mockSdlGetRetVal, mockSdlRetNok).Once()
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal1, mockSdlRetOk).Once()
-
-	restHook := createResthook(false, mSdl)
-
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal1, mockSdlRetOk).Once()
-	mSdl.On("Set", appDbSdlNs, []string{value}).Return(mockSdlRetOk).Twice()
+	mockedSdl.On("RemoveAll", appmgrSdlNs).Return(mockSdlRetOk).Once()
 
-	mSdl.On("Remove", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
-	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
-	restHook.UpdateAppData(params, true)
+	rh.FlushSubscriptions()
 }
 
-func TestUpdateAppDataSucc2(t *testing.T) {
-	var mockSdlRetOk error
-	var params models.RegisterRequest
-
-	mSdl := new(SdlMock)
-	mockSdlRetNok := errors.New("some SDL error")
-
-	var tEndpoint1 string = "10.104.237.59:8087"
-	params.Config = "/temp/config.yaml"
-	params.HTTPEndpoint = &tEndpoint1
-	serializedSubsReq1, err := json.Marshal(tEndpoint1)
-	if err != nil {
-		t.Logf("error in marshal .. %v", err)
-	}
-	subsReq := createSubscription(models.EventTypeCreated, int64(5), int64(10), "http://localhost:8087/xapps_hook")
-	serializedSubsReq, err := json.Marshal(subsReq)
-	assert.Nil(t, err)
-
-	key := "key-1"
-	value := "endpoints"
-	mockSdlGetRetVal := make(map[string]interface{})
-	mockSdlGetRetVal[key] = serializedSubsReq
-
-	mockSdlGetRetVal1 := make(map[string]interface{})
-	mockSdlGetRetVal1[value] = serializedSubsReq1
-
-	mSdl.On("GetAll", appmgrSdlNs).Return([]string{key}, mockSdlRetOk).Twice()
-	mSdl.On("Get", appmgrSdlNs, []string{key}).Return(mockSdlGetRetVal, mockSdlRetNok).Once()
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal1, mockSdlRetOk).Once()
-
-	restHook := createResthook(false, mSdl)
-
-	mSdl.On("Get", appDbSdlNs, []string{value}).Return(mockSdlGetRetVal1, mockSdlRetOk).Once()
-	mSdl.On("Set", appDbSdlNs, []string{value}).Return(mockSdlRetOk).Twice()
-
-	mSdl.On("Remove", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
-	mSdl.On("Set", appDbSdlNs, mock.Anything).Return(mockSdlRetOk)
-	restHook.UpdateAppData(params, true)
-}
 func createSubscription(et models.EventType, maxRetries, retryTimer int64, targetUrl string) models.SubscriptionRequest {
-	return models.SubscriptionRequest{&models.SubscriptionData{et, &maxRetries, &retryTimer, &targetUrl}}
+	return models.SubscriptionRequest{Data: &models.SubscriptionData{EventType: et, MaxRetries: &maxRetries, RetryTimer: &retryTimer, TargetURL: &targetUrl}}
 }
 
 func getDummyXapp() models.Xapp {
@@ -530,7 +405,7 @@ func (m *SdlMock) expectDbSet(t *testing.T, subsReq models.SubscriptionRequest,
 
 func TestPublishSubscription(t *testing.T) {
 	sub := createSubscription(models.EventTypeCreated, int64(2), int64(1), "http://localhost:8087/xapps_hook")
-	resp := rh.AddSubscription(sub)
+	resp, _ := rh.AddSubscription(sub)
 
 	xapp := getDummyXapp()
 
@@ -567,6 +442,6 @@ func (m *SdlMock) RemoveAll(ns string) error {
 }
 
 func (m *SdlMock) Remove(ns string, keys []string) error {
-	a := m.Called(ns)
+	a := m.Called(ns, keys)
 	return a.Error(0)
 }
@@ -22,6 +22,8 @@ package resthooks
 import (
 	cmap "github.com/orcaman/concurrent-map"
 	"net/http"
+	"sync"
+	"time"
 
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
 )
@@ -37,6 +39,41 @@ type Resthook struct {
 	subscriptions cmap.ConcurrentMap
 	db            iSdl
 	Seq           int64
+	seqMutex      sync.Mutex
+	queues        map[string]*subscriberQueue
+	queuesMutex   sync.Mutex
+	queueSize     int
+	maxBackoff    time.Duration
+	draining      bool
+	// Limits of the dead-letter store, see PruneDeadLetters
+	maxDeadLetters   int
+	maxDeadLetterAge time.Duration
+	now              func() time.Time
+}
+
+// A single notification on its way to one subscriber. Exhausted deliveries are
+// stored as such in the dead-letter namespace.
+type delivery struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscriptionId"`
+	TargetURL      string    `json:"targetUrl"`
+	Event          string    `json:"eventType"`
+	Payload        string    `json:"payload"`
+	Attempts       int64     `json:"attempts"`
+	LastError      string    `json:"lastError,omitempty"`
+	FailedAt       time.Time `json:"failedAt"`
+	// W3C trace context of the event, so that retries and redrives stay in its trace
+	Trace map[string]string `json:"trace,omitempty"`
+}
+
+// Pending deliveries of one subscriber, sent in order by a single worker. Closing
+// stop ends the worker at once, closing drain once the queue is empty. The worker
+// closes done when it ends.
+type subscriberQueue struct {
+	deliveries chan *delivery
+	stop       chan struct{}
+	drain      chan struct{}
+	done       chan struct{}
 }
 
 // TODO: remove this when RTMGR changes done
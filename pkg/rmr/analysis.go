@@ -0,0 +1,159 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package rmr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+// Analyzer looks for messages that cannot flow between the xApps
+type Analyzer struct {
+	// PolicyMessages are routed by A1 policy, so their consumers may be told apart by policy
+	PolicyMessages []string
+	// External messages are exchanged with platform components, so nobody sending
+	// or receiving them among the xApps is not an issue
+	External []string
+}
+
+// flow collects the xApps sending and receiving a message
+type flow struct {
+	producers []string
+	consumers []string
+	// policies of each consumer, an xApp with an instance without policies has none
+	policies map[string][]int64
+}
+
+// AppID identifies an xApp in the analysis results
+func AppID(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// Analyze reports the rx messages that no xApp sends, the tx messages that no
+// xApp receives, and the messages received by several xApps that are not told
+// apart by distinct policies
+func (a *Analyzer) Analyze(xapps models.AllDeployedXapps) models.FlowAnalysis {
+	flows := make(map[string]*flow)
+	flowOf := func(name string) *flow {
+		if flows[name] == nil {
+			flows[name] = &flow{policies: make(map[string][]int64)}
+		}
+		return flows[name]
+	}
+
+	for _, x := range xapps {
+		if x == nil || x.Name == nil {
+			continue
+		}
+		app := AppID(x.Namespace, *x.Name)
+		noPolicy := make(map[string]bool)
+		for _, i := range x.Instances {
+			if i == nil {
+				continue
+			}
+			for _, name := range i.TxMessages {
+				if f := flowOf(name); !containsString(f.producers, app) {
+					f.producers = append(f.producers, app)
+				}
+			}
+			for _, name := range i.RxMessages {
+				f := flowOf(name)
+				if !containsString(f.consumers, app) {
+					f.consumers = append(f.consumers, app)
+				}
+				if len(i.Policies) == 0 || !containsString(a.PolicyMessages, name) {
+					noPolicy[name] = true
+				}
+				f.policies[app] = append(f.policies[app], i.Policies...)
+			}
+		}
+		for name := range noPolicy {
+			flows[name].policies[app] = nil
+		}
+	}
+
+	issues := []*models.FlowIssue{}
+	for name, f := range flows {
+		external := containsString(a.External, name)
+		if len(f.producers) == 0 && !external {
+			issues = append(issues, newIssue(models.FlowIssueKindOrphanRx, name, f.consumers,
+				"%s is received by %s, but no xApp sends it"))
+		}
+		if len(f.consumers) == 0 && !external {
+			issues = append(issues, newIssue(models.FlowIssueKindUnconsumedTx, name, f.producers,
+				"%s is sent by %s, but no xApp receives it"))
+		}
+		if len(f.consumers) > 1 && !distinctPolicies(f.policies) {
+			issues = append(issues, newIssue(models.FlowIssueKindMultipleConsumers, name, f.consumers,
+				"%s is received by %s without a distinguishing policy"))
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if *issues[i].Message != *issues[j].Message {
+			return *issues[i].Message < *issues[j].Message
+		}
+		return *issues[i].Kind < *issues[j].Kind
+	})
+	return models.FlowAnalysis{Issues: issues}
+}
+
+// Involving returns the issues that concern the given xApp
+func Involving(issues []*models.FlowIssue, app string) []*models.FlowIssue {
+	var result []*models.FlowIssue
+	for _, issue := range issues {
+		if containsString(issue.Xapps, app) {
+			result = append(result, issue)
+		}
+	}
+	return result
+}
+
+func newIssue(kind, message string, xapps []string, format string) *models.FlowIssue {
+	sorted := append([]string{}, xapps...)
+	sort.Strings(sorted)
+	k, m := kind, message
+	return &models.FlowIssue{
+		Kind:        &k,
+		Message:     &m,
+		Xapps:       sorted,
+		Description: fmt.Sprintf(format, message, strings.Join(sorted, ", ")),
+	}
+}
+
+// distinctPolicies returns true if every xApp has policies, and no policy is shared
+func distinctPolicies(policies map[string][]int64) bool {
+	owner := make(map[int64]string)
+	for app, list := range policies {
+		if len(list) == 0 {
+			return false
+		}
+		for _, p := range list {
+			if other, found := owner[p]; found && other != app {
+				return false
+			}
+			owner[p] = app
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package rmr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+func TestAnalyzeReportsOrphanAndUnconsumedMessages(t *testing.T) {
+	a := &Analyzer{External: []string{"RIC_INDICATION"}}
+	xapps := models.AllDeployedXapps{
+		newTestXapp("ts", "ts-1", []string{"TS_QOE_PRED_REQ"}, []string{"TS_QOE_PREDICTION", "RIC_INDICATION"}),
+		newTestXapp("qp", "qp-1", []string{"TS_UE_LIST"}, []string{"TS_QOE_PRED_REQ"}),
+	}
+
+	issues := a.Analyze(xapps).Issues
+	assert.Equal(t, 2, len(issues))
+	assert.Equal(t, models.FlowIssueKindOrphanRx, *issues[0].Kind)
+	assert.Equal(t, "TS_QOE_PREDICTION", *issues[0].Message)
+	assert.Equal(t, []string{"ricxapp/ts"}, issues[0].Xapps)
+	assert.Equal(t, "TS_QOE_PREDICTION is received by ricxapp/ts, but no xApp sends it", issues[0].Description)
+	assert.Equal(t, models.FlowIssueKindUnconsumedTx, *issues[1].Kind)
+	assert.Equal(t, "TS_UE_LIST", *issues[1].Message)
+}
+
+func TestAnalyzeReportsConsumersWithoutDistinctPolicies(t *testing.T) {
+	a := &Analyzer{PolicyMessages: DefaultPolicyMessages, External: []string{"A1_POLICY_REQ", "RIC_INDICATION"}}
+	qp := newTestXapp("qp", "qp-1", nil, []string{"A1_POLICY_REQ"})
+	qp.Instances[0].Policies = []int64{20008}
+	ts := newTestXapp("ts", "ts-1", nil, []string{"A1_POLICY_REQ"})
+	ts.Instances[0].Policies = []int64{20009}
+
+	assert.Equal(t, 0, len(a.Analyze(models.AllDeployedXapps{qp, ts}).Issues))
+
+	ts.Instances[0].Policies = []int64{20008}
+	issues := a.Analyze(models.AllDeployedXapps{qp, ts}).Issues
+	assert.Equal(t, 1, len(issues))
+	assert.Equal(t, models.FlowIssueKindMultipleConsumers, *issues[0].Kind)
+	assert.Equal(t, []string{"ricxapp/qp", "ricxapp/ts"}, issues[0].Xapps)
+
+	// Policies don't tell apart the consumers of other messages
+	kpimon := newTestXapp("kpimon", "kpimon-1", nil, []string{"RIC_INDICATION"})
+	ueec := newTestXapp("ueec", "ueec-1", nil, []string{"RIC_INDICATION"})
+	ueec.Instances[0].Policies = []int64{20010}
+	issues = a.Analyze(models.AllDeployedXapps{kpimon, ueec}).Issues
+	assert.Equal(t, 1, len(issues))
+	assert.Equal(t, "RIC_INDICATION", *issues[0].Message)
+}
+
+func TestInvolving(t *testing.T) {
+	a := &Analyzer{}
+	xapps := models.AllDeployedXapps{
+		newTestXapp("ts", "ts-1", []string{"TS_QOE_PRED_REQ"}, nil),
+		newTestXapp("qp", "qp-1", []string{"TS_UE_LIST"}, nil),
+	}
+
+	issues := Involving(a.Analyze(xapps).Issues, AppID("ricxapp", "qp"))
+	assert.Equal(t, 1, len(issues))
+	assert.Equal(t, "TS_UE_LIST", *issues[0].Message)
+}
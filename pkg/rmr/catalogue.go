@@ -0,0 +1,169 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package rmr
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/viper"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+var ErrUnknownMessages = errors.New("unknown RMR message types")
+
+// Catalogue maps the names of the RMR message types to their numeric IDs
+type Catalogue struct {
+	ids      map[string]int64
+	messages models.AllMessageTypes
+}
+
+type catalogueFile struct {
+	Messages map[string]int64 `json:"messages"`
+}
+
+// NewCatalogue returns a catalogue of the given messages. Each message must have an ID of its own.
+func NewCatalogue(messages map[string]int64) (*Catalogue, error) {
+	c := &Catalogue{ids: make(map[string]int64), messages: models.AllMessageTypes{}}
+
+	names := make(map[int64]string)
+	for name, id := range messages {
+		if other, found := names[id]; found {
+			return nil, fmt.Errorf("messages '%s' and '%s' have the same ID %d", name, other, id)
+		}
+		names[id] = name
+		c.ids[name] = id
+
+		n, i := name, id
+		c.messages = append(c.messages, &models.MessageType{Name: &n, ID: &i})
+	}
+
+	sort.Slice(c.messages, func(a, b int) bool { return *c.messages[a].ID < *c.messages[b].ID })
+	return c, nil
+}
+
+// LoadCatalogue reads the 'messages' section of a YAML file like config/msg_type.yaml
+func LoadCatalogue(file string) (*Catalogue, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var f catalogueFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return NewCatalogue(f.Messages)
+}
+
+// LoadConfiguredCatalogue loads the catalogue named by 'messages.catalogue'. A
+// relative path is relative to the appmgr configuration file.
+func LoadConfiguredCatalogue() (*Catalogue, error) {
+	file := viper.GetString("messages.catalogue")
+	if file == "" {
+		return nil, errors.New("no message catalogue configured")
+	}
+	if !filepath.IsAbs(file) && viper.ConfigFileUsed() != "" {
+		file = filepath.Join(filepath.Dir(viper.ConfigFileUsed()), file)
+	}
+
+	c, err := LoadCatalogue(file)
+	if err != nil {
+		return nil, err
+	}
+	appmgr.Logger.Info("Loaded %d RMR message types from %s", len(c.messages), file)
+	return c, nil
+}
+
+// ID returns the numeric ID of the named message type
+func (c *Catalogue) ID(name string) (int64, bool) {
+	id, found := c.ids[name]
+	return id, found
+}
+
+// Messages returns all message types, ordered by ID
+func (c *Catalogue) Messages() models.AllMessageTypes {
+	return c.messages
+}
+
+// Validate checks that all tx and rx messages are in the catalogue. The error
+// wraps ErrUnknownMessages and names the unknown messages.
+func (c *Catalogue) Validate(rtData appmgr.RtmData) error {
+	var unknown []string
+	for _, list := range [][]string{rtData.TxMessages, rtData.RxMessages} {
+		for _, name := range list {
+			if _, found := c.ids[name]; !found && !containsString(unknown, name) {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+
+	if len(unknown) != 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownMessages, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Producers returns the instances that send the named message
+func Producers(xapps models.AllDeployedXapps, name string) models.MessageEndpoints {
+	return endpoints(xapps, func(i *models.XappInstance) []string { return i.TxMessages }, name)
+}
+
+// Consumers returns the instances that receive the named message
+func Consumers(xapps models.AllDeployedXapps, name string) models.MessageEndpoints {
+	return endpoints(xapps, func(i *models.XappInstance) []string { return i.RxMessages }, name)
+}
+
+func endpoints(xapps models.AllDeployedXapps, messages func(*models.XappInstance) []string, name string) models.MessageEndpoints {
+	result := models.MessageEndpoints{}
+	for _, x := range xapps {
+		if x == nil || x.Name == nil {
+			continue
+		}
+		for _, i := range x.Instances {
+			if i == nil || i.Name == nil || !containsString(messages(i), name) {
+				continue
+			}
+			result = append(result, &models.MessageEndpoint{
+				XappName:     *x.Name,
+				Namespace:    x.Namespace,
+				InstanceName: *i.Name,
+				Endpoint:     fmt.Sprintf("%s:%d", i.IP, i.Port),
+			})
+		}
+	}
+	return result
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
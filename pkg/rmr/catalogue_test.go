@@ -0,0 +1,106 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package rmr
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+func TestMain(m *testing.M) {
+	appmgr.Init()
+	appmgr.Logger.SetLevel(0)
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestNewCatalogueRejectsDuplicateIDs(t *testing.T) {
+	_, err := NewCatalogue(map[string]int64{"RIC_SUB_REQ": 12010, "MY_SUB_REQ": 12010})
+	assert.NotNil(t, err)
+}
+
+func TestLoadCatalogue(t *testing.T) {
+	c, err := LoadCatalogue("../../config/msg_type.yaml")
+	assert.Nil(t, err)
+
+	id, found := c.ID("RIC_SUB_REQ")
+	assert.True(t, found)
+	assert.Equal(t, int64(12010), id)
+
+	_, found = c.ID("NO_SUCH_MSG")
+	assert.False(t, found)
+
+	all := c.Messages()
+	for n := 1; n < len(all); n++ {
+		assert.True(t, *all[n-1].ID < *all[n].ID)
+	}
+}
+
+func TestLoadConfiguredCatalogueIsRelativeToConfigFile(t *testing.T) {
+	c, err := LoadConfiguredCatalogue()
+	assert.Nil(t, err)
+
+	_, found := c.ID("A1_POLICY_REQ")
+	assert.True(t, found)
+}
+
+func TestValidateNamesUnknownMessages(t *testing.T) {
+	c, _ := NewCatalogue(map[string]int64{"RIC_SUB_REQ": 12010, "RIC_SUB_RESP": 12011})
+
+	assert.Nil(t, c.Validate(appmgr.RtmData{TxMessages: []string{"RIC_SUB_REQ"}, RxMessages: []string{"RIC_SUB_RESP"}}))
+
+	err := c.Validate(appmgr.RtmData{TxMessages: []string{"RIC_SUB_REQ", "MY_MSG"}, RxMessages: []string{"MY_MSG", "OTHER_MSG"}})
+	assert.True(t, errors.Is(err, ErrUnknownMessages))
+	assert.Equal(t, "unknown RMR message types: MY_MSG, OTHER_MSG", err.Error())
+}
+
+func TestProducersAndConsumers(t *testing.T) {
+	xapps := models.AllDeployedXapps{
+		newTestXapp("ueec", "ueec-1", []string{"RIC_SUB_REQ"}, []string{"RIC_SUB_RESP"}),
+		newTestXapp("submgr", "submgr-1", []string{"RIC_SUB_RESP"}, []string{"RIC_SUB_REQ"}),
+	}
+
+	producers := Producers(xapps, "RIC_SUB_REQ")
+	assert.Equal(t, 1, len(producers))
+	assert.Equal(t, &models.MessageEndpoint{XappName: "ueec", Namespace: "ricxapp", InstanceName: "ueec-1", Endpoint: "service-ueec:4560"}, producers[0])
+
+	consumers := Consumers(xapps, "RIC_SUB_REQ")
+	assert.Equal(t, 1, len(consumers))
+	assert.Equal(t, "submgr-1", consumers[0].InstanceName)
+
+	assert.Equal(t, models.MessageEndpoints{}, Consumers(xapps, "RIC_INDICATION"))
+}
+
+func newTestXapp(name, instance string, tx, rx []string) *models.Xapp {
+	return &models.Xapp{
+		Name:      &name,
+		Namespace: "ricxapp",
+		Instances: []*models.XappInstance{
+			{Name: &instance, IP: "service-" + name, Port: 4560, TxMessages: tx, RxMessages: rx},
+		},
+	}
+}
@@ -0,0 +1,187 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package rmr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+// DefaultSubscriptionID is the subscription ID of the routes that apply to any subscription
+const DefaultSubscriptionID = -1
+
+// DefaultPolicyMessages are the messages routed by A1 policy type when no list is configured
+var DefaultPolicyMessages = []string{"A1_POLICY_REQ"}
+
+// Router keeps the route table computed from the latest set of xApps
+type Router struct {
+	mutex          sync.RWMutex
+	catalogue      *Catalogue
+	policyMessages []string
+	table          models.RouteTable
+}
+
+// NewRouter returns a Router with an empty table. The consumers of policyMessages
+// that list A1 policies get a route per policy, with the policy type as the
+// subscription ID, instead of the default route.
+func NewRouter(c *Catalogue, policyMessages []string) *Router {
+	return &Router{
+		catalogue:      c,
+		policyMessages: policyMessages,
+		table:          models.RouteTable{Routes: []*models.RouteEntry{}},
+	}
+}
+
+// Update recomputes the route table from the given xApps
+func (r *Router) Update(xapps models.AllDeployedXapps) {
+	routes := BuildRoutes(r.catalogue, xapps, r.policyMessages)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.table = models.RouteTable{Generated: strfmt.DateTime(time.Now().UTC()), Routes: routes}
+	appmgr.Logger.Info("Route table updated, %d routes", len(routes))
+}
+
+// Table returns the latest route table
+func (r *Router) Table() models.RouteTable {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.table
+}
+
+// Text returns the latest route table in the RMR static route table format
+func (r *Router) Text() string {
+	return FormatRoutes(r.Table().Routes)
+}
+
+// BuildRoutes routes every message received by some instance to its consumers.
+// The instances of one xApp share the messages round robin, while each xApp
+// receives its own copy. Messages missing from the catalogue have no numeric
+// type, so they are left out.
+func BuildRoutes(c *Catalogue, xapps models.AllDeployedXapps, policyMessages []string) []*models.RouteEntry {
+	type routeKey struct {
+		name  string
+		subID int64
+	}
+	consumers := make(map[routeKey]map[string][]string)
+	producers := make(map[string][]string)
+
+	addConsumer := func(key routeKey, app, endpoint string) {
+		if consumers[key] == nil {
+			consumers[key] = make(map[string][]string)
+		}
+		if !containsString(consumers[key][app], endpoint) {
+			consumers[key][app] = append(consumers[key][app], endpoint)
+		}
+	}
+
+	for _, x := range xapps {
+		if x == nil || x.Name == nil {
+			continue
+		}
+		app := x.Namespace + "/" + *x.Name
+		for _, i := range x.Instances {
+			if i == nil {
+				continue
+			}
+			endpoint := fmt.Sprintf("%s:%d", i.IP, i.Port)
+			for _, name := range i.TxMessages {
+				if !containsString(producers[name], endpoint) {
+					producers[name] = append(producers[name], endpoint)
+				}
+			}
+			for _, name := range i.RxMessages {
+				if !containsString(policyMessages, name) || len(i.Policies) == 0 {
+					addConsumer(routeKey{name, DefaultSubscriptionID}, app, endpoint)
+					continue
+				}
+				for _, policy := range i.Policies {
+					addConsumer(routeKey{name, policy}, app, endpoint)
+				}
+			}
+		}
+	}
+
+	routes := []*models.RouteEntry{}
+	for key, apps := range consumers {
+		id, found := int64(0), false
+		if c != nil {
+			id, found = c.ID(key.name)
+		}
+		if !found {
+			appmgr.Logger.Info("No route for message '%s', it is not in the message catalogue", key.name)
+			continue
+		}
+
+		var groups [][]string
+		for _, endpoints := range apps {
+			sort.Strings(endpoints)
+			groups = append(groups, endpoints)
+		}
+		sort.Slice(groups, func(a, b int) bool { return groups[a][0] < groups[b][0] })
+
+		sources := append([]string{}, producers[key.name]...)
+		sort.Strings(sources)
+
+		name, msgType, subID := key.name, id, key.subID
+		routes = append(routes, &models.RouteEntry{
+			MessageName:    &name,
+			MessageType:    &msgType,
+			SubscriptionID: &subID,
+			EndpointGroups: groups,
+			Producers:      sources,
+		})
+	}
+
+	sort.Slice(routes, func(a, b int) bool {
+		if *routes[a].MessageType != *routes[b].MessageType {
+			return *routes[a].MessageType < *routes[b].MessageType
+		}
+		return *routes[a].SubscriptionID < *routes[b].SubscriptionID
+	})
+	return routes
+}
+
+// FormatRoutes writes the routes as an RMR static route table, e.g.
+//
+//	newrt|start
+//	mse|12010|-1|service-ricplt-submgr-rmr.ricplt:4560
+//	newrt|end
+func FormatRoutes(routes []*models.RouteEntry) string {
+	var b strings.Builder
+	b.WriteString("newrt|start\n")
+	for _, route := range routes {
+		var groups []string
+		for _, g := range route.EndpointGroups {
+			groups = append(groups, strings.Join(g, ","))
+		}
+		fmt.Fprintf(&b, "mse|%d|%d|%s\n", *route.MessageType, *route.SubscriptionID, strings.Join(groups, ";"))
+	}
+	b.WriteString("newrt|end\n")
+	return b.String()
+}
@@ -0,0 +1,78 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package rmr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/models"
+)
+
+func TestBuildRoutesGroupsInstancesByXapp(t *testing.T) {
+	c, _ := NewCatalogue(map[string]int64{"RIC_SUB_REQ": 12010, "RIC_INDICATION": 12050})
+	ueec := newTestXapp("ueec", "ueec-1", []string{"RIC_SUB_REQ"}, []string{"RIC_INDICATION"})
+	second := "ueec-2"
+	ueec.Instances = append(ueec.Instances, &models.XappInstance{Name: &second, IP: "service-ueec-2", Port: 4560, RxMessages: []string{"RIC_INDICATION"}})
+	xapps := models.AllDeployedXapps{
+		ueec,
+		newTestXapp("kpimon", "kpimon-1", nil, []string{"RIC_INDICATION", "NO_SUCH_MSG"}),
+		newTestXapp("submgr", "submgr-1", []string{"RIC_INDICATION"}, []string{"RIC_SUB_REQ"}),
+	}
+
+	routes := BuildRoutes(c, xapps, DefaultPolicyMessages)
+	assert.Equal(t, 2, len(routes))
+	assert.Equal(t, int64(12010), *routes[0].MessageType)
+	assert.Equal(t, [][]string{{"service-submgr:4560"}}, routes[0].EndpointGroups)
+	assert.Equal(t, []string{"service-ueec:4560"}, routes[0].Producers)
+	assert.Equal(t, int64(DefaultSubscriptionID), *routes[1].SubscriptionID)
+	assert.Equal(t, [][]string{{"service-kpimon:4560"}, {"service-ueec-2:4560", "service-ueec:4560"}}, routes[1].EndpointGroups)
+
+	assert.Equal(t, "newrt|start\n"+
+		"mse|12010|-1|service-submgr:4560\n"+
+		"mse|12050|-1|service-kpimon:4560;service-ueec-2:4560,service-ueec:4560\n"+
+		"newrt|end\n", FormatRoutes(routes))
+}
+
+func TestBuildRoutesUsesPoliciesAsSubscriptionIDs(t *testing.T) {
+	c, _ := NewCatalogue(map[string]int64{"A1_POLICY_REQ": 20010})
+	qp := newTestXapp("qp", "qp-1", nil, []string{"A1_POLICY_REQ"})
+	qp.Instances[0].Policies = []int64{20008, 20009}
+	xapps := models.AllDeployedXapps{qp, newTestXapp("ts", "ts-1", nil, []string{"A1_POLICY_REQ"})}
+
+	assert.Equal(t, "newrt|start\n"+
+		"mse|20010|-1|service-ts:4560\n"+
+		"mse|20010|20008|service-qp:4560\n"+
+		"mse|20010|20009|service-qp:4560\n"+
+		"newrt|end\n", FormatRoutes(BuildRoutes(c, xapps, DefaultPolicyMessages)))
+}
+
+func TestRouterUpdate(t *testing.T) {
+	c, _ := NewCatalogue(map[string]int64{"RIC_SUB_REQ": 12010})
+	r := NewRouter(c, DefaultPolicyMessages)
+	assert.Equal(t, "newrt|start\nnewrt|end\n", r.Text())
+
+	r.Update(models.AllDeployedXapps{newTestXapp("submgr", "submgr-1", nil, []string{"RIC_SUB_REQ"})})
+	assert.Equal(t, 1, len(r.Table().Routes))
+
+	r.Update(models.AllDeployedXapps{})
+	assert.Equal(t, 0, len(r.Table().Routes))
+}
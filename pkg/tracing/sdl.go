@@ -0,0 +1,91 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/metrics"
+)
+
+// InstrumentSdl returns a Storage that runs every operation on db in a span. The
+// SDL API takes no context, so the operations are traced only through a storage
+// bound to a traced context with metrics.WithContext.
+func InstrumentSdl(db metrics.Storage) metrics.Storage {
+	return &tracedStorage{db: db, ctx: context.Background()}
+}
+
+type tracedStorage struct {
+	db  metrics.Storage
+	ctx context.Context
+}
+
+// WithContext returns the storage for the operations done within ctx
+func (s *tracedStorage) WithContext(ctx context.Context) metrics.Storage {
+	return &tracedStorage{db: metrics.WithContext(ctx, s.db), ctx: ctx}
+}
+
+// startSdl starts the span of an SDL operation as a child of the span in ctx. An
+// operation outside of a trace gets no span, rather than a trace of its own.
+func startSdl(ctx context.Context, operation, ns string) trace.Span {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return trace.SpanFromContext(ctx)
+	}
+	_, span := Start(ctx, "sdl."+operation, attribute.String("db.system", "sdl"), attribute.String("db.namespace", ns))
+	return span
+}
+
+func (s *tracedStorage) Set(ns string, pairs ...interface{}) (err error) {
+	span := startSdl(s.ctx, "set", ns)
+	defer func() { End(span, err) }()
+	return s.db.Set(ns, pairs...)
+}
+
+func (s *tracedStorage) Get(ns string, keys []string) (values map[string]interface{}, err error) {
+	span := startSdl(s.ctx, "get", ns)
+	defer func() { End(span, err) }()
+	return s.db.Get(ns, keys)
+}
+
+func (s *tracedStorage) GetAll(ns string) (keys []string, err error) {
+	span := startSdl(s.ctx, "getall", ns)
+	defer func() { End(span, err) }()
+	return s.db.GetAll(ns)
+}
+
+func (s *tracedStorage) Remove(ns string, keys []string) (err error) {
+	span := startSdl(s.ctx, "remove", ns)
+	defer func() { End(span, err) }()
+	return s.db.Remove(ns, keys)
+}
+
+func (s *tracedStorage) RemoveAll(ns string) (err error) {
+	span := startSdl(s.ctx, "removeall", ns)
+	defer func() { End(span, err) }()
+	return s.db.RemoveAll(ns)
+}
+
+// Close closes the underlying SDL client, without a span
+func (s *tracedStorage) Close() error {
+	return metrics.CloseStorage(s.db)
+}
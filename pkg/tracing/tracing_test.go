@@ -0,0 +1,202 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/metrics"
+)
+
+const callerTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestMain(m *testing.M) {
+	appmgr.Init()
+	appmgr.Logger.SetLevel(0)
+
+	viper.Set("tracing.exporter", ExporterNone)
+	if _, err := Init(); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+// newRecorder installs a tracer provider that keeps the ended spans in memory
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func attributeOf(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, a := range span.Attributes() {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestInitRejectsUnknownExporter(t *testing.T) {
+	viper.Set("tracing.exporter", "zipkin")
+	defer viper.Set("tracing.exporter", ExporterNone)
+
+	_, err := Init()
+	assert.NotNil(t, err)
+}
+
+func TestHandlerContinuesTraceOfCaller(t *testing.T) {
+	recorder := newRecorder(t)
+
+	var handlerSpan trace.SpanContext
+	h := Handler(func(*http.Request) string { return "deployXapp" }, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(req.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/ric/v1/xapps", nil)
+	req.Header.Set("traceparent", callerTraceparent)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "deployXapp", spans[0].Name())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Equal(t, int64(500), attributeOf(spans[0], "http.response.status_code").AsInt64())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+
+	// The handlers see the server span
+	assert.Equal(t, spans[0].SpanContext().SpanID(), handlerSpan.SpanID())
+}
+
+func TestTransportPassesTraceContextOn(t *testing.T) {
+	recorder := newRecorder(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	ctx, span := Start(context.Background(), "resthooks.notify")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	span.End()
+
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	client := spans[0]
+	assert.Equal(t, "HTTP POST", client.Name())
+	assert.Equal(t, trace.SpanKindClient, client.SpanKind())
+	assert.Equal(t, span.SpanContext().SpanID(), client.Parent().SpanID())
+	assert.Equal(t, "00-"+client.SpanContext().TraceID().String()+"-"+client.SpanContext().SpanID().String()+"-01", traceparent)
+}
+
+func TestCarrierKeepsTraceContext(t *testing.T) {
+	newRecorder(t)
+
+	ctx, span := Start(context.Background(), "resthooks.notify")
+	defer span.End()
+
+	carrier := Carrier(ctx)
+	assert.Contains(t, carrier, "traceparent")
+	assert.Equal(t, span.SpanContext().TraceID(), trace.SpanContextFromContext(FromCarrier(carrier)).TraceID())
+
+	assert.False(t, trace.SpanContextFromContext(FromCarrier(nil)).IsValid())
+}
+
+func TestExecOnlyTracedWithinTrace(t *testing.T) {
+	recorder := newRecorder(t)
+	exec := func(args string) ([]byte, error) { return nil, errors.New("chart not found") }
+
+	_, err := Exec(context.Background(), "helm", "install --name dummy-xapp", exec)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(recorder.Ended()))
+
+	ctx, span := Start(context.Background(), "helm.install")
+	Exec(ctx, "helm", "install --name dummy-xapp --set password=secret", exec)
+	span.End()
+
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "helm install", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "chart not found", spans[0].Status().Description)
+}
+
+type failingStorage struct{}
+
+func (failingStorage) Set(ns string, pairs ...interface{}) error { return errors.New("db down") }
+func (failingStorage) Get(ns string, keys []string) (map[string]interface{}, error) {
+	return nil, nil
+}
+func (failingStorage) GetAll(ns string) ([]string, error)    { return nil, nil }
+func (failingStorage) Remove(ns string, keys []string) error { return nil }
+func (failingStorage) RemoveAll(ns string) error             { return nil }
+
+func TestInstrumentSdlRecordsOperations(t *testing.T) {
+	recorder := newRecorder(t)
+	ctx, parent := Start(context.Background(), "request")
+	db := metrics.WithContext(ctx, InstrumentSdl(failingStorage{}))
+
+	assert.NotNil(t, db.Set("appdb", "key", "value"))
+	db.GetAll("appdb")
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Equal(t, 3, len(spans))
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, "sdl.set", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "appdb", attributeOf(spans[0], "db.namespace").AsString())
+	assert.Equal(t, "sdl.getall", spans[1].Name())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestInstrumentSdlSkipsOperationsWithoutTrace(t *testing.T) {
+	recorder := newRecorder(t)
+	db := InstrumentSdl(failingStorage{})
+
+	assert.NotNil(t, db.Set("appdb", "key", "value"))
+	assert.Equal(t, 0, len(recorder.Ended()))
+}
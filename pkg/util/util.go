@@ -23,25 +23,38 @@ import (
 	"bytes"
 	"errors"
 	"github.com/spf13/viper"
-	"os"
 	"os/exec"
 	"strings"
 	"time"
 
 	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/appmgr"
+	"gerrit.o-ran-sc.org/r/ric-plt/appmgr/pkg/metrics"
 )
 
 var execCommand = exec.Command
 
 func Exec(args string) (out []byte, err error) {
 	cmd := execCommand("/bin/sh", "-c", args)
+	return run(cmd, strings.SplitN(strings.TrimSpace(args), " ", 2)[0], cmd.Args)
+}
+
+// ExecArgs runs command with args as they are, without a shell, so that e.g.
+// credentials are never interpreted. Only the command is logged.
+func ExecArgs(command string, args ...string) (out []byte, err error) {
+	return run(execCommand(command, args...), command, command)
+}
 
+func run(cmd *exec.Cmd, command string, logged interface{}) (out []byte, err error) {
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	appmgr.Logger.Info("Running command: %s ", cmd.Args)
+	defer func(start time.Time) {
+		metrics.CommandDuration.Observe(metrics.Since(start), command)
+	}(time.Now())
+
+	appmgr.Logger.Info("Running command: %s ", logged)
 	for i := 0; i < viper.GetInt("helm.retry"); i++ {
 		if err = cmd.Run(); err != nil {
 			appmgr.Logger.Error("Command failed: %v - %s, retrying", err.Error(), stderr.String())
@@ -51,11 +64,12 @@ func Exec(args string) (out []byte, err error) {
 		break
 	}
 
-	if err == nil && !strings.HasSuffix(os.Args[0], ".test") {
+	if err == nil {
 		appmgr.Logger.Info("command success: %s", stdout.String())
 		return stdout.Bytes(), nil
 	}
 
+	metrics.CommandFailures.Inc(command)
 	return stdout.Bytes(), errors.New(stderr.String())
 }
 
@@ -63,6 +77,11 @@ var HelmExec = func(args string) (out []byte, err error) {
 	return Exec(strings.Join([]string{"helm", args}, " "))
 }
 
+// HelmExecArgs runs helm with separate arguments, see ExecArgs
+var HelmExecArgs = func(args ...string) (out []byte, err error) {
+	return ExecArgs("helm", args...)
+}
+
 var KubectlExec = func(args string) (out []byte, err error) {
 	return Exec(strings.Join([]string{"kubectl", args}, " "))
 }
@@ -0,0 +1,129 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package webhook signs the notifications that xApp Manager posts to its
+// subscribers, and lets Go subscribers verify them:
+//
+//	body, err := webhook.VerifyRequest(secret, req, webhook.DefaultTolerance)
+//	if err != nil {
+//		w.WriteHeader(http.StatusUnauthorized)
+//		return
+//	}
+//
+// The signature is an HMAC-SHA256 over "<timestamp>.<body>" keyed with the
+// secret of the subscription, sent as "sha256=<hex>".
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderDeliveryID = "X-Appmgr-Delivery-Id"
+	HeaderTimestamp  = "X-Appmgr-Timestamp"
+	HeaderSignature  = "X-Appmgr-Signature"
+
+	// DefaultTolerance is the accepted clock difference between appmgr and a subscriber
+	DefaultTolerance = 5 * time.Minute
+
+	signaturePrefix = "sha256="
+	secretLength    = 32
+)
+
+var (
+	ErrMissingSignature = errors.New("webhook signature missing")
+	ErrInvalidSignature = errors.New("webhook signature invalid")
+	ErrExpired          = errors.New("webhook timestamp outside tolerance")
+)
+
+// GenerateSecret returns a random hex encoded secret
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value of body sent at timestamp (Unix seconds)
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetHeaders adds the delivery ID, the current timestamp and, if secret is set, the signature of body to h
+func SetHeaders(h http.Header, secret, deliveryID string, body []byte) {
+	timestamp := time.Now().Unix()
+	h.Set(HeaderDeliveryID, deliveryID)
+	h.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if secret != "" {
+		h.Set(HeaderSignature, Sign(secret, timestamp, body))
+	}
+}
+
+// Verify checks that body was signed with secret no more than tolerance ago
+func Verify(secret string, h http.Header, body []byte, tolerance time.Duration) error {
+	signature, ts := h.Get(HeaderSignature), h.Get(HeaderTimestamp)
+	if signature == "" || ts == "" {
+		return ErrMissingSignature
+	}
+
+	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return ErrExpired
+	}
+	return nil
+}
+
+// VerifyRequest reads and verifies the body of a notification request. The body
+// is returned, and also left readable in req.
+func VerifyRequest(secret string, req *http.Request, tolerance time.Duration) ([]byte, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := Verify(secret, req.Header, body, tolerance); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -0,0 +1,100 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "s3cr3t"
+
+var testBody = []byte(`{"eventType":"deployed","id":"1","version":1}`)
+
+func TestGenerateSecret(t *testing.T) {
+	s1, err := GenerateSecret()
+	assert.Nil(t, err)
+	s2, _ := GenerateSecret()
+	assert.Equal(t, 2*secretLength, len(s1))
+	assert.NotEqual(t, s1, s2)
+}
+
+func TestSignIsStable(t *testing.T) {
+	assert.Equal(t, Sign(testSecret, 1600000000, testBody), Sign(testSecret, 1600000000, testBody))
+	assert.NotEqual(t, Sign(testSecret, 1600000000, testBody), Sign(testSecret, 1600000001, testBody))
+	assert.NotEqual(t, Sign(testSecret, 1600000000, testBody), Sign("other", 1600000000, testBody))
+}
+
+func TestVerifySuccess(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h, testSecret, "delivery-1", testBody)
+
+	assert.Equal(t, "delivery-1", h.Get(HeaderDeliveryID))
+	assert.Nil(t, Verify(testSecret, h, testBody, DefaultTolerance))
+}
+
+func TestVerifyFailsIfSignatureIsMissing(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h, "", "delivery-1", testBody)
+
+	assert.Equal(t, ErrMissingSignature, Verify(testSecret, h, testBody, DefaultTolerance))
+}
+
+func TestVerifyFailsIfBodyIsModified(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h, testSecret, "delivery-1", testBody)
+
+	assert.Equal(t, ErrInvalidSignature, Verify(testSecret, h, []byte(`{"eventType":"undeployed"}`), DefaultTolerance))
+}
+
+func TestVerifyFailsIfSecretIsWrong(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h, "other", "delivery-1", testBody)
+
+	assert.Equal(t, ErrInvalidSignature, Verify(testSecret, h, testBody, DefaultTolerance))
+}
+
+func TestVerifyFailsIfTimestampIsTooOld(t *testing.T) {
+	timestamp := time.Now().Add(-10 * time.Minute).Unix()
+	h := http.Header{}
+	h.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	h.Set(HeaderSignature, Sign(testSecret, timestamp, testBody))
+
+	assert.Equal(t, ErrExpired, Verify(testSecret, h, testBody, DefaultTolerance))
+}
+
+func TestVerifyRequestKeepsBodyReadable(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/xapps_hook", bytes.NewReader(testBody))
+	SetHeaders(req.Header, testSecret, "delivery-1", testBody)
+
+	body, err := VerifyRequest(testSecret, req, DefaultTolerance)
+	assert.Nil(t, err)
+	assert.Equal(t, testBody, body)
+
+	again, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, testBody, again)
+}